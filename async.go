@@ -0,0 +1,355 @@
+package opcda
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+//ErrTransactionCanceled is the error of an asynchronous transaction that was
+//canceled before it completed.
+var ErrTransactionCanceled = errors.New("transaction canceled")
+
+//AsyncResult is the completion of an asynchronous read, refresh or write.
+type AsyncResult struct {
+	TransactionID int32
+	//Results holds the items of a read or refresh.
+	Results map[string]ReadResult
+	//Errors holds the errors of the tags which could not be written.
+	Errors map[string]error
+	//Err is set if the transaction as a whole failed or was canceled.
+	Err error
+}
+
+//Transaction is a pending asynchronous request. Its result is sent on the
+//channel returned by Done, which is closed afterwards.
+type Transaction struct {
+	id       int32
+	cancelID int32
+	tags     map[int32]string // client handle to tag
+	done     chan AsyncResult
+	cancel   func() error
+
+	mu        sync.Mutex
+	initial   AsyncResult  // items which already failed when the request was sent
+	sent      bool         // the request has returned
+	result    *AsyncResult // result which arrived before the request returned
+	completed bool         // the result has been sent on done
+}
+
+//newTransaction returns a transaction whose Cancel calls cancel.
+func newTransaction(id int32, tags map[int32]string, cancel func() error) *Transaction {
+	return &Transaction{
+		id:      id,
+		tags:    tags,
+		initial: AsyncResult{Results: make(map[string]ReadResult), Errors: make(map[string]error)},
+		done:    make(chan AsyncResult, 1),
+		cancel:  cancel,
+	}
+}
+
+//ID returns the transaction ID which identifies the request.
+func (t *Transaction) ID() int32 {
+	return t.id
+}
+
+//Done returns the channel which receives the result of the transaction.
+func (t *Transaction) Done() <-chan AsyncResult {
+	return t.done
+}
+
+//Wait waits for the result of the transaction. If ctx is done first, the
+//transaction is canceled and ctx.Err() is returned.
+func (t *Transaction) Wait(ctx context.Context) (AsyncResult, error) {
+	select {
+	case result := <-t.done:
+		return result, nil
+	case <-ctx.Done():
+		t.Cancel()
+		return AsyncResult{TransactionID: t.id, Err: ctx.Err()}, ctx.Err()
+	}
+}
+
+//Cancel asks the server to cancel the transaction. If the server cancels it
+//in time, the result has ErrTransactionCanceled as error. A transaction that
+//has already completed cannot be canceled; its result stays on Done.
+func (t *Transaction) Cancel() error {
+	t.mu.Lock()
+	completed := t.completed
+	t.mu.Unlock()
+	if completed {
+		return errors.New("transaction already completed")
+	}
+	return t.cancel()
+}
+
+//failRead records a tag which could not be read when the request was sent.
+func (t *Transaction) failRead(tag string, err error) {
+	t.mu.Lock()
+	t.initial.Results[tag] = ReadResult{Err: err}
+	t.mu.Unlock()
+}
+
+//failWrite records a tag which could not be written when the request was sent.
+func (t *Transaction) failWrite(tag string, err error) {
+	t.mu.Lock()
+	t.initial.Errors[tag] = err
+	t.mu.Unlock()
+}
+
+//requestSent marks the request as returned and delivers a result which has
+//already arrived.
+func (t *Transaction) requestSent() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sent = true
+	if t.result != nil {
+		t.deliver(*t.result)
+	}
+}
+
+//complete sends the result of the transaction once the request has returned.
+func (t *Transaction) complete(result AsyncResult) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.sent {
+		if t.result == nil {
+			t.result = &result
+		}
+		return
+	}
+	t.deliver(result)
+}
+
+//deliver sends the result once, with the items which failed when the request
+//was sent merged into it. It must be called with t.mu held.
+func (t *Transaction) deliver(result AsyncResult) {
+	if t.completed {
+		return
+	}
+	t.completed = true
+	result.TransactionID = t.id
+	for tag, r := range t.initial.Results {
+		if result.Results == nil {
+			result.Results = make(map[string]ReadResult)
+		}
+		result.Results[tag] = r
+	}
+	for tag, err := range t.initial.Errors {
+		if result.Errors == nil {
+			result.Errors = make(map[string]error)
+		}
+		result.Errors[tag] = err
+	}
+	t.done <- result
+	close(t.done)
+}
+
+//AsyncIO is implemented by connections which read, refresh and write without
+//blocking until the server answers. The ctx only limits sending the request;
+//use Transaction.Wait to limit waiting for the result.
+type AsyncIO interface {
+	AsyncRead(ctx context.Context, tags ...string) (*Transaction, error)
+	AsyncRefresh(ctx context.Context, src ReadSource) (*Transaction, error)
+	AsyncWrite(ctx context.Context, values map[string]interface{}) (*Transaction, error)
+}
+
+//AsyncRead reads the tags asynchronously. If conn does not implement AsyncIO,
+//the tags are read with ReadItemContext in a goroutine.
+func AsyncRead(ctx context.Context, conn Connection, tags ...string) (*Transaction, error) {
+	if a, ok := conn.(AsyncIO); ok {
+		return a.AsyncRead(ctx, tags...)
+	}
+	return goTransaction(func(ctx context.Context) AsyncResult {
+		results := make(map[string]ReadResult)
+		for _, tag := range tags {
+			item, err := conn.ReadItemContext(ctx, tag)
+			results[tag] = ReadResult{Item: item, Err: err}
+		}
+		return AsyncResult{Results: results, Err: ctx.Err()}
+	}), nil
+}
+
+//AsyncRefresh reads all tags of conn asynchronously from src. If conn does not
+//implement AsyncIO, the tags are read with ReadContext in a goroutine.
+func AsyncRefresh(ctx context.Context, conn Connection, src ReadSource) (*Transaction, error) {
+	if a, ok := conn.(AsyncIO); ok {
+		return a.AsyncRefresh(ctx, src)
+	}
+	return goTransaction(func(ctx context.Context) AsyncResult {
+		results, err := conn.ReadContext(WithReadSource(ctx, src))
+		return AsyncResult{Results: results, Err: err}
+	}), nil
+}
+
+//AsyncWrite writes the values asynchronously. If conn does not implement
+//AsyncIO, the values are written with WriteMany in a goroutine.
+func AsyncWrite(ctx context.Context, conn Connection, values map[string]interface{}) (*Transaction, error) {
+	if a, ok := conn.(AsyncIO); ok {
+		return a.AsyncWrite(ctx, values)
+	}
+	return goTransaction(func(ctx context.Context) AsyncResult {
+		failures, err := WriteMany(ctx, conn, values, WriteOptions{})
+		return AsyncResult{Errors: failures, Err: err}
+	}), nil
+}
+
+//goTransactionID numbers the transactions of goTransaction.
+var goTransactionID struct {
+	sync.Mutex
+	last int32
+}
+
+//goTransaction runs f in a goroutine. Canceling the transaction cancels the
+//context of f.
+func goTransaction(f func(ctx context.Context) AsyncResult) *Transaction {
+	goTransactionID.Lock()
+	goTransactionID.last++
+	id := goTransactionID.last
+	goTransactionID.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t := newTransaction(id, nil, func() error {
+		cancel()
+		return nil
+	})
+	t.requestSent()
+	go func() {
+		defer cancel()
+		result := f(ctx)
+		if errors.Is(result.Err, context.Canceled) {
+			result.Err = ErrTransactionCanceled
+		}
+		t.complete(result)
+	}()
+	return t
+}
+
+//transactions keeps track of the pending transactions of an OPC group and
+//completes them with the events of the group.
+type transactions struct {
+	mu      sync.Mutex
+	last    int32
+	pending map[int32]*Transaction
+}
+
+func newTransactions() *transactions {
+	return &transactions{pending: make(map[int32]*Transaction)}
+}
+
+//start registers a new transaction for the tags with their client handles.
+//It must be called before the request is sent, as the server may complete it
+//before the request returns.
+func (ts *transactions) start(tags map[int32]string, cancel func(t *Transaction) error) *Transaction {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.last++
+	if ts.last <= 0 {
+		ts.last = 1
+	}
+	var t *Transaction
+	t = newTransaction(ts.last, tags, func() error { return cancel(t) })
+	ts.pending[t.id] = t
+	return t
+}
+
+//take removes the transaction with the ID from the pending transactions.
+func (ts *transactions) take(id int32) (*Transaction, bool) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	t, ok := ts.pending[id]
+	delete(ts.pending, id)
+	return t, ok
+}
+
+//abort completes the transaction with err, e.g. if the request could not be sent.
+func (ts *transactions) abort(t *Transaction, err error) {
+	ts.take(t.id)
+	t.complete(AsyncResult{Err: err})
+	t.requestSent()
+}
+
+//finish marks the request of t as returned. If none of its items were
+//accepted by the server, no event will complete it, so it is completed now.
+func (ts *transactions) finish(t *Transaction, accepted int) {
+	if accepted == 0 {
+		ts.take(t.id)
+		t.complete(AsyncResult{})
+	}
+	t.requestSent()
+}
+
+//readComplete completes a read or refresh with the items of the event.
+//For a refresh, only the items of the event are in the result.
+func (ts *transactions) readComplete(id int32, handles []int32, values []interface{}, qualities []Quality, timestamps []time.Time, errs []int32) {
+	t, ok := ts.take(id)
+	if !ok {
+		return
+	}
+	n := len(handles)
+	if len(values) != n || len(qualities) != n || len(timestamps) != n || (errs != nil && len(errs) != n) {
+		t.complete(AsyncResult{Err: errors.New("read completion arrays differ in length")})
+		return
+	}
+	results := make(map[string]ReadResult)
+	for i, handle := range handles {
+		tag, ok := t.tags[handle]
+		if !ok {
+			continue
+		}
+		if errs != nil && hresultFailed(errs[i]) {
+			results[tag] = ReadResult{Err: fmt.Errorf("cannot read %s: %w", tag, hresultError(errs[i]))}
+			continue
+		}
+		results[tag] = ReadResult{Item: Item{Value: values[i], Quality: qualities[i], Timestamp: timestamps[i]}}
+	}
+	t.complete(AsyncResult{Results: results})
+}
+
+//writeComplete completes a write with the item errors of the event.
+func (ts *transactions) writeComplete(id int32, handles []int32, errs []int32) {
+	t, ok := ts.take(id)
+	if !ok {
+		return
+	}
+	if len(errs) != len(handles) {
+		t.complete(AsyncResult{Err: errors.New("write completion arrays differ in length")})
+		return
+	}
+	failures := make(map[string]error)
+	for i, handle := range handles {
+		tag, ok := t.tags[handle]
+		if ok && hresultFailed(errs[i]) {
+			failures[tag] = fmt.Errorf("cannot write %s: %w", tag, hresultError(errs[i]))
+		}
+	}
+	t.complete(AsyncResult{Errors: failures})
+}
+
+//fail completes the transaction with the ID with err, e.g. if its event
+//cannot be decoded.
+func (ts *transactions) fail(id int32, err error) {
+	if t, ok := ts.take(id); ok {
+		t.complete(AsyncResult{Err: err})
+	}
+}
+
+//cancelComplete completes a canceled transaction.
+func (ts *transactions) cancelComplete(id int32) {
+	if t, ok := ts.take(id); ok {
+		t.complete(AsyncResult{Err: ErrTransactionCanceled})
+	}
+}
+
+//closeAll completes all pending transactions with err.
+func (ts *transactions) closeAll(err error) {
+	ts.mu.Lock()
+	pending := ts.pending
+	ts.pending = make(map[int32]*Transaction)
+	ts.mu.Unlock()
+	for _, t := range pending {
+		t.complete(AsyncResult{Err: err})
+	}
+}
@@ -0,0 +1,164 @@
+package opcda
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTransactionsReadComplete(t *testing.T) {
+	ts := newTransactions()
+	tx := ts.start(map[int32]string{1: "tag1", 2: "tag2"}, func(*Transaction) error { return nil })
+	tx.failRead("tag3", ErrTagNotFound)
+
+	now := time.Now()
+	// the completion may arrive before the request has returned
+	ts.readComplete(tx.ID(), []int32{2, 1}, []interface{}{2.0, nil}, []Quality{OPCQualityGood, OPCQualityBad},
+		[]time.Time{now, now}, []int32{0, testUnknownItemID})
+	select {
+	case <-tx.Done():
+		t.Fatal("result should wait until the request has returned")
+	default:
+	}
+	ts.finish(tx, 2)
+
+	result := <-tx.Done()
+	if result.Err != nil || result.TransactionID != tx.ID() {
+		t.Fatalf("unexpected result %v", result)
+	}
+	if result.Results["tag2"].Value != 2.0 || result.Results["tag2"].Err != nil {
+		t.Errorf("unexpected tag2 %v", result.Results["tag2"])
+	}
+	if err := result.Results["tag1"].Err; err == nil || !strings.Contains(err.Error(), "OPC_E_UNKNOWNITEMID") {
+		t.Errorf("tag1 should fail with OPC_E_UNKNOWNITEMID, got %v", err)
+	}
+	if !errors.Is(result.Results["tag3"].Err, ErrTagNotFound) {
+		t.Errorf("tag3 should fail when the request is sent, got %v", result.Results["tag3"].Err)
+	}
+	if _, ok := <-tx.Done(); ok {
+		t.Fatal("Done should be closed after the result")
+	}
+}
+
+func TestTransactionsWriteComplete(t *testing.T) {
+	ts := newTransactions()
+	tx := ts.start(map[int32]string{1: "tag1", 2: "tag2"}, func(*Transaction) error { return nil })
+	ts.finish(tx, 2)
+	ts.writeComplete(tx.ID(), []int32{1, 2}, []int32{testBadRights, 0})
+
+	result := <-tx.Done()
+	if len(result.Errors) != 1 || !strings.Contains(result.Errors["tag1"].Error(), "OPC_E_BADRIGHTS") {
+		t.Fatalf("only tag1 should fail, got %v", result.Errors)
+	}
+}
+
+func TestTransactionsCancel(t *testing.T) {
+	ts := newTransactions()
+	var canceled *Transaction
+	tx := ts.start(map[int32]string{1: "tag1"}, func(t *Transaction) error {
+		canceled = t
+		return nil
+	})
+	ts.finish(tx, 1)
+	if err := tx.Cancel(); err != nil || canceled != tx {
+		t.Fatal("Cancel should ask the server to cancel the transaction")
+	}
+	ts.cancelComplete(tx.ID())
+	if result := <-tx.Done(); !errors.Is(result.Err, ErrTransactionCanceled) {
+		t.Fatalf("transaction should be canceled, got %v", result.Err)
+	}
+	if tx.Cancel() == nil {
+		t.Fatal("completed transaction cannot be canceled")
+	}
+}
+
+func TestTransactionsCancelAfterComplete(t *testing.T) {
+	ts := newTransactions()
+	tx := ts.start(map[int32]string{1: "tag1"}, func(*Transaction) error {
+		t.Fatal("completed transaction should not be canceled on the server")
+		return nil
+	})
+	ts.finish(tx, 1)
+	ts.writeComplete(tx.ID(), []int32{1}, []int32{0})
+
+	if tx.Cancel() == nil {
+		t.Fatal("completed transaction cannot be canceled")
+	}
+	select {
+	case result, ok := <-tx.Done():
+		if !ok || result.TransactionID != tx.ID() || result.Err != nil {
+			t.Fatalf("Cancel should not consume the result, got %v", result)
+		}
+	default:
+		t.Fatal("result should still be on Done")
+	}
+}
+
+func TestTransactionsNothingAccepted(t *testing.T) {
+	ts := newTransactions()
+	tx := ts.start(map[int32]string{1: "tag1"}, func(*Transaction) error { return nil })
+	tx.failRead("tag1", errors.New("rejected"))
+	ts.finish(tx, 0)
+	result := <-tx.Done()
+	if result.Results["tag1"].Err == nil {
+		t.Fatal("rejected tag should be reported")
+	}
+}
+
+func TestTransactionsCloseAll(t *testing.T) {
+	ts := newTransactions()
+	tx1 := ts.start(nil, func(*Transaction) error { return nil })
+	tx2 := ts.start(nil, func(*Transaction) error { return nil })
+	if tx1.ID() == tx2.ID() {
+		t.Fatal("transactions should have unique IDs")
+	}
+	ts.finish(tx1, 1)
+	ts.finish(tx2, 1)
+	ts.closeAll(errors.New("closed"))
+	if (<-tx1.Done()).Err == nil || (<-tx2.Done()).Err == nil {
+		t.Fatal("pending transactions should fail when closed")
+	}
+}
+
+func TestAsyncFallback(t *testing.T) {
+	ctx := context.Background()
+	tx, err := AsyncRead(ctx, &OpcMockServerStatic{TagList: []string{"tag1", "tag2"}}, "tag2", "tag3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := tx.Wait(ctx)
+	if err != nil || result.Results["tag2"].Value != 2.0 || !errors.Is(result.Results["tag3"].Err, ErrTagNotFound) {
+		t.Fatalf("unexpected read result %v %v", result, err)
+	}
+
+	tx, _ = AsyncRefresh(ctx, &OpcMockServerSource{TagList: []string{"tag1"}}, SourceDevice)
+	result = <-tx.Done()
+	if result.Results["tag1"].Value != float64(OPCDevice) {
+		t.Fatalf("refresh should read from the source, got %v", result.Results)
+	}
+
+	server := newWritableServer()
+	server.FailWrite["speed"] = true
+	tx, _ = AsyncWrite(ctx, server, recipe)
+	result = <-tx.Done()
+	if len(result.Errors) != 1 || result.Errors["speed"] == nil || server.Value("temp") != 80.0 {
+		t.Fatalf("unexpected write result %v", result.Errors)
+	}
+}
+
+func TestAsyncFallbackCancel(t *testing.T) {
+	tx, err := AsyncRead(context.Background(), &OpcMockServerHung{}, "tag1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := tx.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait should give up with the context, got %v", err)
+	}
+	if result := <-tx.Done(); !errors.Is(result.Err, ErrTransactionCanceled) {
+		t.Fatalf("transaction should be canceled, got %v", result.Err)
+	}
+}
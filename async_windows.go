@@ -0,0 +1,449 @@
+//go:build windows
+// +build windows
+
+package opcda
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+// groupAsyncIO calls the asynchronous methods of an OPCGroup.
+type groupAsyncIO struct {
+	group *ole.IDispatch
+}
+
+// asyncRead calls AsyncRead(NumItems, ServerHandles, Errors, TransactionID, CancelID).
+func (g groupAsyncIO) asyncRead(handles []int32, id int32) ([]int32, int32, error) {
+	serverHandles, err := safeArrayFromInt32s(handles)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer destroySafeArray(serverHandles)
+
+	var errs *ole.SafeArray
+	var cancelID int32
+	_, err = invokeMethod(g.group, "AsyncRead",
+		ole.NewVariant(ole.VT_I4, int64(len(handles))),
+		byrefArray(ole.VT_I4, &serverHandles),
+		byrefArray(ole.VT_I4, &errs),
+		ole.NewVariant(ole.VT_I4, int64(id)),
+		byrefInt32(&cancelID),
+	)
+	defer destroySafeArray(errs)
+	if err != nil {
+		return nil, 0, refineOleError(err)
+	}
+	itemErrs, err := int32sFromSafeArray(errs)
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot decode AsyncRead errors: %w", err)
+	}
+	return itemErrs, cancelID, nil
+}
+
+// asyncWrite calls AsyncWrite(NumItems, ServerHandles, Values, Errors, TransactionID, CancelID).
+func (g groupAsyncIO) asyncWrite(handles []int32, values []interface{}, id int32) ([]int32, int32, error) {
+	serverHandles, err := safeArrayFromInt32s(handles)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer destroySafeArray(serverHandles)
+	writeValues, err := safeArrayFromValues(values)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer destroySafeArray(writeValues)
+
+	var errs *ole.SafeArray
+	var cancelID int32
+	_, err = invokeMethod(g.group, "AsyncWrite",
+		ole.NewVariant(ole.VT_I4, int64(len(handles))),
+		byrefArray(ole.VT_I4, &serverHandles),
+		byrefArray(ole.VT_VARIANT, &writeValues),
+		byrefArray(ole.VT_I4, &errs),
+		ole.NewVariant(ole.VT_I4, int64(id)),
+		byrefInt32(&cancelID),
+	)
+	defer destroySafeArray(errs)
+	if err != nil {
+		return nil, 0, refineOleError(err)
+	}
+	itemErrs, err := int32sFromSafeArray(errs)
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot decode AsyncWrite errors: %w", err)
+	}
+	return itemErrs, cancelID, nil
+}
+
+// asyncRefresh calls AsyncRefresh(Source, TransactionID, CancelID).
+func (g groupAsyncIO) asyncRefresh(source int32, id int32) (int32, error) {
+	var cancelID int32
+	_, err := invokeMethod(g.group, "AsyncRefresh",
+		ole.NewVariant(ole.VT_I2, int64(source)),
+		ole.NewVariant(ole.VT_I4, int64(id)),
+		byrefInt32(&cancelID),
+	)
+	if err != nil {
+		return 0, refineOleError(err)
+	}
+	return cancelID, nil
+}
+
+// asyncCancel calls AsyncCancel(CancelID).
+func (g groupAsyncIO) asyncCancel(cancelID int32) error {
+	_, err := invokeMethod(g.group, "AsyncCancel", ole.NewVariant(ole.VT_I4, int64(cancelID)))
+	if err != nil {
+		return refineOleError(err)
+	}
+	return nil
+}
+
+// asyncEvents receives the completion events of an OPC group and completes
+// the pending transactions. lock serializes the calls to the group.
+type asyncEvents struct {
+	group        *ole.IDispatch
+	sink         *eventSink
+	point        *ole.IConnectionPoint
+	cookie       uint32
+	transactions *transactions
+	lock         func(func())
+	closed       bool
+}
+
+// asyncEvents returns the events of the group of ai. They are connected on
+// first use, which subscribes the group to its events.
+func (ai *AutomationItems) asyncEvents(lock func(func())) (*asyncEvents, error) {
+	if ai.async != nil {
+		return ai.async, nil
+	}
+	if ai.group == nil {
+		return nil, errors.New("group is not available")
+	}
+	ae := &asyncEvents{group: oleDispatch(ai.group), transactions: newTransactions(), lock: lock}
+	ae.sink = newEventSink(iidOPCGroupEvent, ae.handle)
+	var err error
+	ae.point, ae.cookie, err = advise(oleDispatch(ai.group), iidOPCGroupEvent, ae.sink)
+	if err != nil {
+		ae.sink.release()
+		return nil, fmt.Errorf("cannot connect to group events: %w", refineOleError(err))
+	}
+	if err := ai.group.put("IsSubscribed", true); err != nil {
+		unadvise(ae.point, ae.cookie)
+		ae.sink.release()
+		return nil, fmt.Errorf("cannot subscribe to group: %w", refineOleError(err))
+	}
+	ai.async = ae
+	return ae, nil
+}
+
+// close disconnects the events and fails the pending transactions.
+func (ae *asyncEvents) close() {
+	ae.closed = true
+	unadvise(ae.point, ae.cookie)
+	ae.point = nil
+	ae.sink.release()
+	ae.transactions.closeAll(errors.New("connection closed"))
+}
+
+// start registers a transaction for the tags whose Cancel calls AsyncCancel.
+func (ae *asyncEvents) start(tags map[int32]string) *Transaction {
+	return ae.transactions.start(tags, func(t *Transaction) error {
+		err := errors.New("connection closed")
+		ae.lock(func() {
+			if !ae.closed {
+				err = groupAsyncIO{ae.group}.asyncCancel(t.cancelID)
+			}
+		})
+		return err
+	})
+}
+
+// handle is called by COM for every event of the group. The arguments are
+// passed in reverse order.
+// DataChange(TransactionID, NumItems, ClientHandles, ItemValues, Qualities, TimeStamps)
+// AsyncReadComplete(TransactionID, NumItems, ClientHandles, ItemValues, Qualities, TimeStamps, Errors)
+// AsyncWriteComplete(TransactionID, NumItems, ClientHandles, Errors)
+// AsyncCancelComplete(CancelID)
+func (ae *asyncEvents) handle(dispid int32, args []ole.VARIANT) {
+	switch {
+	case dispid == dispidDataChange && len(args) == 6:
+		// DataChange without a transaction is not the result of a refresh
+		if id, err := int32FromVariant(&args[5]); err == nil && id != 0 {
+			ae.readComplete(id, args[3:4], args[2:3], args[1:2], args[0:1], nil)
+		}
+	case dispid == dispidAsyncReadComplete && len(args) == 7:
+		if id, err := int32FromVariant(&args[6]); err == nil {
+			ae.readComplete(id, args[4:5], args[3:4], args[2:3], args[1:2], args[0:1])
+		}
+	case dispid == dispidAsyncWriteComplete && len(args) == 4:
+		id, err := int32FromVariant(&args[3])
+		if err != nil {
+			return
+		}
+		handles, err := int32sFromVariant(&args[1])
+		if err != nil {
+			ae.transactions.fail(id, fmt.Errorf("cannot decode write completion: %w", err))
+			return
+		}
+		errs, err := int32sFromVariant(&args[0])
+		if err != nil {
+			ae.transactions.fail(id, fmt.Errorf("cannot decode write completion: %w", err))
+			return
+		}
+		ae.transactions.writeComplete(id, handles, errs)
+	case dispid == dispidAsyncCancelComplete && len(args) == 1:
+		if id, err := int32FromVariant(&args[0]); err == nil {
+			ae.transactions.cancelComplete(id)
+		}
+	}
+}
+
+// readComplete decodes the arrays of a read or refresh completion. Each
+// argument is a slice of one VARIANT, errs is nil for a refresh.
+func (ae *asyncEvents) readComplete(id int32, handles, values, qualities, timestamps, errs []ole.VARIANT) {
+	fail := func(err error) {
+		ae.transactions.fail(id, fmt.Errorf("cannot decode read completion: %w", err))
+	}
+	itemHandles, err := int32sFromVariant(&handles[0])
+	if err != nil {
+		fail(err)
+		return
+	}
+	itemValues, err := valuesFromVariant(&values[0])
+	if err != nil {
+		fail(err)
+		return
+	}
+	itemQualities, err := int32sFromVariant(&qualities[0])
+	if err != nil {
+		fail(err)
+		return
+	}
+	itemTimestamps, err := timesFromVariant(&timestamps[0])
+	if err != nil {
+		fail(err)
+		return
+	}
+	var itemErrs []int32
+	if errs != nil {
+		if itemErrs, err = int32sFromVariant(&errs[0]); err != nil {
+			fail(err)
+			return
+		}
+	}
+	qualities16 := make([]Quality, len(itemQualities))
+	for i, q := range itemQualities {
+		qualities16[i] = Quality(ensureInt16(q))
+	}
+	ae.transactions.readComplete(id, itemHandles, itemValues, qualities16, itemTimestamps, itemErrs)
+}
+
+// clientHandles returns the client handles of the tags, or of all readable
+// tags if none are given, and the tags which are not added.
+func (ai *AutomationItems) clientHandles(tags ...string) (map[int32]string, []string) {
+	handles := make(map[int32]string)
+	var missing []string
+	if len(tags) == 0 {
+		for tag, opcitem := range ai.items {
+			if !opcitem.writeOnly {
+				handles[opcitem.clientHandle] = tag
+			}
+		}
+		return handles, nil
+	}
+	for _, tag := range tags {
+		if opcitem, ok := ai.items[tag]; ok {
+			handles[opcitem.clientHandle] = tag
+		} else {
+			missing = append(missing, tag)
+		}
+	}
+	return handles, missing
+}
+
+// asyncRead sends an AsyncRead for the tags.
+func (ai *AutomationItems) asyncRead(lock func(func()), tags []string) (*Transaction, error) {
+	ae, err := ai.asyncEvents(lock)
+	if err != nil {
+		return nil, err
+	}
+	handles, missing := ai.clientHandles(tags...)
+	t := ae.start(handles)
+	for _, tag := range missing {
+		t.failRead(tag, fmt.Errorf("%s: %w", tag, ErrTagNotFound))
+	}
+	order, serverHandles := ai.requestOrder(handles)
+	if len(order) == 0 {
+		ae.transactions.finish(t, 0)
+		return t, nil
+	}
+	errs, cancelID, err := groupAsyncIO{oleDispatch(ai.group)}.asyncRead(serverHandles, t.id)
+	if err == nil && len(errs) != len(order) {
+		err = errors.New("AsyncRead returned an array of unexpected length")
+	}
+	if err != nil {
+		ae.transactions.abort(t, err)
+		return nil, err
+	}
+	t.cancelID = cancelID
+	accepted := 0
+	for i, tag := range order {
+		if hresultFailed(errs[i]) {
+			t.failRead(tag, fmt.Errorf("cannot read %s: %w", tag, hresultError(errs[i])))
+			continue
+		}
+		accepted++
+	}
+	ae.transactions.finish(t, accepted)
+	return t, nil
+}
+
+// asyncRefresh sends an AsyncRefresh of all active items of the group.
+func (ai *AutomationItems) asyncRefresh(lock func(func()), src ReadSource) (*Transaction, error) {
+	if src.MaxAge > 0 {
+		return nil, errors.New("AsyncRefresh does not support a max age")
+	}
+	ae, err := ai.asyncEvents(lock)
+	if err != nil {
+		return nil, err
+	}
+	handles, _ := ai.clientHandles()
+	t := ae.start(handles)
+	if len(handles) == 0 {
+		ae.transactions.finish(t, 0)
+		return t, nil
+	}
+	cancelID, err := groupAsyncIO{oleDispatch(ai.group)}.asyncRefresh(src.Source, t.id)
+	if err != nil {
+		ae.transactions.abort(t, err)
+		return nil, err
+	}
+	t.cancelID = cancelID
+	ae.transactions.finish(t, len(handles))
+	return t, nil
+}
+
+// asyncWrite sends an AsyncWrite of the values. Tags which are not added yet
+// are added as write-only tags.
+func (ai *AutomationItems) asyncWrite(lock func(func()), values map[string]interface{}) (*Transaction, error) {
+	ae, err := ai.asyncEvents(lock)
+	if err != nil {
+		return nil, err
+	}
+	failures := ai.ensure(sortedKeys(values)...)
+	handles, _ := ai.clientHandles(sortedKeys(values)...)
+	t := ae.start(handles)
+	for tag, err := range failures {
+		t.failWrite(tag, err)
+	}
+	order, serverHandles := ai.requestOrder(handles)
+	if len(order) == 0 {
+		ae.transactions.finish(t, 0)
+		return t, nil
+	}
+	writeValues := make([]interface{}, len(order))
+	for i, tag := range order {
+		writeValues[i] = values[tag]
+	}
+	errs, cancelID, err := groupAsyncIO{oleDispatch(ai.group)}.asyncWrite(serverHandles, writeValues, t.id)
+	if err == nil && len(errs) != len(order) {
+		err = errors.New("AsyncWrite returned an array of unexpected length")
+	}
+	if err != nil {
+		ae.transactions.abort(t, err)
+		return nil, err
+	}
+	t.cancelID = cancelID
+	accepted := 0
+	for i, tag := range order {
+		if hresultFailed(errs[i]) {
+			t.failWrite(tag, fmt.Errorf("cannot write %s: %w", tag, hresultError(errs[i])))
+			continue
+		}
+		accepted++
+	}
+	ae.transactions.finish(t, accepted)
+	return t, nil
+}
+
+// requestOrder returns the tags of the client handles in alphabetical order
+// and their server handles.
+func (ai *AutomationItems) requestOrder(handles map[int32]string) ([]string, []int32) {
+	byTag := make(map[string]int32)
+	for _, tag := range handles {
+		byTag[tag] = ai.items[tag].serverHandle
+	}
+	order := sortedTags(byTag)
+	serverHandles := make([]int32, len(order))
+	for i, tag := range order {
+		serverHandles[i] = byTag[tag]
+	}
+	return order, serverHandles
+}
+
+// lockFunc returns a function which runs f with the lock of conn held.
+func (conn *opcConnectionImpl) lockFunc() func(func()) {
+	return func(f func()) { conn.mu.do(context.Background(), f) }
+}
+
+// AsyncRead sends an asynchronous read of the tags to the server. The result
+// arrives on the Done channel of the returned transaction.
+func (conn *opcConnectionImpl) AsyncRead(ctx context.Context, tags ...string) (*Transaction, error) {
+	return conn.asyncRead(ctx, func() *AutomationItems { return conn.AutomationItems }, tags)
+}
+
+// AsyncRefresh sends an asynchronous read of all readable tags from src.
+func (conn *opcConnectionImpl) AsyncRefresh(ctx context.Context, src ReadSource) (*Transaction, error) {
+	return conn.asyncRefresh(ctx, func() *AutomationItems { return conn.AutomationItems }, src)
+}
+
+// AsyncWrite sends an asynchronous write of the values. Tags which are not
+// added yet are added as write-only tags.
+func (conn *opcConnectionImpl) AsyncWrite(ctx context.Context, values map[string]interface{}) (*Transaction, error) {
+	return conn.asyncWrite(ctx, func() *AutomationItems { return conn.AutomationItems }, values)
+}
+
+// asyncRead sends an AsyncRead to the items returned by items.
+func (conn *opcConnectionImpl) asyncRead(ctx context.Context, items func() *AutomationItems, tags []string) (*Transaction, error) {
+	var t *Transaction
+	var err error
+	cerr := conn.mu.do(ctx, func() {
+		t, err = items().asyncRead(conn.lockFunc(), tags)
+	})
+	if cerr != nil {
+		return nil, cerr
+	}
+	return t, err
+}
+
+// asyncRefresh sends an AsyncRefresh to the items returned by items.
+func (conn *opcConnectionImpl) asyncRefresh(ctx context.Context, items func() *AutomationItems, src ReadSource) (*Transaction, error) {
+	if err := src.validate(); err != nil {
+		return nil, err
+	}
+	var t *Transaction
+	var err error
+	cerr := conn.mu.do(ctx, func() {
+		t, err = items().asyncRefresh(conn.lockFunc(), src)
+	})
+	if cerr != nil {
+		return nil, cerr
+	}
+	return t, err
+}
+
+// asyncWrite sends an AsyncWrite to the items returned by items.
+func (conn *opcConnectionImpl) asyncWrite(ctx context.Context, items func() *AutomationItems, values map[string]interface{}) (*Transaction, error) {
+	var t *Transaction
+	var err error
+	cerr := conn.mu.do(ctx, func() {
+		t, err = items().asyncWrite(conn.lockFunc(), values)
+	})
+	if cerr != nil {
+		return nil, cerr
+	}
+	return t, err
+}
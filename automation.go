@@ -0,0 +1,665 @@
+package opcda
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+//AutomationObject loads the OPC Automation Wrapper and handles to connection to the OPC Server.
+type AutomationObject struct {
+	unknown *ole.IUnknown
+	opc     dispatcher
+	opts    options
+}
+
+//log returns the logger of the object or the package logger.
+func (ao *AutomationObject) log() *log.Logger {
+	if ao == nil {
+		return logger
+	}
+	return ao.opts.log()
+}
+
+//CreateBrowser returns the OPCBrowser object from the OPCServer.
+//It only works if there is a successful connection.
+func (ao *AutomationObject) CreateBrowser() (*Tree, error) {
+	//check if server is running, if not return error
+	if !ao.IsConnected() {
+		return nil, fmt.Errorf("cannot create browser: %w", ErrNotConnected)
+	}
+
+	browser, err := callObject(ao.opc, "CreateBrowser")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OPCBrowser: %w", refineOleError(err))
+	}
+	defer browser.release()
+
+	if _, err := browser.call("MoveToRoot"); err != nil {
+		return nil, fmt.Errorf("cannot move to root: %w", refineOleError(err))
+	}
+
+	root := Tree{"root", nil, []*Tree{}, []Leaf{}}
+	if err := buildTree(ao.log(), browser, &root); err != nil {
+		return nil, err
+	}
+	return &root, nil
+}
+
+//buildTree runs through the OPCBrowser and creates a tree with the OPC tags.
+func buildTree(out *log.Logger, browser dispatcher, branch *Tree) error {
+	out.Println("Entering branch:", branch.Name)
+
+	leaves, err := showLeafs(browser)
+	if err != nil {
+		return fmt.Errorf("cannot browse leafs of %s: %w", branch.Name, err)
+	}
+	out.Println("\tLeafs count:", len(leaves))
+	for i, l := range leaves {
+		out.Println("\t", i+1, l)
+	}
+	branch.Leaves = append(branch.Leaves, leaves...)
+
+	names, err := showBranches(browser)
+	if err != nil {
+		return fmt.Errorf("cannot browse branches of %s: %w", branch.Name, err)
+	}
+	out.Println("\tBranches count:", len(names))
+	for i, name := range names {
+		out.Println("\t", i+1, "next branch:", name)
+		if _, err := browser.call("MoveDown", name); err != nil {
+			return fmt.Errorf("cannot move down to %s: %w", name, refineOleError(err))
+		}
+		nextBranch := Tree{name, branch, []*Tree{}, []Leaf{}}
+		branch.Branches = append(branch.Branches, &nextBranch)
+		if err := buildTree(out, browser, &nextBranch); err != nil {
+			return err
+		}
+		if _, err := browser.call("MoveUp"); err != nil {
+			return fmt.Errorf("cannot move up from %s: %w", name, refineOleError(err))
+		}
+	}
+
+	out.Println("Exiting branch:", branch.Name)
+	return nil
+}
+
+//Connect establishes a connection to the OPC Server on node.
+//It returns a reference to AutomationItems and error message.
+func (ao *AutomationObject) Connect(server string, node string) (*AutomationItems, error) {
+	//make sure there is not active connection before trying to connect
+	ao.disconnect()
+
+	ao.log().Printf("Connecting to %s on node %s\n", server, node)
+	if _, err := ao.opc.call("Connect", server, node); err != nil {
+		ao.log().Println("Connection failed:", err)
+		return nil, fmt.Errorf("connection failed: %w", refineOleError(err))
+	}
+
+	//set up opc groups and items
+	opcGroups, err := getObject(ao.opc, "OPCGroups")
+	if err != nil {
+		return nil, fmt.Errorf("cannot get OPCGroups property: %w", refineOleError(err))
+	}
+	defer opcGroups.release()
+	var group dispatcher
+	if ao.opts.groupName != "" {
+		group, err = callObject(opcGroups, "Add", ao.opts.groupName)
+	} else {
+		group, err = callObject(opcGroups, "Add")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot add new OPC Group: %w", refineOleError(err))
+	}
+	addItemObject, err := getObject(group, "OPCItems")
+	if err != nil {
+		group.release()
+		return nil, fmt.Errorf("cannot get OPC Items: %w", refineOleError(err))
+	}
+	if err := ao.applyOptions(group); err != nil {
+		addItemObject.release()
+		group.release()
+		return nil, err
+	}
+
+	ao.log().Println("Connected.")
+
+	//the items keep the group for SyncRead and SyncWrite
+	items := newAutomationItems(addItemObject)
+	items.group = group
+	items.out = ao.opts.logger
+	return items, nil
+}
+
+//applyOptions sets the locale of the server and the update rate and deadband
+//of the default group if they were given as options.
+func (ao *AutomationObject) applyOptions(group dispatcher) error {
+	if ao.opts.hasLocaleID {
+		if err := ao.opc.put("LocaleID", int32(ao.opts.localeID)); err != nil {
+			return fmt.Errorf("cannot set LocaleID: %w", refineOleError(err))
+		}
+	}
+	if ao.opts.hasUpdateRate {
+		if err := group.put("UpdateRate", int32(ao.opts.updateRate/time.Millisecond)); err != nil {
+			return fmt.Errorf("cannot set UpdateRate: %w", refineOleError(err))
+		}
+	}
+	if ao.opts.hasDeadBand {
+		if err := group.put("DeadBand", ao.opts.deadBand); err != nil {
+			return fmt.Errorf("cannot set DeadBand: %w", refineOleError(err))
+		}
+	}
+	return nil
+}
+
+//TryConnect loops over the nodes array and tries to connect to any of the servers.
+//If all nodes fail, it returns a MultiError with a *ConnectError for every node.
+func (ao *AutomationObject) TryConnect(server string, nodes []string) (*AutomationItems, error) {
+	items, _, err := ao.tryConnect(context.Background(), server, nodes)
+	return items, err
+}
+
+//connectResult is the result of an attempt to connect.
+type connectResult struct {
+	items *AutomationItems
+	err   error
+}
+
+//tryConnect is TryConnect which gives up once ctx is done, even during an
+//attempt. Like ctxMutex.do, it runs the attempt in its own goroutine; an
+//abandoned attempt keeps running in the background and closes its items and
+//ao when it returns. tryConnect reports if it abandoned an attempt, in which
+//case ao must no longer be used.
+func (ao *AutomationObject) tryConnect(ctx context.Context, server string, nodes []string) (*AutomationItems, bool, error) {
+	if len(nodes) == 0 {
+		return nil, false, &ConnectError{Server: server, Err: errors.New("no nodes given")}
+	}
+	var errs []error
+	for i, node := range nodes {
+		if ctx.Err() != nil {
+			errs = append(errs, &ConnectError{Server: server, Node: node, Err: ctx.Err()})
+			continue
+		}
+		done := make(chan connectResult, 1)
+		go func(node string) {
+			items, err := ao.Connect(server, node)
+			done <- connectResult{items, err}
+		}(node)
+		var r connectResult
+		select {
+		case r = <-done:
+		case <-ctx.Done():
+			select {
+			case r = <-done:
+			default:
+				go ao.release(done)
+				for _, node := range nodes[i:] {
+					errs = append(errs, &ConnectError{Server: server, Node: node, Err: ctx.Err()})
+				}
+				return nil, true, multiError(errs)
+			}
+		}
+		if r.err == nil {
+			return r.items, false, nil
+		}
+		errs = append(errs, &ConnectError{Server: server, Node: node, Err: r.err})
+	}
+	return nil, false, multiError(errs)
+}
+
+//release waits for an abandoned attempt to connect and closes its items and
+//ao.
+func (ao *AutomationObject) release(done <-chan connectResult) {
+	r := <-done
+	if r.items != nil {
+		r.items.Close()
+	}
+	ao.Close()
+	ao.log().Println("Closed the abandoned connection.")
+}
+
+//reconnect connects again if the connection is lost and adds the tags of old,
+//which it closes, to the new items. It returns old if the server is connected.
+func (ao *AutomationObject) reconnect(server string, nodes []string, old *AutomationItems) (*AutomationItems, error) {
+	if ao.IsConnected() {
+		return old, nil
+	}
+	items, err := ao.TryConnect(server, nodes)
+	if err != nil {
+		return nil, err
+	}
+	tags := old.tags()
+	if items.Add(tags...) == nil {
+		ao.log().Printf("Added %d tags", len(tags))
+	}
+	for tag, opcitem := range items.items {
+		if prev, ok := old.items[tag]; ok {
+			opcitem.writeOnly = prev.writeOnly
+		}
+	}
+	old.Close()
+	return items, nil
+}
+
+//IsConnected check if the server is properly connected and up and running.
+func (ao *AutomationObject) IsConnected() bool {
+	if ao == nil || ao.opc == nil {
+		return false
+	}
+	state, err := ao.opc.get("ServerState")
+	if err != nil {
+		ao.log().Println("GetProperty call for ServerState failed", err)
+		return false
+	}
+	if s, _ := toInt64(state); ServerState(s) != OPCRunning {
+		return false
+	}
+	return true
+}
+
+//GetOPCServers returns a list of Prog ID on the specified node
+func (ao *AutomationObject) GetOPCServers(node string) []string {
+	progids, err := ao.opc.call("GetOPCServers", node)
+	if err != nil {
+		ao.log().Println("GetOPCServers call failed.")
+		return []string{}
+	}
+	return nonEmptyStrings(progids)
+}
+
+//PublicGroupNames returns the names of the public groups of the server.
+func (ao *AutomationObject) PublicGroupNames() []string {
+	if !ao.IsConnected() {
+		return []string{}
+	}
+	publicGroups, err := ao.opc.get("PublicGroupNames")
+	if err != nil {
+		ao.log().Println("GetProperty call for PublicGroupNames failed", err)
+		return []string{}
+	}
+	return nonEmptyStrings(publicGroups)
+}
+
+//disconnect checks if connected to server and if so, it calls 'disconnect'
+func (ao *AutomationObject) disconnect() {
+	if ao.IsConnected() {
+		if _, err := ao.opc.call("Disconnect"); err != nil {
+			ao.log().Println("Failed to disconnect.")
+		}
+	}
+}
+
+//Close releases the OLE objects in the AutomationObject.
+func (ao *AutomationObject) Close() {
+	if ao.opc != nil {
+		ao.disconnect()
+		ao.opc.release()
+		ao.opc = nil
+	}
+	if ao.unknown != nil {
+		ao.unknown.Release()
+		ao.unknown = nil
+	}
+}
+
+//AutomationItems store the OPCItems from OPCGroup and does the bookkeeping
+//for the individual OPC items. Tags can added, removed, and read.
+//If the OPCGroup is known, all items are read and written with a single
+//SyncRead or SyncWrite call of the group, and asynchronously with the events
+//of the group.
+type AutomationItems struct {
+	addItemObject dispatcher
+	group         dispatcher
+	items         map[string]*itemWrap
+	lastHandle    int32
+	out           *log.Logger
+	platformItems
+}
+
+type itemWrap struct {
+	dispatcher
+	writeOnly    bool // if true, conn.Read() will not read this item
+	serverHandle int32
+	clientHandle int32
+}
+
+//newAutomationItems returns AutomationItems which add their items to addItemObject.
+func newAutomationItems(addItemObject dispatcher) *AutomationItems {
+	return &AutomationItems{addItemObject: addItemObject, items: make(map[string]*itemWrap)}
+}
+
+//log returns the logger of the items or the package logger.
+func (ai *AutomationItems) log() *log.Logger {
+	if ai == nil || ai.out == nil {
+		return logger
+	}
+	return ai.out
+}
+
+//addSingle adds the tag with a unique client handle and returns an error.
+func (ai *AutomationItems) addSingle(tag string) error {
+	if ai.addItemObject == nil {
+		return &AddItemError{Tag: tag, Err: errors.New("group is not available")}
+	}
+	ai.lastHandle++
+	clientHandle := ai.lastHandle
+	v, err := ai.addItemObject.call("AddItem", tag, clientHandle)
+	if err != nil {
+		err = refineOleError(err)
+		return &AddItemError{Tag: tag, HRESULT: hresultOf(err), Err: err}
+	}
+	//if item does not belong to address space, there is no item
+	item, ok := v.(dispatcher)
+	if !ok || item == nil {
+		return &AddItemError{Tag: tag, Err: errors.New("could not get IDispatch")}
+	}
+	serverHandle, err := getInt32(item, "ServerHandle")
+	if err != nil {
+		item.release()
+		return &AddItemError{Tag: tag, Err: errors.New("could not get ServerHandle")}
+	}
+	ai.items[tag] = &itemWrap{item, false, serverHandle, clientHandle}
+	return nil
+}
+
+//Add accepts a variadic parameters of tags.
+//If tags cannot be added, it returns a MultiError with an *AddItemError for each.
+func (ai *AutomationItems) Add(tags ...string) error {
+	var errs []error
+	for _, tag := range tags {
+		err := ai.addSingle(tag)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return multiError(errs)
+}
+
+//Remove removes the tag.
+func (ai *AutomationItems) Remove(tag string) {
+	item, ok := ai.items[tag]
+	if ok {
+		item.release()
+	}
+	delete(ai.items, tag)
+}
+
+//serverHandles returns the server handles of the tags, or of all readable
+//tags if none are given.
+func (ai *AutomationItems) serverHandles(tags ...string) map[string]int32 {
+	handles := make(map[string]int32)
+	if len(tags) == 0 {
+		for tag, opcitem := range ai.items {
+			if !opcitem.writeOnly {
+				handles[tag] = opcitem.serverHandle
+			}
+		}
+		return handles
+	}
+	for _, tag := range tags {
+		if opcitem, ok := ai.items[tag]; ok {
+			handles[tag] = opcitem.serverHandle
+		}
+	}
+	return handles
+}
+
+//ensure adds the tags which are not added yet as write-only tags and returns
+//the errors of the tags which could not be added.
+func (ai *AutomationItems) ensure(tags ...string) map[string]error {
+	failures := make(map[string]error)
+	for _, tag := range tags {
+		if _, ok := ai.items[tag]; ok {
+			continue
+		}
+		if err := ai.addSingle(tag); err != nil {
+			failures[tag] = err
+			continue
+		}
+		ai.items[tag].writeOnly = true
+	}
+	return failures
+}
+
+//tags returns the added tags.
+func (ai *AutomationItems) tags() []string {
+	var tags []string
+	if ai != nil {
+		for tag := range ai.items {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+//Close closes the OLE objects in AutomationItems.
+func (ai *AutomationItems) Close() {
+	if ai == nil {
+		return
+	}
+	ai.platformItems.close()
+	for key, opcitem := range ai.items {
+		opcitem.release()
+		delete(ai.items, key)
+	}
+	if ai.addItemObject != nil {
+		ai.addItemObject.release()
+		ai.addItemObject = nil
+	}
+	if ai.group != nil {
+		ai.group.release()
+		ai.group = nil
+	}
+}
+
+//syncIO returns the SyncRead and SyncWrite of the group of the items, or nil
+//if the group is not known.
+func (ai *AutomationItems) syncIO() syncIO {
+	if ai.group == nil {
+		return nil
+	}
+	if io, ok := ai.group.(syncIO); ok {
+		return io
+	}
+	return newGroupSyncIO(ai.group)
+}
+
+/*
+ * FIX:
+ * some opc servers sometimes returns an int32 Quality, that produces panic
+ */
+func ensureInt16(q interface{}) int16 {
+	if v16, ok := q.(int16); ok {
+		return v16
+	}
+	if v32, ok := q.(int32); ok && v32 >= -32768 && v32 < 32768 {
+		return int16(v32)
+	}
+	return 0
+}
+
+//itemReader is implemented by OPCItems which read their value, quality and
+//timestamp from the server with a single call of Read.
+type itemReader interface {
+	readItem(source int32) (Item, error)
+}
+
+//readFromOPC reads from source of the server and returns an Item and error.
+//It is only used for items without a known group, which are read one by one.
+func (ai *AutomationItems) readFromOpc(opcitem dispatcher, source int32) (Item, error) {
+	r, ok := opcitem.(itemReader)
+	if !ok {
+		return Item{}, errors.New("item cannot be read without its group")
+	}
+	return r.readItem(source)
+}
+
+//readFromSource reads opcitem from src. A stale value from the cache is
+//read again from the device.
+func (ai *AutomationItems) readFromSource(opcitem dispatcher, src ReadSource) (Item, error) {
+	item, err := ai.readFromOpc(opcitem, src.Source)
+	if err != nil || !src.stale(item, time.Now()) {
+		return item, err
+	}
+	return ai.readFromOpc(opcitem, OPCDevice)
+}
+
+//writeToOPC writes value to opc tag and return an error
+func (ai *AutomationItems) writeToOpc(opcitem dispatcher, value interface{}) error {
+	_, err := opcitem.call("Write", value)
+	if err != nil {
+		//TODO: Prometheus Monitoring
+		//opcWritesCounter.WithLabelValues("failed").Inc()
+		return refineOleError(err)
+	}
+	//opcWritesCounter.WithLabelValues("failed").Inc()
+	return nil
+}
+
+//read reads a single added tag from src.
+func (ai *AutomationItems) read(tag string, src ReadSource) (Item, error) {
+	opcitem, ok := ai.items[tag]
+	if !ok {
+		return Item{}, fmt.Errorf("%s: %w", tag, ErrTagNotFound)
+	}
+	if io := ai.syncIO(); io != nil {
+		results, err := syncReadSource(io, src, ai.serverHandles(tag))
+		if err != nil {
+			return Item{}, fmt.Errorf("cannot read %s: %w", tag, err)
+		}
+		return results[tag].Item, results[tag].Err
+	}
+	item, err := ai.readFromSource(opcitem.dispatcher, src)
+	if err != nil {
+		return Item{}, fmt.Errorf("cannot read %s: %w", tag, err)
+	}
+	return item, nil
+}
+
+//readAll reads all added tags which are not write-only from src. It stops
+//early when ctx is done and reports if any tag could not be read.
+func (ai *AutomationItems) readAll(ctx context.Context, src ReadSource) (results map[string]ReadResult, failed bool) {
+	if io := ai.syncIO(); io != nil {
+		return ai.syncReadAll(io, src)
+	}
+	results = make(map[string]ReadResult)
+	for tag, opcitem := range ai.items {
+		if opcitem.writeOnly {
+			continue
+		}
+		if ctx.Err() != nil {
+			return results, failed
+		}
+		item, err := ai.readFromSource(opcitem.dispatcher, src)
+		if err != nil {
+			ai.log().Printf("Cannot read %s: %s.", tag, err)
+			results[tag] = ReadResult{Err: fmt.Errorf("cannot read %s: %w", tag, err)}
+			failed = true
+			continue
+		}
+		results[tag] = ReadResult{Item: item}
+	}
+	return results, failed
+}
+
+//syncReadAll reads all readable tags from src with a single SyncRead of the group.
+func (ai *AutomationItems) syncReadAll(io syncIO, src ReadSource) (results map[string]ReadResult, failed bool) {
+	handles := ai.serverHandles()
+	results, err := syncReadSource(io, src, handles)
+	if err != nil {
+		ai.log().Printf("Cannot read %d tags: %s.", len(handles), err)
+		results = make(map[string]ReadResult)
+		for tag := range handles {
+			results[tag] = ReadResult{Err: fmt.Errorf("cannot read %s: %w", tag, err)}
+		}
+		return results, true
+	}
+	for tag, result := range results {
+		if result.Err != nil {
+			ai.log().Printf("Cannot read %s: %s.", tag, result.Err)
+			failed = true
+		}
+	}
+	return results, failed
+}
+
+//write writes value to the tag. If tag is not added, it is added as write-only.
+func (ai *AutomationItems) write(tag string, value interface{}) error {
+	if err := ai.ensure(tag)[tag]; err != nil {
+		return err
+	}
+	if io := ai.syncIO(); io != nil {
+		failures, err := syncWriteTags(io, map[string]interface{}{tag: value}, ai.serverHandles(tag))
+		if err != nil {
+			return err
+		}
+		return failures[tag]
+	}
+	return ai.writeToOpc(ai.items[tag].dispatcher, value)
+}
+
+//readTags reads the tags from src. Tags which are not added yet are added
+//as write-only tags.
+func (ai *AutomationItems) readTags(tags []string, src ReadSource) map[string]ReadResult {
+	results := make(map[string]ReadResult)
+	for tag, err := range ai.ensure(tags...) {
+		results[tag] = ReadResult{Err: err}
+	}
+	handles := ai.serverHandles(tags...)
+	if io := ai.syncIO(); io != nil {
+		read, err := syncReadSource(io, src, handles)
+		for tag := range handles {
+			if err != nil {
+				results[tag] = ReadResult{Err: fmt.Errorf("cannot read %s: %w", tag, err)}
+				continue
+			}
+			results[tag] = read[tag]
+		}
+		return results
+	}
+	for tag := range handles {
+		item, err := ai.readFromSource(ai.items[tag].dispatcher, src)
+		if err != nil {
+			err = fmt.Errorf("cannot read %s: %w", tag, err)
+		}
+		results[tag] = ReadResult{Item: item, Err: err}
+	}
+	return results
+}
+
+//writeMany writes the values with a single SyncWrite of the group, or item by
+//item if the group is not known, and returns the errors of the failed tags.
+//Tags which are not added yet are added as write-only tags.
+func (ai *AutomationItems) writeMany(values map[string]interface{}) map[string]error {
+	tags := sortedKeys(values)
+	added := ai.ensure(tags...)
+	failures := make(map[string]error)
+	if io := ai.syncIO(); io != nil {
+		var err error
+		failures, err = syncWriteTags(io, values, ai.serverHandles(tags...))
+		if err != nil {
+			failures = make(map[string]error)
+			for _, tag := range tags {
+				failures[tag] = fmt.Errorf("cannot write %s: %w", tag, err)
+			}
+		}
+	} else {
+		for _, tag := range tags {
+			opcitem, ok := ai.items[tag]
+			if !ok {
+				continue
+			}
+			if err := ai.writeToOpc(opcitem.dispatcher, values[tag]); err != nil {
+				failures[tag] = fmt.Errorf("cannot write %s: %w", tag, err)
+			}
+		}
+	}
+	for tag, err := range added {
+		failures[tag] = err
+	}
+	return failures
+}
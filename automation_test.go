@@ -0,0 +1,457 @@
+package opcda
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestAutomationConnect(t *testing.T) {
+	server := newFakeServer(fakeNamespace())
+	ao := server.newAutomationObject(WithGroupName("plant"), WithUpdateRate(250*time.Millisecond), WithDeadBand(1.5), WithLocaleID(0x0409))
+	if ao.IsConnected() {
+		t.Fatal("server should not be connected yet")
+	}
+	items, err := ao.Connect("Fake.Simulator", "localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ao.IsConnected() {
+		t.Fatal("server should be connected")
+	}
+	if len(server.groups) != 1 {
+		t.Fatalf("expected one group, got %d", len(server.groups))
+	}
+	group := server.groups[0]
+	if group.prop("Name") != "plant" || group.prop("UpdateRate") != int32(250) || group.prop("DeadBand") != float32(1.5) {
+		t.Errorf("options should be applied to the group: %v", group.props)
+	}
+	if server.prop("LocaleID") != int32(0x0409) {
+		t.Errorf("locale should be set, got %v", server.prop("LocaleID"))
+	}
+	if items.group != group {
+		t.Error("items should keep the group")
+	}
+	if servers := ao.GetOPCServers("localhost"); !reflect.DeepEqual(servers, []string{"Fake.Simulator"}) {
+		t.Errorf("unexpected servers %v", servers)
+	}
+
+	items.Close()
+	ao.Close()
+	if !group.isReleased() || !server.isReleased() {
+		t.Error("group and server should be released")
+	}
+	if server.prop("ServerState") != int32(OPCDisconnected) {
+		t.Error("server should be disconnected on close")
+	}
+}
+
+func TestAutomationConnectOptionFails(t *testing.T) {
+	server := newFakeServer(fakeNamespace())
+	server.fail("LocaleID", OPCErrInvalidConfig)
+	ao := server.newAutomationObject(WithLocaleID(7))
+	if _, err := ao.Connect("Fake.Simulator", "localhost"); err == nil {
+		t.Fatal("a locale which cannot be set should fail the connect")
+	}
+	if !server.groups[0].isReleased() {
+		t.Error("the group should be released after a failed connect")
+	}
+}
+
+func TestAutomationTryConnect(t *testing.T) {
+	server := newFakeServer(fakeNamespace())
+	connect := server.methods["Connect"]
+	server.on("Connect", func(args ...interface{}) (interface{}, error) {
+		if args[1] == "down" {
+			return nil, errors.New("RPC server unavailable")
+		}
+		return connect(args...)
+	})
+	ao := server.newAutomationObject()
+
+	items, err := ao.TryConnect("Fake.Simulator", []string{"down", "localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	items.Close()
+	if server.prop("ServerNode") != "localhost" {
+		t.Errorf("expected the second node, got %v", server.prop("ServerNode"))
+	}
+
+	_, err = ao.TryConnect("Fake.Simulator", []string{"down", "down"})
+	var merr MultiError
+	if !errors.As(err, &merr) || len(merr) != 2 {
+		t.Fatalf("expected an error per node, got %v", err)
+	}
+	var cerr *ConnectError
+	if !errors.As(err, &cerr) || cerr.Node != "down" || !errors.Is(err, ErrNotConnected) {
+		t.Errorf("expected a ConnectError, got %v", err)
+	}
+}
+
+func TestAutomationItemsAddRemove(t *testing.T) {
+	server := newFakeServer(fakeNamespace())
+	ao := server.newAutomationObject()
+	items, err := ao.Connect("Fake.Simulator", "localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = items.Add("numeric.sin.float", "unknown", "textual.random")
+	var aerr *AddItemError
+	if !errors.As(err, &aerr) || aerr.Tag != "unknown" || aerr.HRESULT != OPCErrUnknownItemID {
+		t.Fatalf("expected an AddItemError for the unknown tag, got %v", err)
+	}
+	tags := items.tags()
+	sort.Strings(tags)
+	if !reflect.DeepEqual(tags, []string{"numeric.sin.float", "textual.random"}) {
+		t.Fatalf("unexpected tags %v", tags)
+	}
+	sin, random := items.items["numeric.sin.float"], items.items["textual.random"]
+	if sin.clientHandle == random.clientHandle || sin.serverHandle == random.serverHandle {
+		t.Error("handles should be unique")
+	}
+
+	if failures := items.ensure("numeric.saw.int64", "textual.random"); len(failures) != 0 {
+		t.Fatal(failures)
+	}
+	if !items.items["numeric.saw.int64"].writeOnly || items.items["textual.random"].writeOnly {
+		t.Error("only tags which were missing should be added as write-only")
+	}
+	if _, ok := items.serverHandles()["numeric.saw.int64"]; ok {
+		t.Error("write-only tags should not be read")
+	}
+
+	items.Remove("textual.random")
+	items.Remove("never.added")
+	if _, ok := items.items["textual.random"]; ok {
+		t.Error("tag should be removed")
+	}
+	if live := server.liveItems(); len(live) != 2 {
+		t.Errorf("removed item should be released, got %v", live)
+	}
+
+	items.Close()
+	items.Close()
+	if live := server.liveItems(); len(live) != 0 {
+		t.Errorf("all items should be released, got %v", live)
+	}
+	if err := items.Add("status"); err == nil {
+		t.Error("closed items cannot add tags")
+	}
+}
+
+func TestAutomationReconnect(t *testing.T) {
+	server := newFakeServer(fakeNamespace())
+	ao := server.newAutomationObject()
+	items, err := ao.Connect("Fake.Simulator", "localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	items.Add("numeric.sin.float")
+	items.ensure("textual.random")
+
+	same, err := ao.reconnect("Fake.Simulator", []string{"localhost"}, items)
+	if err != nil || same != items || server.called("Connect") != 1 {
+		t.Fatal("a connected server should not be reconnected")
+	}
+
+	server.crash()
+	server.fail("Connect", errors.New("RPC server unavailable"))
+	if _, err := ao.reconnect("Fake.Simulator", []string{"localhost"}, items); !errors.Is(err, ErrNotConnected) {
+		t.Fatalf("expected a connect error, got %v", err)
+	}
+	if len(items.items) != 2 {
+		t.Fatal("the items should be kept until the reconnect succeeds")
+	}
+
+	server.fail("Connect", nil)
+	fixed, err := ao.reconnect("Fake.Simulator", []string{"localhost"}, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fixed == items || !ao.IsConnected() {
+		t.Fatal("expected new items")
+	}
+	if len(fixed.items) != 2 || fixed.items["numeric.sin.float"].writeOnly || !fixed.items["textual.random"].writeOnly {
+		t.Errorf("tags should be added again with their access: %v", fixed.items)
+	}
+	if len(items.items) != 0 || !server.groups[0].isReleased() {
+		t.Error("the old items should be closed")
+	}
+	if live := server.liveItems(); len(live) != 2 {
+		t.Errorf("expected 2 items on the server, got %v", live)
+	}
+}
+
+func TestAutomationConnectTimeout(t *testing.T) {
+	server := newFakeServer(fakeNamespace())
+	hang := make(chan struct{})
+	server.on("Connect", func(args ...interface{}) (interface{}, error) {
+		<-hang
+		server.setProp("ServerState", int32(OPCRunning))
+		return nil, nil
+	})
+	ao := server.newAutomationObject()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	items, abandoned, err := ao.tryConnect(ctx, "Fake.Simulator", []string{"hung", "other"})
+	if items != nil || !abandoned || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("a hung attempt should be abandoned, got %v, %v", abandoned, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("the attempt should be abandoned at the deadline, took %s", elapsed)
+	}
+	var merr MultiError
+	if !errors.As(err, &merr) || len(merr) != 2 || server.called("Connect") != 1 {
+		t.Errorf("expected a ConnectError for both nodes and a single attempt, got %v", err)
+	}
+
+	close(hang)
+	deadline := time.Now().Add(5 * time.Second)
+	for !server.isReleased() {
+		if time.Now().After(deadline) {
+			t.Fatal("the abandoned connection should be closed when the attempt returns")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	server.mu.Lock()
+	groups := server.groups
+	server.mu.Unlock()
+	if len(groups) != 1 || !groups[0].isReleased() {
+		t.Error("the group of the abandoned attempt should be released")
+	}
+}
+
+//newFakeConnection connects to server with the tags and reconnects every 10
+//milliseconds.
+func newFakeConnection(t *testing.T, server *fakeServer, tags ...string) *opcConnectionImpl {
+	t.Helper()
+	ao := server.newAutomationObject(WithReconnectPolicy(ReconnectPolicy{Interval: 10 * time.Millisecond}))
+	items, err := ao.Connect("Fake.Simulator", "localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := items.Add(tags...); err != nil {
+		t.Fatal(err)
+	}
+	return newConnection(ao, items, "Fake.Simulator", []string{"localhost"}, ao.opts, newStateMachine())
+}
+
+func TestConnectionReadWrite(t *testing.T) {
+	server := newFakeServer(fakeNamespace())
+	server.setValue("numeric.sin.float", 1.5)
+	conn := newFakeConnection(t, server, "numeric.sin.float")
+	defer conn.Close()
+	if conn.State() != StateConnected {
+		t.Fatalf("unexpected state %s", conn.State())
+	}
+
+	item, err := conn.ReadItemContext(context.Background(), "numeric.sin.float")
+	if err != nil || item.Value != 1.5 || !item.Good() {
+		t.Fatalf("unexpected item %v, %v", item, err)
+	}
+	if _, err := conn.ReadItemContext(context.Background(), "status"); !errors.Is(err, ErrTagNotFound) {
+		t.Fatalf("expected ErrTagNotFound, got %v", err)
+	}
+
+	if err := conn.Write("textual.random", "text"); err != nil {
+		t.Fatal(err)
+	}
+	if server.value("textual.random") != "text" {
+		t.Fatal("value should be written to the server")
+	}
+	if items := conn.Read(); len(items) != 1 || items["numeric.sin.float"].Value != 1.5 {
+		t.Fatalf("write-only tags should not be read, got %v", items)
+	}
+	var aerr *AddItemError
+	if err := conn.Write("unknown", 1); !errors.As(err, &aerr) || !errors.Is(err, OPCErrUnknownItemID) {
+		t.Fatalf("expected an AddItemError, got %v", err)
+	}
+}
+
+func TestConnectionReconnect(t *testing.T) {
+	server := newFakeServer(fakeNamespace())
+	server.setValue("numeric.sin.float", 1.5)
+	conn := newFakeConnection(t, server, "numeric.sin.float")
+	defer conn.Close()
+	changes := conn.StateChanges()
+
+	server.crash()
+	server.fail("Connect", errors.New("RPC server unavailable"))
+	results, err := conn.ReadContext(context.Background())
+	if !errors.Is(err, ErrReconnecting) {
+		t.Fatalf("expected ErrReconnecting, got %v", err)
+	}
+	var hr HRESULT
+	if !errors.As(results["numeric.sin.float"].Err, &hr) || hr != testServerUnavailable {
+		t.Fatalf("the read should fail with the HRESULT of the server, got %v", results["numeric.sin.float"].Err)
+	}
+	if e := receive(t, changes); e.To != StateReconnecting {
+		t.Fatalf("expected Reconnecting, got %s", e)
+	}
+	if _, err := conn.ReadItemContext(context.Background(), "numeric.sin.float"); !errors.Is(err, ErrReconnecting) {
+		t.Fatalf("reads should fail while reconnecting, got %v", err)
+	}
+
+	//Tags runs while the reconnector replaces the items
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				conn.Tags()
+			}
+		}
+	}()
+	server.fail("Connect", nil)
+	e := receive(t, changes)
+	close(stop)
+	<-stopped
+	if e.To != StateConnected {
+		t.Fatalf("expected Connected, got %s", e)
+	}
+	if tags := conn.Tags(); !reflect.DeepEqual(tags, []string{"numeric.sin.float"}) {
+		t.Errorf("the tags should be kept after reconnect, got %v", tags)
+	}
+	item, err := conn.ReadItemContext(context.Background(), "numeric.sin.float")
+	if err != nil || item.Value != 1.5 {
+		t.Fatalf("unexpected item after reconnect %v, %v", item, err)
+	}
+	if !server.groups[0].isReleased() || len(server.groups) != 2 {
+		t.Error("fix should replace the group")
+	}
+	if live := server.liveItems(); !reflect.DeepEqual(live, []string{"numeric.sin.float"}) {
+		t.Errorf("the tags should be added again, got %v", live)
+	}
+
+	conn.Close()
+	if conn.State() != StateClosed || !server.isReleased() || len(server.liveItems()) != 0 {
+		t.Error("close should release the server and its items")
+	}
+}
+
+func TestAutomationCreateBrowser(t *testing.T) {
+	namespace := fakeNamespace()
+	server := newFakeServer(namespace)
+	ao := server.newAutomationObject()
+	if _, err := ao.CreateBrowser(); !errors.Is(err, ErrNotConnected) {
+		t.Fatalf("expected ErrNotConnected, got %v", err)
+	}
+	if _, err := ao.Connect("Fake.Simulator", "localhost"); err != nil {
+		t.Fatal(err)
+	}
+	tree, err := ao.CreateBrowser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, expected := CollectTags(tree), CollectTags(namespace); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected tags %v, got %v", expected, got)
+	}
+	deep := ExtractBranchByNames(tree, "textual", "nested")
+	if deep == nil || deep.Parent.Name != "textual" || deep.Leaves[0].ItemId != "textual.nested.deep" {
+		t.Errorf("unexpected branch %+v", deep)
+	}
+	if !server.browsers[0].isReleased() {
+		t.Error("browser should be released")
+	}
+
+	server.browsers = nil
+	server.on("CreateBrowser", func(args ...interface{}) (interface{}, error) {
+		b := server.newBrowser()
+		b.fail("GetItemID", OPCErrInvalidItemID)
+		return b, nil
+	})
+	if _, err := ao.CreateBrowser(); err == nil {
+		t.Error("a failing browser should fail instead of panic")
+	}
+}
+
+func TestBrowserImpl(t *testing.T) {
+	server := newFakeServer(fakeNamespace())
+	ao := server.newAutomationObject()
+	if _, err := ao.Connect("Fake.Simulator", "localhost"); err != nil {
+		t.Fatal(err)
+	}
+	b, err := newBrowser(ao, "Fake.Simulator", []string{"localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if branches := b.ShowBranches(); !reflect.DeepEqual(branches, []string{"numeric", "textual"}) {
+		t.Errorf("unexpected branches %v", branches)
+	}
+	b.MoveDown("textual")
+	b.MoveDown("nested")
+	if p := b.Position(); p != "textual.nested" {
+		t.Errorf("unexpected position %q", p)
+	}
+	if leaves := b.ShowLeafs(); !reflect.DeepEqual(leaves, []Leaf{{Name: "deep", ItemId: "textual.nested.deep"}}) {
+		t.Errorf("unexpected leaves %v", leaves)
+	}
+	b.MoveUp()
+	if p := b.Position(); p != "textual" {
+		t.Errorf("unexpected position %q", p)
+	}
+	b.MoveDown("missing")
+	if p := b.Position(); p != "textual" {
+		t.Errorf("a failed move should keep the position, got %q", p)
+	}
+	b.MoveTo("numeric")
+	if leaves := b.ShowLeafs(); len(leaves) != 2 || leaves[0].ItemId != "numeric.sin.float" {
+		t.Errorf("unexpected leaves %v", leaves)
+	}
+	b.MoveToRoot()
+	if p := b.Position(); p != "" {
+		t.Errorf("root should have an empty position, got %q", p)
+	}
+	if leaves := b.ShowLeafs(); len(leaves) != 1 || leaves[0].ItemId != "status" {
+		t.Errorf("unexpected leaves %v", leaves)
+	}
+
+	server.crash()
+	if len(b.ShowBranches()) != 0 || len(b.ShowLeafs()) != 0 || b.Position() != "" {
+		t.Error("a disconnected browser should be empty and keep its position")
+	}
+	b.Close()
+	if !server.browsers[0].isReleased() || !server.isReleased() {
+		t.Error("browser and server should be released")
+	}
+}
+
+func TestAutomationServerStatus(t *testing.T) {
+	server := newFakeServer(fakeNamespace())
+	start := time.Date(2019, 6, 21, 15, 0, 0, 0, time.UTC)
+	server.setProp("StartTime", start)
+	server.setProp("MajorVersion", int16(1))
+	ao := server.newAutomationObject()
+	if _, err := ao.Connect("Fake.Simulator", "localhost"); err != nil {
+		t.Fatal(err)
+	}
+	status, err := ao.ServerStatus()
+	if err == nil {
+		t.Fatal("missing properties should fail")
+	}
+	for _, name := range []string{"VendorInfo", "MinorVersion", "BuildNumber", "CurrentTime", "LastUpdateTime", "Bandwidth", "LocaleID"} {
+		server.setProp(name, nil)
+	}
+	server.fail("VendorInfo", testServerUnavailable)
+	var hr HRESULT
+	if _, err := ao.ServerStatus(); !errors.As(err, &hr) || hr != testServerUnavailable {
+		t.Fatalf("the error should wrap the HRESULT of the server, got %v", err)
+	}
+	server.fail("VendorInfo", nil)
+	status, err = ao.ServerStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.State != OPCRunning || status.ServerName != "Fake.Simulator" || status.ServerNode != "localhost" || !status.StartTime.Equal(start) || status.MajorVersion != 1 {
+		t.Errorf("unexpected status %+v", status)
+	}
+}
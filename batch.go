@@ -0,0 +1,132 @@
+package opcda
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+//syncIO transfers the values of several items of an OPC group in a single call
+//to SyncRead or SyncWrite of the group. The items are identified by their
+//server handles; the result slices are in the order of the handles and errs
+//holds the code returned for every item.
+type syncIO interface {
+	syncRead(source int32, handles []int32) (values []interface{}, qualities []Quality, timestamps []time.Time, errs []int32, err error)
+	syncWrite(handles []int32, values []interface{}) (errs []int32, err error)
+}
+
+//sortedTags returns the tags of handles in alphabetical order.
+func sortedTags(handles map[string]int32) []string {
+	tags := make([]string, 0, len(handles))
+	for tag := range handles {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+//syncReadTags reads the tags with their server handles in a single call and
+//maps the item errors back to the tags.
+func syncReadTags(io syncIO, source int32, handles map[string]int32) (map[string]ReadResult, error) {
+	results := make(map[string]ReadResult)
+	if len(handles) == 0 {
+		return results, nil
+	}
+	tags := sortedTags(handles)
+	serverHandles := make([]int32, len(tags))
+	for i, tag := range tags {
+		serverHandles[i] = handles[tag]
+	}
+
+	values, qualities, timestamps, errs, err := io.syncRead(source, serverHandles)
+	if err != nil {
+		return nil, err
+	}
+	n := len(tags)
+	if len(values) != n || len(qualities) != n || len(timestamps) != n || len(errs) != n {
+		return nil, errors.New("SyncRead returned arrays of unexpected length")
+	}
+
+	for i, tag := range tags {
+		if hresultFailed(errs[i]) {
+			results[tag] = ReadResult{Err: fmt.Errorf("cannot read %s: %w", tag, hresultError(errs[i]))}
+			continue
+		}
+		results[tag] = ReadResult{Item: Item{Value: values[i], Quality: qualities[i], Timestamp: timestamps[i]}}
+	}
+	return results, nil
+}
+
+//syncReadSource reads the tags from src. For a maximum age the tags are read
+//from the cache first and the tags with stale values again from the device.
+func syncReadSource(io syncIO, src ReadSource, handles map[string]int32) (map[string]ReadResult, error) {
+	if src.Source == OPCDevice || src.MaxAge == 0 {
+		return syncReadTags(io, src.Source, handles)
+	}
+	results, err := syncReadTags(io, OPCCache, handles)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	stale := make(map[string]int32)
+	for tag, result := range results {
+		if result.Err == nil && src.stale(result.Item, now) {
+			stale[tag] = handles[tag]
+		}
+	}
+	if len(stale) == 0 {
+		return results, nil
+	}
+	fresh, err := syncReadTags(io, OPCDevice, stale)
+	if err != nil {
+		for tag := range stale {
+			results[tag] = ReadResult{Err: fmt.Errorf("cannot read %s from device: %w", tag, err)}
+		}
+		return results, nil
+	}
+	for tag, result := range fresh {
+		results[tag] = result
+	}
+	return results, nil
+}
+
+//syncWriteTags writes the values to the tags with their server handles in a
+//single call and maps the item errors back to the tags. The returned map only
+//contains the tags which could not be written.
+func syncWriteTags(io syncIO, values map[string]interface{}, handles map[string]int32) (map[string]error, error) {
+	failures := make(map[string]error)
+	writeHandles := make(map[string]int32)
+	for tag := range values {
+		handle, ok := handles[tag]
+		if !ok {
+			failures[tag] = fmt.Errorf("%s: %w", tag, ErrTagNotFound)
+			continue
+		}
+		writeHandles[tag] = handle
+	}
+	if len(writeHandles) == 0 {
+		return failures, nil
+	}
+	tags := sortedTags(writeHandles)
+	serverHandles := make([]int32, len(tags))
+	writeValues := make([]interface{}, len(tags))
+	for i, tag := range tags {
+		serverHandles[i] = writeHandles[tag]
+		writeValues[i] = values[tag]
+	}
+
+	errs, err := io.syncWrite(serverHandles, writeValues)
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) != len(tags) {
+		return nil, errors.New("SyncWrite returned an array of unexpected length")
+	}
+	for i, tag := range tags {
+		if hresultFailed(errs[i]) {
+			failures[tag] = fmt.Errorf("cannot write %s: %w", tag, hresultError(errs[i]))
+		}
+	}
+	return failures, nil
+}
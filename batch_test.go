@@ -0,0 +1,225 @@
+package opcda
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+//fakeSyncIO emulates SyncRead and SyncWrite of an OPC group whose items
+//are identified by their server handles.
+//Values in the cache are age old, values from the device are new.
+type fakeSyncIO struct {
+	values  map[int32]interface{}
+	errs    map[int32]int32
+	age     map[int32]time.Duration
+	fail    error
+	calls   int
+	sources []int32
+}
+
+func (f *fakeSyncIO) syncRead(source int32, handles []int32) ([]interface{}, []Quality, []time.Time, []int32, error) {
+	f.calls++
+	f.sources = append(f.sources, source)
+	if f.fail != nil {
+		return nil, nil, nil, nil, f.fail
+	}
+	values := make([]interface{}, len(handles))
+	qualities := make([]Quality, len(handles))
+	timestamps := make([]time.Time, len(handles))
+	errs := make([]int32, len(handles))
+	for i, handle := range handles {
+		if code, ok := f.errs[handle]; ok {
+			errs[i] = code
+			continue
+		}
+		values[i] = f.values[handle]
+		qualities[i] = OPCQualityGood
+		timestamps[i] = time.Now()
+		if source == OPCCache {
+			timestamps[i] = timestamps[i].Add(-f.age[handle])
+		}
+	}
+	return values, qualities, timestamps, errs, nil
+}
+
+func (f *fakeSyncIO) syncWrite(handles []int32, values []interface{}) ([]int32, error) {
+	f.calls++
+	if f.fail != nil {
+		return nil, f.fail
+	}
+	errs := make([]int32, len(handles))
+	for i, handle := range handles {
+		if code, ok := f.errs[handle]; ok {
+			errs[i] = code
+			continue
+		}
+		f.values[handle] = values[i]
+	}
+	return errs, nil
+}
+
+// OPC_E_UNKNOWNITEMID and OPC_E_BADRIGHTS
+const (
+	testUnknownItemID int32 = -1073479673
+	testBadRights     int32 = -1073479674
+)
+
+func TestSyncReadTags(t *testing.T) {
+	io := &fakeSyncIO{
+		values: map[int32]interface{}{10: 1.5, 11: "text", 12: true},
+		errs:   map[int32]int32{13: testUnknownItemID},
+	}
+	handles := map[string]int32{"tag1": 10, "tag2": 11, "tag3": 12, "tag4": 13}
+
+	results, err := syncReadTags(io, OPCCache, handles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if io.calls != 1 {
+		t.Fatalf("all tags should be read with one call, got %d", io.calls)
+	}
+	if len(results) != 4 {
+		t.Fatalf("expected 4 results, got %d", len(results))
+	}
+	want := map[string]interface{}{"tag1": 1.5, "tag2": "text", "tag3": true}
+	for tag, value := range want {
+		if results[tag].Err != nil || results[tag].Value != value {
+			t.Errorf("%s: unexpected result %v", tag, results[tag])
+		}
+	}
+	if results["tag4"].Err == nil || !strings.Contains(results["tag4"].Err.Error(), "OPC_E_UNKNOWNITEMID") {
+		t.Errorf("tag4 should fail with OPC_E_UNKNOWNITEMID, got %v", results["tag4"].Err)
+	}
+}
+
+func TestSyncReadTagsCallFails(t *testing.T) {
+	io := &fakeSyncIO{fail: errors.New("server unavailable")}
+	_, err := syncReadTags(io, OPCCache, map[string]int32{"tag1": 1})
+	if err == nil {
+		t.Fatal("failed call should return an error")
+	}
+
+	results, err := syncReadTags(io, OPCCache, map[string]int32{})
+	if err != nil || len(results) != 0 || io.calls != 1 {
+		t.Fatal("reading no tags should not call the server")
+	}
+}
+
+func TestSyncReadSource(t *testing.T) {
+	handles := map[string]int32{"fresh": 1, "stale": 2}
+	newIO := func() *fakeSyncIO {
+		return &fakeSyncIO{
+			values: map[int32]interface{}{1: 1, 2: 2},
+			age:    map[int32]time.Duration{1: time.Second, 2: time.Hour},
+		}
+	}
+
+	io := newIO()
+	if _, err := syncReadSource(io, SourceDevice, handles); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(io.sources, []int32{OPCDevice}) {
+		t.Fatalf("device read should bypass the cache, got sources %v", io.sources)
+	}
+
+	io = newIO()
+	if _, err := syncReadSource(io, SourceCache, handles); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(io.sources, []int32{OPCCache}) {
+		t.Fatalf("cache read should accept any age, got sources %v", io.sources)
+	}
+
+	io = newIO()
+	results, err := syncReadSource(io, SourceMaxAge(time.Minute), handles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(io.sources, []int32{OPCCache, OPCDevice}) {
+		t.Fatalf("stale values should be read from the device, got sources %v", io.sources)
+	}
+	for tag, result := range results {
+		if result.Err != nil || time.Since(result.Timestamp) > time.Minute {
+			t.Errorf("%s: value is older than max age: %v", tag, result)
+		}
+	}
+	if time.Since(results["fresh"].Timestamp) < time.Second {
+		t.Error("fresh value should be taken from the cache")
+	}
+}
+
+//deviceFailsIO is a fakeSyncIO whose reads from the device fail with err.
+type deviceFailsIO struct {
+	*fakeSyncIO
+	err error
+}
+
+func (f deviceFailsIO) syncRead(source int32, handles []int32) ([]interface{}, []Quality, []time.Time, []int32, error) {
+	if source == OPCDevice {
+		return nil, nil, nil, nil, f.err
+	}
+	return f.fakeSyncIO.syncRead(source, handles)
+}
+
+func TestSyncReadSourceWrapsErrors(t *testing.T) {
+	unavailable := HRESULT(0x800706BA)
+	io := deviceFailsIO{
+		fakeSyncIO: &fakeSyncIO{
+			values: map[int32]interface{}{1: 1},
+			age:    map[int32]time.Duration{1: time.Hour},
+		},
+		err: unavailable,
+	}
+	results, err := syncReadSource(io, SourceMaxAge(time.Minute), map[string]int32{"stale": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hr HRESULT
+	if !errors.As(results["stale"].Err, &hr) || hr != unavailable {
+		t.Fatalf("the HRESULT of the failed device read should be kept, got %v", results["stale"].Err)
+	}
+}
+
+func TestSyncWriteTags(t *testing.T) {
+	io := &fakeSyncIO{
+		values: map[int32]interface{}{},
+		errs:   map[int32]int32{3: testBadRights},
+	}
+	handles := map[string]int32{"tag1": 1, "tag2": 2, "tag3": 3}
+	values := map[string]interface{}{"tag1": 1.0, "tag2": "on", "tag3": 3, "unknown": 4}
+
+	failures, err := syncWriteTags(io, values, handles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if io.calls != 1 {
+		t.Fatalf("all tags should be written with one call, got %d", io.calls)
+	}
+	if !reflect.DeepEqual(io.values, map[int32]interface{}{1: 1.0, 2: "on"}) {
+		t.Fatalf("unexpected values written %v", io.values)
+	}
+	if len(failures) != 2 {
+		t.Fatalf("expected 2 failures, got %v", failures)
+	}
+	if !errors.Is(failures["unknown"], ErrTagNotFound) {
+		t.Errorf("unknown tag should fail with ErrTagNotFound, got %v", failures["unknown"])
+	}
+	if !strings.Contains(failures["tag3"].Error(), "OPC_E_BADRIGHTS") {
+		t.Errorf("tag3 should fail with OPC_E_BADRIGHTS, got %v", failures["tag3"])
+	}
+}
+
+func TestHresultText(t *testing.T) {
+	if hresultFailed(0) || hresultFailed(0x0004000E) || !hresultFailed(testBadRights) {
+		t.Fatal("hresultFailed does not distinguish success and error codes")
+	}
+	if text := hresultText(testUnknownItemID); text != "OPC_E_UNKNOWNITEMID: the item ID is not defined in the address space of the server (0xC0040007)" {
+		t.Errorf("unexpected text %q", text)
+	}
+	if text := hresultText(-1); !strings.HasPrefix(text, "unknown error") {
+		t.Errorf("unexpected text %q", text)
+	}
+}
@@ -0,0 +1,108 @@
+//go:build windows
+// +build windows
+
+package opcda
+
+import (
+	"fmt"
+	"time"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+// groupSyncIO implements syncIO with SyncRead and SyncWrite of an OPCGroup.
+type groupSyncIO struct {
+	group *ole.IDispatch
+}
+
+// newGroupSyncIO returns the syncIO of the COM object of group, or nil if
+// group has none.
+func newGroupSyncIO(group dispatcher) syncIO {
+	if d := oleDispatch(group); d != nil {
+		return groupSyncIO{d}
+	}
+	return nil
+}
+
+// syncRead calls SyncRead(Source, NumItems, ServerHandles, Values, Errors, Qualities, TimeStamps).
+func (g groupSyncIO) syncRead(source int32, handles []int32) ([]interface{}, []Quality, []time.Time, []int32, error) {
+	serverHandles, err := safeArrayFromInt32s(handles)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	defer destroySafeArray(serverHandles)
+
+	var values, errs *ole.SafeArray
+	var qualities, timestamps ole.VARIANT
+	ole.VariantInit(&qualities)
+	ole.VariantInit(&timestamps)
+	_, err = invokeMethod(g.group, "SyncRead",
+		ole.NewVariant(ole.VT_I2, int64(source)),
+		ole.NewVariant(ole.VT_I4, int64(len(handles))),
+		byrefArray(ole.VT_I4, &serverHandles),
+		byrefArray(ole.VT_VARIANT, &values),
+		byrefArray(ole.VT_I4, &errs),
+		byrefVariant(&qualities),
+		byrefVariant(&timestamps),
+	)
+	defer destroySafeArray(values)
+	defer destroySafeArray(errs)
+	defer ole.VariantClear(&qualities)
+	defer ole.VariantClear(&timestamps)
+	if err != nil {
+		return nil, nil, nil, nil, refineOleError(err)
+	}
+
+	itemErrs, err := int32sFromSafeArray(errs)
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("cannot decode SyncRead errors: %w", err)
+	}
+	itemValues, err := valuesFromSafeArray(values)
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("cannot decode SyncRead values: %w", err)
+	}
+	itemQualities, err := int32sFromVariant(&qualities)
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("cannot decode SyncRead qualities: %w", err)
+	}
+	itemTimestamps, err := timesFromVariant(&timestamps)
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("cannot decode SyncRead timestamps: %w", err)
+	}
+	qualities16 := make([]Quality, len(itemQualities))
+	for i, q := range itemQualities {
+		qualities16[i] = Quality(ensureInt16(q))
+	}
+	return itemValues, qualities16, itemTimestamps, itemErrs, nil
+}
+
+// syncWrite calls SyncWrite(NumItems, ServerHandles, Values, Errors).
+func (g groupSyncIO) syncWrite(handles []int32, values []interface{}) ([]int32, error) {
+	serverHandles, err := safeArrayFromInt32s(handles)
+	if err != nil {
+		return nil, err
+	}
+	defer destroySafeArray(serverHandles)
+	writeValues, err := safeArrayFromValues(values)
+	if err != nil {
+		return nil, err
+	}
+	defer destroySafeArray(writeValues)
+
+	var errs *ole.SafeArray
+	_, err = invokeMethod(g.group, "SyncWrite",
+		ole.NewVariant(ole.VT_I4, int64(len(handles))),
+		byrefArray(ole.VT_I4, &serverHandles),
+		byrefArray(ole.VT_VARIANT, &writeValues),
+		byrefArray(ole.VT_I4, &errs),
+	)
+	defer destroySafeArray(errs)
+	if err != nil {
+		return nil, refineOleError(err)
+	}
+	itemErrs, err := int32sFromSafeArray(errs)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode SyncWrite errors: %w", err)
+	}
+	return itemErrs, nil
+}
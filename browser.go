@@ -0,0 +1,154 @@
+package opcda
+
+import (
+	"fmt"
+	"sync"
+)
+
+//browserItems returns the names in the current collection of the OPCBrowser,
+//which is filled by ShowBranches or ShowLeafs.
+func browserItems(browser dispatcher) ([]string, error) {
+	count, err := getInt32(browser, "Count")
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, count)
+	for i := 1; i <= int(count); i++ {
+		name, err := callString(browser, "Item", i)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+//showBranches returns the names of the branches at the current position.
+func showBranches(browser dispatcher) ([]string, error) {
+	if _, err := browser.call("ShowBranches"); err != nil {
+		return nil, refineOleError(err)
+	}
+	return browserItems(browser)
+}
+
+//showLeafs returns the leaves at the current position with their item IDs.
+func showLeafs(browser dispatcher) ([]Leaf, error) {
+	if _, err := browser.call("ShowLeafs"); err != nil {
+		return nil, refineOleError(err)
+	}
+	names, err := browserItems(browser)
+	if err != nil {
+		return nil, err
+	}
+	leaves := make([]Leaf, 0, len(names))
+	for _, name := range names {
+		id, err := callString(browser, "GetItemID", name)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get item ID of %s: %w", name, refineOleError(err))
+		}
+		leaves = append(leaves, Leaf{Name: name, ItemId: id})
+	}
+	return leaves, nil
+}
+
+//browserImpl implements Browser with an OPCBrowser of the server.
+//Failed calls are logged and leave the position unchanged.
+type browserImpl struct {
+	*AutomationObject
+	Server   string
+	Nodes    []string
+	mu       sync.Mutex
+	position string
+	browser  dispatcher
+}
+
+//newBrowser creates an OPCBrowser of the connected object and moves it to the root.
+func newBrowser(object *AutomationObject, server string, nodes []string) (*browserImpl, error) {
+	browser, err := callObject(object.opc, "CreateBrowser")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OPCBrowser: %w", refineOleError(err))
+	}
+	if _, err := browser.call("MoveToRoot"); err != nil {
+		browser.release()
+		return nil, fmt.Errorf("cannot move to root: %w", refineOleError(err))
+	}
+	return &browserImpl{AutomationObject: object, Server: server, Nodes: nodes, browser: browser}, nil
+}
+
+//move calls method of the OPCBrowser if the server is connected.
+func (b *browserImpl) move(method string, args ...interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.IsConnected() {
+		return
+	}
+	if _, err := b.browser.call(method, args...); err != nil {
+		b.log().Printf("Cannot %s: %s", method, refineOleError(err))
+	}
+}
+
+func (b *browserImpl) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.browser != nil {
+		b.browser.release()
+		b.browser = nil
+	}
+	b.AutomationObject.Close()
+}
+
+func (b *browserImpl) MoveTo(branches ...string) {
+	b.move("MoveTo", branches)
+}
+
+func (b *browserImpl) MoveToRoot() {
+	b.move("MoveToRoot")
+}
+
+func (b *browserImpl) MoveUp() {
+	b.move("MoveUp")
+}
+
+func (b *browserImpl) MoveDown(branch string) {
+	b.move("MoveDown", branch)
+}
+
+func (b *browserImpl) Position() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.IsConnected() {
+		p, err := b.browser.get("CurrentPosition")
+		if s, ok := p.(string); err == nil && ok {
+			b.position = s
+		}
+	}
+	return b.position
+}
+
+func (b *browserImpl) ShowBranches() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.IsConnected() {
+		return []string{}
+	}
+	branches, err := showBranches(b.browser)
+	if err != nil {
+		b.log().Println("Cannot show branches:", err)
+		return []string{}
+	}
+	return branches
+}
+
+func (b *browserImpl) ShowLeafs() []Leaf {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.IsConnected() {
+		return []Leaf{}
+	}
+	leaves, err := showLeafs(b.browser)
+	if err != nil {
+		b.log().Println("Cannot show leafs:", err)
+		return []Leaf{}
+	}
+	return leaves
+}
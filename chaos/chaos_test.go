@@ -0,0 +1,285 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/rxue92/opcda"
+	"github.com/rxue92/opcda/opcdatest"
+	"github.com/rxue92/opcda/simulator"
+)
+
+//testClock is a clock which only moves when told.
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func (c *testClock) Add(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+//newTestConnection wraps a simulator with the tags a, b and c.
+func newTestConnection(t *testing.T, faults ...Fault) (*Connection, *testClock) {
+	clock := &testClock{now: time.Unix(1000, 0)}
+	sim, err := simulator.New([]simulator.Tag{
+		{Name: "a", Waveform: simulator.Constant{Value: 1.0}},
+		{Name: "b", Waveform: simulator.Constant{Value: 2.0}},
+		{Name: "c", Writable: true},
+	}, simulator.WithClock(clock.Now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := sim.Connect("a", "b", "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Wrap(conn, faults, WithClock(clock.Now), WithSeed(1)), clock
+}
+
+func TestTrigger(t *testing.T) {
+	second := time.Second
+	tests := []struct {
+		trigger Trigger
+		at      time.Duration
+		want    bool
+	}{
+		{Trigger{}, time.Hour, true},
+		{Trigger{After: second}, 0, false},
+		{Trigger{After: second}, second, true},
+		{Trigger{After: second, For: second}, 2 * second, false},
+		{Trigger{After: second, For: second, Every: 10 * second}, 11 * second, true},
+		{Trigger{After: second, For: second, Every: 10 * second}, 12 * second, false},
+	}
+	for _, test := range tests {
+		if got := test.trigger.scheduled(test.at); got != test.want {
+			t.Errorf("%+v at %s: expected %v", test.trigger, test.at, test.want)
+		}
+	}
+	if !(Trigger{Tags: []string{"a"}}).applies("a") || (Trigger{Tags: []string{"a"}}).applies("b") || !(Trigger{}).applies("b") {
+		t.Error("tags should limit the trigger")
+	}
+}
+
+func TestDisconnect(t *testing.T) {
+	conn, clock := newTestConnection(t, Disconnect(Trigger{After: time.Minute, For: time.Minute}))
+	if !conn.IsConnected() || len(conn.Read()) != 3 {
+		t.Fatal("connection should work before the disconnect")
+	}
+	clock.Add(time.Minute)
+	if conn.IsConnected() {
+		t.Error("IsConnected should flip during the disconnect")
+	}
+	if _, err := conn.ReadContext(context.Background()); !errors.Is(err, opcda.ErrNotConnected) {
+		t.Errorf("expected ErrNotConnected, got %v", err)
+	}
+	if err := conn.Write("c", 1.0); !errors.Is(err, opcda.ErrNotConnected) {
+		t.Errorf("expected ErrNotConnected, got %v", err)
+	}
+	if err := conn.Add("a"); !errors.Is(err, opcda.ErrNotConnected) {
+		t.Errorf("expected ErrNotConnected, got %v", err)
+	}
+	clock.Add(time.Minute)
+	if !conn.IsConnected() || conn.ReadItem("a").Value != 1.0 {
+		t.Error("connection should be back after the disconnect")
+	}
+}
+
+func TestDisconnectProbability(t *testing.T) {
+	for seed := int64(1); seed <= 20; seed++ {
+		clock := &testClock{now: time.Unix(1000, 0)}
+		sim, _ := simulator.New([]simulator.Tag{{Name: "a"}}, simulator.WithClock(clock.Now))
+		conn, _ := sim.Connect("a")
+		c := Wrap(conn, []Fault{Disconnect(Trigger{Every: time.Minute, For: 30 * time.Second, Probability: 0.5, Tags: []string{"b"}})}, WithClock(clock.Now), WithSeed(seed))
+		var periods []bool
+		for period := 0; period < 4; period++ {
+			_, err := c.ReadItemContext(context.Background(), "a")
+			down := errors.Is(err, opcda.ErrNotConnected)
+			for i := 0; i < 10; i++ {
+				if c.IsConnected() == down {
+					t.Fatalf("seed %d: IsConnected should agree with the read in period %d", seed, period)
+				}
+			}
+			periods = append(periods, down)
+			clock.Add(time.Minute)
+		}
+
+		clock = &testClock{now: time.Unix(1000, 0)}
+		sim, _ = simulator.New([]simulator.Tag{{Name: "a"}}, simulator.WithClock(clock.Now))
+		conn, _ = sim.Connect("a")
+		c = Wrap(conn, []Fault{Disconnect(Trigger{Every: time.Minute, For: 30 * time.Second, Probability: 0.5})}, WithClock(clock.Now), WithSeed(seed))
+		for period, down := range periods {
+			if c.IsConnected() == down {
+				t.Fatalf("seed %d: period %d should be repeated by the seed", seed, period)
+			}
+			clock.Add(time.Minute)
+		}
+	}
+}
+
+func TestTagFaults(t *testing.T) {
+	only := Trigger{Tags: []string{"a"}}
+	conn, _ := newTestConnection(t, ReadError(nil, only), RejectWrites(nil, Trigger{Tags: []string{"c"}}), Latency(Fixed(time.Second), only))
+	var slept time.Duration
+	conn.sleep = func(ctx context.Context, d time.Duration) error {
+		slept += d
+		return nil
+	}
+	if _, err := conn.ReadItemContext(context.Background(), "b"); err != nil || slept != 0 {
+		t.Errorf("faults of a should not hit b, got %v after %s", err, slept)
+	}
+	if _, err := conn.ReadItemContext(context.Background(), "a"); !errors.Is(err, ErrInjected) || slept != time.Second {
+		t.Errorf("faults of a should hit a, got %v after %s", err, slept)
+	}
+	if _, err := conn.ReadContext(context.Background()); !errors.Is(err, ErrInjected) {
+		t.Errorf("faults of a should hit the read of all tags, got %v", err)
+	}
+	conn.Remove("a")
+	if _, err := conn.ReadContext(context.Background()); err != nil {
+		t.Errorf("faults of a should not hit a read without a, got %v", err)
+	}
+	if err := conn.Write("c", 1.0); !errors.Is(err, ErrInjected) {
+		t.Errorf("write to c should be rejected, got %v", err)
+	}
+	slept = 0
+	if err := conn.Add("b"); err != nil || slept != 0 {
+		t.Errorf("latency of a should not delay adding b, got %v after %s", err, slept)
+	}
+}
+
+func TestReadFaults(t *testing.T) {
+	conn, clock := newTestConnection(t,
+		ReadError(opcda.OPCErrBadType, Trigger{After: time.Minute, For: time.Second}),
+		ItemError(nil, Trigger{Tags: []string{"a"}}),
+		Quality(opcda.OPCQualityUncertain, Trigger{Tags: []string{"b"}}),
+		DropTags(Trigger{Tags: []string{"c"}}),
+	)
+	results, err := conn.ReadContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Errorf("c should be dropped, got %v", results)
+	}
+	if !errors.Is(results["a"].Err, ErrInjected) {
+		t.Errorf("a should fail, got %v", results["a"])
+	}
+	if results["b"].Value != 2.0 || !results["b"].Quality.IsUncertain() {
+		t.Errorf("b should be uncertain, got %v", results["b"])
+	}
+	if _, err := conn.ReadItemContext(context.Background(), "c"); !errors.Is(err, opcda.ErrTagNotFound) {
+		t.Errorf("dropped tag should not be found, got %v", err)
+	}
+	if _, err := conn.ReadItemContext(context.Background(), "a"); !errors.Is(err, ErrInjected) {
+		t.Errorf("expected injected error, got %v", err)
+	}
+
+	clock.Add(time.Minute)
+	if _, err := conn.ReadContext(context.Background()); !errors.Is(err, opcda.OPCErrBadType) {
+		t.Errorf("expected read error, got %v", err)
+	}
+	if _, err := conn.ReadItemContext(context.Background(), "b"); !errors.Is(err, opcda.OPCErrBadType) {
+		t.Errorf("expected read error, got %v", err)
+	}
+
+	conn.SetFaults()
+	if items := conn.Read(); len(items) != 3 || !items["b"].Quality.IsGood() {
+		t.Errorf("no faults should be left, got %v", items)
+	}
+}
+
+func TestFreezeTimestamps(t *testing.T) {
+	conn, clock := newTestConnection(t, FreezeTimestamps(Trigger{For: time.Minute}))
+	frozen := conn.ReadItem("a").Timestamp
+	clock.Add(30 * time.Second)
+	if ts := conn.ReadItem("a").Timestamp; !ts.Equal(frozen) {
+		t.Errorf("timestamp should be frozen at %s, got %s", frozen, ts)
+	}
+	clock.Add(time.Minute)
+	if ts := conn.ReadItem("a").Timestamp; !ts.Equal(clock.now) {
+		t.Errorf("timestamp should move again, got %s", ts)
+	}
+}
+
+func TestRejectWrites(t *testing.T) {
+	conn, _ := newTestConnection(t, RejectWrites(opcda.OPCErrBadRights, Trigger{Probability: 0.5}))
+	rejected := 0
+	for i := 0; i < 100; i++ {
+		if err := conn.Write("c", float64(i)); errors.Is(err, opcda.OPCErrBadRights) {
+			rejected++
+		}
+	}
+	if rejected < 30 || rejected > 70 {
+		t.Errorf("expected about half of the writes to be rejected, got %d", rejected)
+	}
+}
+
+func TestLatency(t *testing.T) {
+	conn, _ := newTestConnection(t, Latency(Fixed(time.Second), Trigger{}), Latency(Fixed(time.Second), Trigger{After: time.Hour}))
+	var slept time.Duration
+	conn.sleep = func(ctx context.Context, d time.Duration) error {
+		slept += d
+		return nil
+	}
+	conn.ReadItem("a")
+	conn.Write("c", 1.0)
+	if slept != 2*time.Second {
+		t.Errorf("expected 2s of latency, got %s", slept)
+	}
+
+	conn.SetFaults(Latency(Fixed(time.Hour), Trigger{}))
+	conn.sleep = sleep
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if _, err := conn.ReadContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("latency should honor the context, got %v", err)
+	}
+}
+
+func TestDistributions(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for name, d := range map[string]Distribution{
+		"fixed":       Fixed(time.Second),
+		"uniform":     Uniform(500*time.Millisecond, 1500*time.Millisecond),
+		"normal":      Normal(time.Second, 100*time.Millisecond),
+		"exponential": Exponential(time.Second),
+	} {
+		var sum time.Duration
+		for i := 0; i < 1000; i++ {
+			v := d(r)
+			if v < 0 {
+				t.Fatalf("%s: negative latency %s", name, v)
+			}
+			sum += v
+		}
+		if mean := sum / 1000; mean < 900*time.Millisecond || mean > 1100*time.Millisecond {
+			t.Errorf("%s: expected a mean of 1s, got %s", name, mean)
+		}
+	}
+}
+
+func TestConformance(t *testing.T) {
+	sim, err := simulator.New([]simulator.Tag{
+		{Name: "a", Waveform: simulator.Constant{Value: 1.0}},
+		{Name: "b", Waveform: simulator.Constant{Value: 2.0}},
+		{Name: "c", Writable: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opcdatest.TestConnection(t, opcdatest.ConnectionConfig{
+		New: func() (opcda.Connection, error) {
+			conn, err := sim.Connect()
+			return Wrap(conn, []Fault{Latency(Fixed(0), Trigger{}), Disconnect(Trigger{After: time.Hour})}), err
+		},
+		Tags:    []string{"a", "b"},
+		Missing: "missing",
+		Values:  map[string]interface{}{"c": 1.5},
+	})
+}
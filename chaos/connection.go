@@ -0,0 +1,305 @@
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/rxue92/opcda"
+)
+
+//Option configures a Connection.
+type Option func(*Connection)
+
+//WithSeed seeds the random numbers of the probabilities and latencies, so
+//that a run can be repeated.
+func WithSeed(seed int64) Option {
+	return func(c *Connection) {
+		c.rand = rand.New(rand.NewSource(seed))
+	}
+}
+
+//WithClock makes the triggers take the time from now instead of time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(c *Connection) {
+		c.now = now
+	}
+}
+
+//Connection is a Connection with injected faults. The schedules of the
+//triggers start when the connection is wrapped.
+type Connection struct {
+	conn  opcda.Connection
+	now   func() time.Time
+	sleep func(context.Context, time.Duration) error
+	start time.Time
+
+	mu     sync.Mutex
+	rand   *rand.Rand
+	faults []Fault
+	frozen map[string]time.Time
+	down   map[int]latch //decisions of the disconnects by index in faults
+}
+
+//latch is the decision of a probabilistic disconnect for a period of its
+//schedule.
+type latch struct {
+	period int64
+	hit    bool
+}
+
+//Wrap returns conn with the faults injected.
+func Wrap(conn opcda.Connection, faults []Fault, opts ...Option) *Connection {
+	c := &Connection{
+		conn:   conn,
+		now:    time.Now,
+		sleep:  sleep,
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+		faults: faults,
+		frozen: make(map[string]time.Time),
+		down:   make(map[int]latch),
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(c)
+		}
+	}
+	c.start = c.now()
+	return c
+}
+
+//sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+//SetFaults replaces the faults. Their schedules keep the start of the
+//connection.
+func (c *Connection) SetFaults(faults ...Fault) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.faults = faults
+	c.down = make(map[int]latch)
+}
+
+//active returns the scheduled faults of kind. It must be called with the
+//lock held.
+func (c *Connection) active(k kind) []Fault {
+	elapsed := c.now().Sub(c.start)
+	var faults []Fault
+	for _, f := range c.faults {
+		if f.kind == k && f.when.scheduled(elapsed) {
+			faults = append(faults, f)
+		}
+	}
+	return faults
+}
+
+//hit returns the first active fault of kind which hits a call for the tags
+//or a single tag. It must be called with the lock held.
+func (c *Connection) hit(k kind, tags ...string) (Fault, bool) {
+	for _, f := range c.active(k) {
+		if f.when.applies(tags...) && f.when.hits(c.rand.Float64) {
+			return f, true
+		}
+	}
+	return Fault{}, false
+}
+
+//disconnected reports if an active disconnect takes the connection down.
+//The decision is latched for the period of the schedule, so that the calls
+//and IsConnected agree while it lasts. It must be called with the lock held.
+func (c *Connection) disconnected() bool {
+	elapsed := c.now().Sub(c.start)
+	down := false
+	for i, f := range c.faults {
+		if f.kind != kindDisconnect || !f.when.scheduled(elapsed) {
+			continue
+		}
+		period := f.when.period(elapsed)
+		l, ok := c.down[i]
+		if !ok || l.period != period {
+			l = latch{period: period, hit: f.when.hits(c.rand.Float64)}
+			c.down[i] = l
+		}
+		down = down || l.hit
+	}
+	return down
+}
+
+//enter injects the disconnects and the latency of a call for the tags.
+func (c *Connection) enter(ctx context.Context, tags ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	disconnected := c.disconnected()
+	var delay time.Duration
+	for _, f := range c.active(kindLatency) {
+		if f.when.applies(tags...) && f.when.hits(c.rand.Float64) {
+			delay += f.latency(c.rand)
+		}
+	}
+	c.mu.Unlock()
+	if disconnected {
+		return opcda.ErrNotConnected
+	}
+	return c.sleep(ctx, delay)
+}
+
+//item injects the faults of a tag into its result and reports if the tag is
+//dropped. It must be called with the lock held.
+func (c *Connection) item(tag string, r opcda.ReadResult) (opcda.ReadResult, bool) {
+	if _, ok := c.hit(kindDrop, tag); ok {
+		return r, false
+	}
+	if f, ok := c.hit(kindItemError, tag); ok {
+		return opcda.ReadResult{Err: fmt.Errorf("cannot read %s: %w", tag, f.err)}, true
+	}
+	if r.Err != nil {
+		return r, true
+	}
+	if f, ok := c.hit(kindQuality, tag); ok {
+		r.Quality = f.quality
+	}
+	if _, ok := c.hit(kindFreeze, tag); ok {
+		if ts, ok := c.frozen[tag]; ok {
+			r.Timestamp = ts
+		} else {
+			c.frozen[tag] = r.Timestamp
+		}
+	} else {
+		delete(c.frozen, tag)
+	}
+	return r, true
+}
+
+//Add adds the tags.
+func (c *Connection) Add(tags ...string) error {
+	return c.AddContext(context.Background(), tags...)
+}
+
+//AddContext adds the tags unless a fault is injected.
+func (c *Connection) AddContext(ctx context.Context, tags ...string) error {
+	if err := c.enter(ctx, tags...); err != nil {
+		return err
+	}
+	return c.conn.AddContext(ctx, tags...)
+}
+
+//Remove removes the tag.
+func (c *Connection) Remove(tag string) {
+	c.conn.Remove(tag)
+}
+
+//Read returns the tags which were read successfully.
+func (c *Connection) Read() map[string]opcda.Item {
+	results, err := c.ReadContext(context.Background())
+	if err != nil {
+		return map[string]opcda.Item{}
+	}
+	return opcda.Items(results)
+}
+
+//ReadContext reads the tags and injects the faults into the results.
+func (c *Connection) ReadContext(ctx context.Context) (map[string]opcda.ReadResult, error) {
+	tags := c.conn.Tags()
+	if err := c.enter(ctx, tags...); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	f, failed := c.hit(kindReadError, tags...)
+	c.mu.Unlock()
+	if failed {
+		return nil, f.err
+	}
+	results, err := c.conn.ReadContext(ctx)
+	if err != nil {
+		return results, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	faulty := make(map[string]opcda.ReadResult, len(results))
+	for tag, r := range results {
+		if r, ok := c.item(tag, r); ok {
+			faulty[tag] = r
+		}
+	}
+	return faulty, nil
+}
+
+//ReadItem reads the tag and returns an empty Item if it fails.
+func (c *Connection) ReadItem(tag string) opcda.Item {
+	item, _ := c.ReadItemContext(context.Background(), tag)
+	return item
+}
+
+//ReadItemContext reads the tag and injects the faults into the item.
+func (c *Connection) ReadItemContext(ctx context.Context, tag string) (opcda.Item, error) {
+	if err := c.enter(ctx, tag); err != nil {
+		return opcda.Item{}, err
+	}
+	c.mu.Lock()
+	f, failed := c.hit(kindReadError, tag)
+	c.mu.Unlock()
+	if failed {
+		return opcda.Item{}, f.err
+	}
+	item, err := c.conn.ReadItemContext(ctx, tag)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, ok := c.item(tag, opcda.ReadResult{Item: item, Err: err})
+	if !ok {
+		return opcda.Item{}, fmt.Errorf("%s: %w", tag, opcda.ErrTagNotFound)
+	}
+	return r.Item, r.Err
+}
+
+//Tags returns the tags of the connection.
+func (c *Connection) Tags() []string {
+	return c.conn.Tags()
+}
+
+//Write writes value to the tag.
+func (c *Connection) Write(tag string, value interface{}) error {
+	return c.WriteContext(context.Background(), tag, value)
+}
+
+//WriteContext writes value to the tag unless a fault is injected.
+func (c *Connection) WriteContext(ctx context.Context, tag string, value interface{}) error {
+	if err := c.enter(ctx, tag); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	f, rejected := c.hit(kindRejectWrite, tag)
+	c.mu.Unlock()
+	if rejected {
+		return fmt.Errorf("cannot write %s: %w", tag, f.err)
+	}
+	return c.conn.WriteContext(ctx, tag, value)
+}
+
+//IsConnected reports false while a disconnect is injected.
+func (c *Connection) IsConnected() bool {
+	c.mu.Lock()
+	disconnected := c.disconnected()
+	c.mu.Unlock()
+	return !disconnected && c.conn.IsConnected()
+}
+
+//Close closes the connection.
+func (c *Connection) Close() {
+	c.conn.Close()
+}
@@ -0,0 +1,190 @@
+//Package chaos wraps an opcda.Connection to inject faults: latency, failed
+//reads, bad qualities, frozen timestamps, dropped tags, rejected writes and
+//disconnects, on a schedule or by chance.
+//
+//	conn := chaos.Wrap(conn, []chaos.Fault{
+//		chaos.Latency(chaos.Uniform(10*time.Millisecond, 200*time.Millisecond), chaos.Trigger{}),
+//		chaos.ReadError(nil, chaos.Trigger{Probability: 0.01}),
+//		chaos.Quality(opcda.OPCQualityUncertain, chaos.Trigger{Tags: []string{"line.speed"}, Every: time.Minute, For: 10 * time.Second}),
+//		chaos.Disconnect(chaos.Trigger{After: 5 * time.Minute, For: 30 * time.Second}),
+//	})
+package chaos
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/rxue92/opcda"
+)
+
+//ErrInjected is the error of faults created without an error.
+var ErrInjected = errors.New("injected fault")
+
+//Trigger decides when a fault is active. The zero Trigger is always active.
+//The fault starts After the connection was wrapped and lasts For, or forever
+//if For is zero. If Every is positive it repeats in every period. While it is
+//active, a Probability between 0 and 1 makes it hit only that fraction of
+//the calls, or of the tags for the faults of tags. Tags limits the fault to
+//these tags: the faults of calls only hit the calls for one of them, and a
+//read of all tags if one of them is added.
+type Trigger struct {
+	After       time.Duration
+	For         time.Duration
+	Every       time.Duration
+	Probability float64
+	Tags        []string
+}
+
+//scheduled reports if the fault is active at elapsed.
+func (t Trigger) scheduled(elapsed time.Duration) bool {
+	if elapsed < t.After {
+		return false
+	}
+	since := elapsed - t.After
+	if t.Every > 0 {
+		since %= t.Every
+	}
+	return t.For == 0 || since < t.For
+}
+
+//hits reports if the fault hits a call or a tag. random returns a number in
+//[0, 1).
+func (t Trigger) hits(random func() float64) bool {
+	return t.Probability == 0 || random() < t.Probability
+}
+
+//period returns the number of the period of the schedule at elapsed.
+func (t Trigger) period(elapsed time.Duration) int64 {
+	if t.Every <= 0 || elapsed < t.After {
+		return 0
+	}
+	return int64((elapsed - t.After) / t.Every)
+}
+
+//applies reports if the fault is for one of the tags.
+func (t Trigger) applies(tags ...string) bool {
+	if len(t.Tags) == 0 {
+		return true
+	}
+	for _, name := range t.Tags {
+		for _, tag := range tags {
+			if name == tag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type kind int
+
+const (
+	kindLatency kind = iota
+	kindReadError
+	kindItemError
+	kindQuality
+	kindFreeze
+	kindDrop
+	kindRejectWrite
+	kindDisconnect
+)
+
+//Fault is a fault injected into the calls of a Connection while its trigger
+//is active.
+type Fault struct {
+	kind    kind
+	when    Trigger
+	latency Distribution
+	err     error
+	quality opcda.Quality
+}
+
+//orInjected returns err, or ErrInjected if it is nil.
+func orInjected(err error) error {
+	if err == nil {
+		return ErrInjected
+	}
+	return err
+}
+
+//Latency delays the adds, reads and writes by a duration drawn from d.
+func Latency(d Distribution, when Trigger) Fault {
+	return Fault{kind: kindLatency, when: when, latency: d}
+}
+
+//ReadError makes the reads fail as a whole with err, or ErrInjected.
+func ReadError(err error, when Trigger) Fault {
+	return Fault{kind: kindReadError, when: when, err: orInjected(err)}
+}
+
+//ItemError makes the reads of single tags fail with err, or ErrInjected.
+func ItemError(err error, when Trigger) Fault {
+	return Fault{kind: kindItemError, when: when, err: orInjected(err)}
+}
+
+//Quality replaces the quality of the items read, e.g. with
+//opcda.OPCQualityBad or opcda.OPCQualityUncertain.
+func Quality(q opcda.Quality, when Trigger) Fault {
+	return Fault{kind: kindQuality, when: when, quality: q}
+}
+
+//FreezeTimestamps keeps the timestamps of the items at their value when the
+//fault became active, like a device which stopped updating.
+func FreezeTimestamps(when Trigger) Fault {
+	return Fault{kind: kindFreeze, when: when}
+}
+
+//DropTags leaves tags out of the results of reads. Reading a dropped tag
+//alone fails with opcda.ErrTagNotFound.
+func DropTags(when Trigger) Fault {
+	return Fault{kind: kindDrop, when: when}
+}
+
+//RejectWrites makes the writes fail with err, or ErrInjected, without
+//writing.
+func RejectWrites(err error, when Trigger) Fault {
+	return Fault{kind: kindRejectWrite, when: when, err: orInjected(err)}
+}
+
+//Disconnect makes all calls fail with opcda.ErrNotConnected and IsConnected
+//report false. It takes down the whole connection, so it ignores the Tags of
+//the trigger, and its Probability decides once per period of the schedule if
+//the connection is down for the whole period.
+func Disconnect(when Trigger) Fault {
+	when.Tags = nil
+	return Fault{kind: kindDisconnect, when: when}
+}
+
+//Distribution draws a latency.
+type Distribution func(r *rand.Rand) time.Duration
+
+//Fixed is always d.
+func Fixed(d time.Duration) Distribution {
+	return func(*rand.Rand) time.Duration {
+		return d
+	}
+}
+
+//Uniform is uniformly distributed between min and max.
+func Uniform(min, max time.Duration) Distribution {
+	return func(r *rand.Rand) time.Duration {
+		return min + time.Duration(r.Float64()*float64(max-min))
+	}
+}
+
+//Normal is normally distributed around mean, but never negative.
+func Normal(mean, stddev time.Duration) Distribution {
+	return func(r *rand.Rand) time.Duration {
+		return time.Duration(math.Max(0, float64(mean)+r.NormFloat64()*float64(stddev)))
+	}
+}
+
+//Exponential is exponentially distributed with mean, which models the long
+//tail of slow calls.
+func Exponential(mean time.Duration) Distribution {
+	return func(r *rand.Rand) time.Duration {
+		return time.Duration(r.ExpFloat64() * float64(mean))
+	}
+}
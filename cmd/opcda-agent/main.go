@@ -0,0 +1,90 @@
+//Command opcda-agent serves an OPC server over HTTP for remote.Client.
+//
+//	opcda-agent -server Graybox.Simulator -tags numeric.sin.float
+//
+//The agent listens on 127.0.0.1:8080 by default. Every client which reaches
+//it can write to the OPC server, so before listening on other addresses set
+//-token and -tls-cert/-tls-key, and -read-only if the clients only read:
+//
+//	opcda-agent -listen :8443 -token-file token -tls-cert cert.pem -tls-key key.pem -read-only
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/rxue92/opcda"
+	"github.com/rxue92/opcda/remote"
+)
+
+func main() {
+	server := flag.String("server", "Graybox.Simulator", "ProgId of the OPC server")
+	nodes := flag.String("nodes", "localhost", "comma separated nodes of the OPC server")
+	tags := flag.String("tags", "", "comma separated tags to add at start")
+	listen := flag.String("listen", "127.0.0.1:8080", "address to serve on")
+	browse := flag.Bool("browse", true, "serve the browser of the OPC server")
+	readOnly := flag.Bool("read-only", false, "refuse add, remove, write and subscriptions to tags which are not added")
+	token := flag.String("token", "", "bearer token which the clients must send")
+	tokenFile := flag.String("token-file", "", "file with the bearer token, instead of -token")
+	cert := flag.String("tls-cert", "", "certificate file to serve with TLS")
+	key := flag.String("tls-key", "", "key file of the certificate")
+	flag.Parse()
+
+	if *tokenFile != "" {
+		b, err := ioutil.ReadFile(*tokenFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		*token = strings.TrimSpace(string(b))
+	}
+	if (*cert == "") != (*key == "") {
+		log.Fatal("-tls-cert and -tls-key must be set together")
+	}
+	var opts []remote.ServerOption
+	if *token != "" {
+		opts = append(opts, remote.RequireToken(*token))
+	}
+	if *readOnly {
+		opts = append(opts, remote.ReadOnly())
+	}
+	if *token == "" || *cert == "" {
+		log.Printf("warning: clients on %s are not authenticated or not encrypted; set -token and -tls-cert/-tls-key", *listen)
+	}
+
+	conn, err := opcda.NewConnection(*server, split(*nodes), split(*tags))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+
+	var browser opcda.Browser
+	if *browse {
+		browser, err = opcda.NewBrowser(*server, split(*nodes))
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer browser.Close()
+	}
+
+	handler := remote.NewServer(conn, browser, opts...)
+	log.Printf("serving %s on %s", *server, *listen)
+	if *cert != "" {
+		log.Print(http.ListenAndServeTLS(*listen, *cert, *key, handler))
+		return
+	}
+	log.Print(http.ListenAndServe(*listen, handler))
+}
+
+//split returns the comma separated values of s.
+func split(s string) []string {
+	var values []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
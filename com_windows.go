@@ -0,0 +1,531 @@
+//go:build windows
+// +build windows
+
+package opcda
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+var (
+	modoleaut32 = syscall.NewLazyDLL("oleaut32.dll")
+
+	procSafeArrayCreateVector = modoleaut32.NewProc("SafeArrayCreateVector")
+	procSafeArrayDestroy      = modoleaut32.NewProc("SafeArrayDestroy")
+	procSafeArrayGetLBound    = modoleaut32.NewProc("SafeArrayGetLBound")
+	procSafeArrayGetUBound    = modoleaut32.NewProc("SafeArrayGetUBound")
+	procSafeArrayGetElement   = modoleaut32.NewProc("SafeArrayGetElement")
+	procSafeArrayGetVartype   = modoleaut32.NewProc("SafeArrayGetVartype")
+	procSafeArrayPutElement   = modoleaut32.NewProc("SafeArrayPutElement")
+)
+
+// iidOPCGroupEvent is the event interface DIOPCGroupEvent of the OPCGroup
+// object in the OPC Automation Wrapper.
+var iidOPCGroupEvent = ole.NewGUID("{28E68F97-8D75-11D1-8DC3-3C302A000000}")
+
+// dispids of the DIOPCGroupEvent methods
+const (
+	dispidDataChange          int32 = 1
+	dispidAsyncReadComplete   int32 = 2
+	dispidAsyncWriteComplete  int32 = 3
+	dispidAsyncCancelComplete int32 = 4
+)
+
+// variantArray returns the SAFEARRAY of a VARIANT, which is passed
+// by reference in the arguments of the OPC events.
+func variantArray(v *ole.VARIANT) (*ole.SafeArray, error) {
+	if v.VT&ole.VT_ARRAY == 0 {
+		return nil, errors.New("variant is not an array")
+	}
+	if v.VT&ole.VT_BYREF != 0 {
+		return **(***ole.SafeArray)(unsafe.Pointer(&v.Val)), nil
+	}
+	return *(**ole.SafeArray)(unsafe.Pointer(&v.Val)), nil
+}
+
+// safeArrayBounds returns the lower and upper bound of a one-dimensional SAFEARRAY.
+// The arrays of the OPC Automation Wrapper start at index 1.
+func safeArrayBounds(sa *ole.SafeArray) (lower int32, upper int32, err error) {
+	if sa == nil {
+		return 0, -1, errors.New("safearray is nil")
+	}
+	hr, _, _ := procSafeArrayGetLBound.Call(uintptr(unsafe.Pointer(sa)), 1, uintptr(unsafe.Pointer(&lower)))
+	if hr != 0 {
+		return 0, -1, ole.NewError(hr)
+	}
+	hr, _, _ = procSafeArrayGetUBound.Call(uintptr(unsafe.Pointer(sa)), 1, uintptr(unsafe.Pointer(&upper)))
+	if hr != 0 {
+		return 0, -1, ole.NewError(hr)
+	}
+	return lower, upper, nil
+}
+
+// safeArrayElement copies the element at index into the memory at dst.
+func safeArrayElement(sa *ole.SafeArray, index int32, dst unsafe.Pointer) error {
+	hr, _, _ := procSafeArrayGetElement.Call(uintptr(unsafe.Pointer(sa)), uintptr(unsafe.Pointer(&index)), uintptr(dst))
+	if hr != 0 {
+		return ole.NewError(hr)
+	}
+	return nil
+}
+
+// safeArrayVartype returns the type of the elements of a SAFEARRAY.
+func safeArrayVartype(sa *ole.SafeArray) (ole.VT, error) {
+	var vt uint16
+	hr, _, _ := procSafeArrayGetVartype.Call(uintptr(unsafe.Pointer(sa)), uintptr(unsafe.Pointer(&vt)))
+	if hr != 0 {
+		return 0, ole.NewError(hr)
+	}
+	return ole.VT(vt), nil
+}
+
+// int32sFromSafeArray returns the elements of a SAFEARRAY of shorts or longs.
+func int32sFromSafeArray(sa *ole.SafeArray) ([]int32, error) {
+	lower, upper, err := safeArrayBounds(sa)
+	if err != nil {
+		return nil, err
+	}
+	vt, err := safeArrayVartype(sa)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]int32, 0, upper-lower+1)
+	for i := lower; i <= upper; i++ {
+		switch vt {
+		case ole.VT_I2:
+			var value int16
+			if err := safeArrayElement(sa, i, unsafe.Pointer(&value)); err != nil {
+				return nil, err
+			}
+			values = append(values, int32(value))
+		case ole.VT_I4:
+			var value int32
+			if err := safeArrayElement(sa, i, unsafe.Pointer(&value)); err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		default:
+			return nil, fmt.Errorf("unexpected element type %v", vt)
+		}
+	}
+	return values, nil
+}
+
+// valuesFromSafeArray returns the elements of a SAFEARRAY of VARIANTs.
+func valuesFromSafeArray(sa *ole.SafeArray) ([]interface{}, error) {
+	lower, upper, err := safeArrayBounds(sa)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, 0, upper-lower+1)
+	for i := lower; i <= upper; i++ {
+		var value ole.VARIANT
+		ole.VariantInit(&value)
+		if err := safeArrayElement(sa, i, unsafe.Pointer(&value)); err != nil {
+			return nil, err
+		}
+		values = append(values, value.Value())
+		ole.VariantClear(&value)
+	}
+	return values, nil
+}
+
+// stringsFromSafeArray returns the elements of a SAFEARRAY of BSTRs.
+func stringsFromSafeArray(sa *ole.SafeArray) ([]string, error) {
+	lower, upper, err := safeArrayBounds(sa)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]string, 0, upper-lower+1)
+	for i := lower; i <= upper; i++ {
+		var bstr *uint16
+		if err := safeArrayElement(sa, i, unsafe.Pointer(&bstr)); err != nil {
+			return nil, err
+		}
+		values = append(values, ole.BstrToString(bstr))
+		ole.SysFreeString((*int16)(unsafe.Pointer(bstr)))
+	}
+	return values, nil
+}
+
+// timesFromSafeArray returns the elements of a SAFEARRAY of dates.
+func timesFromSafeArray(sa *ole.SafeArray) ([]time.Time, error) {
+	lower, upper, err := safeArrayBounds(sa)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]time.Time, 0, upper-lower+1)
+	for i := lower; i <= upper; i++ {
+		var date float64
+		if err := safeArrayElement(sa, i, unsafe.Pointer(&date)); err != nil {
+			return nil, err
+		}
+		ts, err := ole.GetVariantDate(math.Float64bits(date))
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, ts)
+	}
+	return values, nil
+}
+
+// int32sFromVariant returns the elements of a VARIANT with an array of shorts or longs.
+func int32sFromVariant(v *ole.VARIANT) ([]int32, error) {
+	sa, err := variantArray(v)
+	if err != nil {
+		return nil, err
+	}
+	return int32sFromSafeArray(sa)
+}
+
+// valuesFromVariant returns the elements of a VARIANT with an array of VARIANTs.
+func valuesFromVariant(v *ole.VARIANT) ([]interface{}, error) {
+	sa, err := variantArray(v)
+	if err != nil {
+		return nil, err
+	}
+	return valuesFromSafeArray(sa)
+}
+
+// timesFromVariant returns the elements of a VARIANT with an array of dates.
+func timesFromVariant(v *ole.VARIANT) ([]time.Time, error) {
+	sa, err := variantArray(v)
+	if err != nil {
+		return nil, err
+	}
+	return timesFromSafeArray(sa)
+}
+
+// newSafeArray creates a one-dimensional SAFEARRAY with the lower bound 1,
+// as expected by the OPC Automation Wrapper.
+func newSafeArray(vt ole.VT, length int) (*ole.SafeArray, error) {
+	sa, _, err := procSafeArrayCreateVector.Call(uintptr(vt), 1, uintptr(length))
+	if sa == 0 {
+		return nil, err
+	}
+	return *(**ole.SafeArray)(unsafe.Pointer(&sa)), nil
+}
+
+// destroySafeArray frees a SAFEARRAY and its elements.
+func destroySafeArray(sa *ole.SafeArray) {
+	if sa != nil {
+		procSafeArrayDestroy.Call(uintptr(unsafe.Pointer(sa)))
+	}
+}
+
+// safeArrayPut copies the element at src into the array at index.
+func safeArrayPut(sa *ole.SafeArray, index int32, src unsafe.Pointer) error {
+	hr, _, _ := procSafeArrayPutElement.Call(uintptr(unsafe.Pointer(sa)), uintptr(unsafe.Pointer(&index)), uintptr(src))
+	if hr != 0 {
+		return ole.NewError(hr)
+	}
+	return nil
+}
+
+// safeArrayFromInt32s returns a SAFEARRAY of longs.
+func safeArrayFromInt32s(values []int32) (*ole.SafeArray, error) {
+	sa, err := newSafeArray(ole.VT_I4, len(values))
+	if err != nil {
+		return nil, err
+	}
+	for i, value := range values {
+		if err := safeArrayPut(sa, int32(i+1), unsafe.Pointer(&value)); err != nil {
+			destroySafeArray(sa)
+			return nil, err
+		}
+	}
+	return sa, nil
+}
+
+// safeArrayFromValues returns a SAFEARRAY of VARIANTs.
+func safeArrayFromValues(values []interface{}) (*ole.SafeArray, error) {
+	sa, err := newSafeArray(ole.VT_VARIANT, len(values))
+	if err != nil {
+		return nil, err
+	}
+	for i, value := range values {
+		v, err := variantFromValue(value)
+		if err == nil {
+			// the array keeps a copy of the variant
+			err = safeArrayPut(sa, int32(i+1), unsafe.Pointer(&v))
+			ole.VariantClear(&v)
+		}
+		if err != nil {
+			destroySafeArray(sa)
+			return nil, err
+		}
+	}
+	return sa, nil
+}
+
+// variantFromValue converts a Go value to a VARIANT. The caller clears it.
+func variantFromValue(value interface{}) (ole.VARIANT, error) {
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return ole.NewVariant(ole.VT_BOOL, 0xffff), nil
+		}
+		return ole.NewVariant(ole.VT_BOOL, 0), nil
+	case int8:
+		return ole.NewVariant(ole.VT_I1, int64(v)), nil
+	case uint8:
+		return ole.NewVariant(ole.VT_UI1, int64(v)), nil
+	case int16:
+		return ole.NewVariant(ole.VT_I2, int64(v)), nil
+	case uint16:
+		return ole.NewVariant(ole.VT_UI2, int64(v)), nil
+	case int32:
+		return ole.NewVariant(ole.VT_I4, int64(v)), nil
+	case uint32:
+		return ole.NewVariant(ole.VT_UI4, int64(v)), nil
+	case int:
+		return ole.NewVariant(ole.VT_I4, int64(v)), nil
+	case uint:
+		return ole.NewVariant(ole.VT_UI4, int64(v)), nil
+	case int64:
+		return ole.NewVariant(ole.VT_I8, v), nil
+	case uint64:
+		return ole.NewVariant(ole.VT_UI8, int64(v)), nil
+	case float32:
+		return ole.NewVariant(ole.VT_R4, int64(math.Float32bits(v))), nil
+	case float64:
+		return ole.NewVariant(ole.VT_R8, int64(math.Float64bits(v))), nil
+	case string:
+		return ole.NewVariant(ole.VT_BSTR, int64(uintptr(unsafe.Pointer(ole.SysAllocStringLen(v))))), nil
+	case nil:
+		return ole.NewVariant(ole.VT_NULL, 0), nil
+	}
+	return ole.VARIANT{}, fmt.Errorf("unsupported value type %T", value)
+}
+
+// byrefArray returns a VARIANT argument passing the SAFEARRAY at p by reference.
+func byrefArray(vt ole.VT, p **ole.SafeArray) ole.VARIANT {
+	return ole.NewVariant(ole.VT_ARRAY|ole.VT_BYREF|vt, int64(uintptr(unsafe.Pointer(p))))
+}
+
+// byrefVariant returns a VARIANT argument passing the VARIANT at p by reference.
+func byrefVariant(p *ole.VARIANT) ole.VARIANT {
+	return ole.NewVariant(ole.VT_VARIANT|ole.VT_BYREF, int64(uintptr(unsafe.Pointer(p))))
+}
+
+// byrefInt32 returns a VARIANT argument passing the int32 at p by reference.
+func byrefInt32(p *int32) ole.VARIANT {
+	return ole.NewVariant(ole.VT_I4|ole.VT_BYREF, int64(uintptr(unsafe.Pointer(p))))
+}
+
+// int32FromVariant returns the integer of an event argument.
+func int32FromVariant(v *ole.VARIANT) (int32, error) {
+	switch v.VT {
+	case ole.VT_I2, ole.VT_I4, ole.VT_INT:
+		return int32(v.Val), nil
+	case ole.VT_I4 | ole.VT_BYREF, ole.VT_INT | ole.VT_BYREF:
+		return **(**int32)(unsafe.Pointer(&v.Val)), nil
+	case ole.VT_I2 | ole.VT_BYREF:
+		return int32(**(**int16)(unsafe.Pointer(&v.Val))), nil
+	}
+	return 0, fmt.Errorf("unexpected variant type %d", v.VT)
+}
+
+// invokeMethod calls the method name of disp with the arguments in their
+// natural order. Unlike oleutil.CallMethod, it passes the VARIANTs as they are,
+// which is needed for the typed arrays of the OPC Automation Wrapper.
+func invokeMethod(disp *ole.IDispatch, name string, args ...ole.VARIANT) (*ole.VARIANT, error) {
+	dispid, err := disp.GetSingleIDOfName(name)
+	if err != nil {
+		return nil, err
+	}
+	// arguments are passed in reverse order
+	reversed := make([]ole.VARIANT, len(args))
+	for i := range args {
+		reversed[len(args)-1-i] = args[i]
+	}
+	var params dispParams
+	if len(reversed) > 0 {
+		params.args = &reversed[0]
+		params.cArgs = uint32(len(reversed))
+	}
+	result := new(ole.VARIANT)
+	ole.VariantInit(result)
+	var excepInfo ole.EXCEPINFO
+	hr, _, _ := syscall.Syscall9(
+		disp.VTable().Invoke,
+		9,
+		uintptr(unsafe.Pointer(disp)),
+		uintptr(dispid),
+		uintptr(unsafe.Pointer(ole.IID_NULL)),
+		uintptr(ole.GetUserDefaultLCID()),
+		uintptr(ole.DISPATCH_METHOD),
+		uintptr(unsafe.Pointer(&params)),
+		uintptr(unsafe.Pointer(result)),
+		uintptr(unsafe.Pointer(&excepInfo)),
+		0)
+	runtime.KeepAlive(reversed)
+	if hr != 0 {
+		return nil, ole.NewErrorWithSubError(hr, excepInfo.String(), excepInfo)
+	}
+	return result, nil
+}
+
+// dispParams mirrors the DISPPARAMS structure passed to IDispatch::Invoke.
+type dispParams struct {
+	args      *ole.VARIANT
+	namedArgs *int32
+	cArgs     uint32
+	cNamed    uint32
+}
+
+// arguments returns the arguments in reverse order, as COM passes them.
+func (p *dispParams) arguments() []ole.VARIANT {
+	if p == nil || p.cArgs == 0 || p.args == nil {
+		return nil
+	}
+	n := int(p.cArgs)
+	return (*[1 << 16]ole.VARIANT)(unsafe.Pointer(p.args))[:n:n]
+}
+
+// eventSink is a minimal COM object implementing IDispatch which receives
+// the events of a connection point and passes them to handle.
+type eventSink struct {
+	vtbl   *ole.IDispatchVtbl
+	ref    int32
+	iid    *ole.GUID
+	handle func(dispid int32, args []ole.VARIANT)
+}
+
+var (
+	eventSinkOnce sync.Once
+	eventSinkVtbl *ole.IDispatchVtbl
+
+	// liveSinks keeps the sinks referenced by COM from being garbage collected.
+	liveSinks   = make(map[*eventSink]bool)
+	liveSinksMu sync.Mutex
+)
+
+// newEventSink returns an eventSink for the event interface iid.
+func newEventSink(iid *ole.GUID, handle func(dispid int32, args []ole.VARIANT)) *eventSink {
+	eventSinkOnce.Do(func() {
+		eventSinkVtbl = &ole.IDispatchVtbl{
+			IUnknownVtbl: ole.IUnknownVtbl{
+				QueryInterface: syscall.NewCallback(sinkQueryInterface),
+				AddRef:         syscall.NewCallback(sinkAddRef),
+				Release:        syscall.NewCallback(sinkRelease),
+			},
+			GetTypeInfoCount: syscall.NewCallback(sinkGetTypeInfoCount),
+			GetTypeInfo:      syscall.NewCallback(sinkGetTypeInfo),
+			GetIDsOfNames:    syscall.NewCallback(sinkGetIDsOfNames),
+			Invoke:           syscall.NewCallback(sinkInvoke),
+		}
+	})
+	sink := &eventSink{vtbl: eventSinkVtbl, iid: iid, handle: handle}
+	sinkAddRef(sink)
+	return sink
+}
+
+// unknown returns the sink as IUnknown for IConnectionPoint.Advise.
+func (sink *eventSink) unknown() *ole.IUnknown {
+	return (*ole.IUnknown)(unsafe.Pointer(sink))
+}
+
+// release drops the reference taken by newEventSink.
+func (sink *eventSink) release() {
+	sinkRelease(sink)
+}
+
+func sinkQueryInterface(this *eventSink, iid *ole.GUID, obj **eventSink) uintptr {
+	if obj == nil {
+		return ole.E_POINTER
+	}
+	if ole.IsEqualGUID(iid, ole.IID_IUnknown) || ole.IsEqualGUID(iid, ole.IID_IDispatch) || ole.IsEqualGUID(iid, this.iid) {
+		sinkAddRef(this)
+		*obj = this
+		return ole.S_OK
+	}
+	*obj = nil
+	return ole.E_NOINTERFACE
+}
+
+func sinkAddRef(this *eventSink) uintptr {
+	ref := atomic.AddInt32(&this.ref, 1)
+	if ref == 1 {
+		liveSinksMu.Lock()
+		liveSinks[this] = true
+		liveSinksMu.Unlock()
+	}
+	return uintptr(ref)
+}
+
+func sinkRelease(this *eventSink) uintptr {
+	ref := atomic.AddInt32(&this.ref, -1)
+	if ref == 0 {
+		liveSinksMu.Lock()
+		delete(liveSinks, this)
+		liveSinksMu.Unlock()
+	}
+	return uintptr(ref)
+}
+
+func sinkGetTypeInfoCount(this *eventSink, count *uint32) uintptr {
+	if count != nil {
+		*count = 0
+	}
+	return ole.S_OK
+}
+
+// The callbacks must declare every argument of the COM method, because on
+// 386 the callee removes them from the stack.
+
+func sinkGetTypeInfo(this *eventSink, index uintptr, lcid uintptr, info uintptr) uintptr {
+	return ole.E_NOTIMPL
+}
+
+func sinkGetIDsOfNames(this *eventSink, iid uintptr, names uintptr, count uintptr, lcid uintptr, dispids uintptr) uintptr {
+	return ole.E_NOTIMPL
+}
+
+func sinkInvoke(this *eventSink, dispid uintptr, iid uintptr, lcid uintptr, flags uintptr, params *dispParams, result uintptr, excepInfo uintptr, argErr uintptr) uintptr {
+	this.handle(int32(dispid), params.arguments())
+	return ole.S_OK
+}
+
+// advise connects sink to the connection point iid of the COM object disp.
+// It returns the connection point and the cookie needed for unadvise.
+func advise(disp *ole.IDispatch, iid *ole.GUID, sink *eventSink) (*ole.IConnectionPoint, uint32, error) {
+	unknown, err := disp.QueryInterface(ole.IID_IConnectionPointContainer)
+	if err != nil {
+		return nil, 0, err
+	}
+	container := (*ole.IConnectionPointContainer)(unsafe.Pointer(unknown))
+	defer container.Release()
+
+	var point *ole.IConnectionPoint
+	if err := container.FindConnectionPoint(iid, &point); err != nil {
+		return nil, 0, err
+	}
+	cookie, err := point.Advise(sink.unknown())
+	if err != nil {
+		point.Release()
+		return nil, 0, err
+	}
+	return point, cookie, nil
+}
+
+// unadvise disconnects the sink from the connection point and releases it.
+func unadvise(point *ole.IConnectionPoint, cookie uint32) {
+	if point == nil {
+		return
+	}
+	if err := point.Unadvise(cookie); err != nil {
+		logger.Println("Failed to unadvise event sink:", err)
+	}
+	point.Release()
+}
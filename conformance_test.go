@@ -0,0 +1,61 @@
+package opcda_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rxue92/opcda"
+	"github.com/rxue92/opcda/opcdatest"
+)
+
+func TestMockServerConformance(t *testing.T) {
+	tags := []string{"tag1", "tag2", "tag3"}
+	t.Run("Static", func(t *testing.T) {
+		opcdatest.TestConnection(t, opcdatest.ConnectionConfig{
+			New:       func() (opcda.Connection, error) { return &opcda.OpcMockServerStatic{TagList: tags}, nil },
+			Tags:      tags,
+			Missing:   "tag4",
+			FixedTags: true,
+		})
+	})
+	t.Run("Random", func(t *testing.T) {
+		opcdatest.TestConnection(t, opcdatest.ConnectionConfig{
+			New:       func() (opcda.Connection, error) { return &opcda.OpcMockServerRandom{TagList: tags}, nil },
+			Tags:      tags,
+			Missing:   "tag4",
+			FixedTags: true,
+		})
+	})
+	t.Run("Writable", func(t *testing.T) {
+		opcdatest.TestConnection(t, opcdatest.ConnectionConfig{
+			New: func() (opcda.Connection, error) {
+				return &opcda.OpcMockServerWritable{Values: map[string]interface{}{"tag1": 1.0, "tag2": "text"}}, nil
+			},
+			Tags:      []string{"tag1", "tag2"},
+			Values:    map[string]interface{}{"tag1": 2.5, "tag2": "changed"},
+			FixedTags: true,
+		})
+	})
+}
+
+func TestConnectionConformance(t *testing.T) {
+	opcdatest.TestConnection(t, opcdatest.ConnectionConfig{
+		New:     opcda.NewFakeConnection,
+		Tags:    []string{"numeric.sin.float", "numeric.saw.int64", "textual.random"},
+		Missing: "unknown",
+		Values:  map[string]interface{}{"numeric.sin.float": 2.5, "textual.random": "changed"},
+	})
+}
+
+func TestTreeBrowserConformance(t *testing.T) {
+	opcdatest.TestBrowser(t, func() (opcda.Browser, error) {
+		tree, err := opcda.ReadTree(strings.NewReader(`{"name": "root", "branches": [
+			{"name": "numeric", "branches": [{"name": "sin", "leaves": [{"name": "float", "itemId": "numeric.sin.float"}]}]},
+			{"name": "textual", "leaves": [{"name": "string", "itemId": "textual.string"}]}
+		]}`))
+		if err != nil {
+			return nil, err
+		}
+		return opcda.NewTreeBrowser(tree), nil
+	})
+}
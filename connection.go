@@ -1,6 +1,9 @@
 package opcda
 
 import (
+	"context"
+	"errors"
+	"log"
 	"time"
 )
 
@@ -11,41 +14,35 @@ const (
 	OPCCache int32 = 1
 	//From the device
 	OPCDevice int32 = 2
-
-	//OPCQuality defines the quality of the OPC items:
-	//Bad
-	OPCQualityBad int16 = 0
-	//Good
-	OPCQualityGood          int16 = 192
-	OPCQualityGoodButForced int16 = 216
-	//Maks
-	OPCQualityMask int16 = 192
-	//Uncertain
-	OPCQualityUncertain int16 = 64
-
-	//OPCServerState defines the state of the server:
-	//Disconnected
-	OPCDisconnected int32 = 6
-	//Failed
-	OPCFailed int32 = 2
-	//Noconfig
-	OPCNoconfig int32 = 3
-	//Running
-	OPCRunning int32 = 1
-	//Suspended
-	OPCSuspended int32 = 4
-	//Test
-	OPCTest int32 = 5
 )
 
 //Connection represents the interface for the connection to the OPC server.
+//The methods with a Context suffix honor the deadline and cancellation of the
+//context, including while reconnecting. The methods without the suffix use
+//context.Background().
+//A context error only means that the caller stopped waiting: the call to the
+//server is not aborted, so the tags of AddContext may still be added and the
+//value of WriteContext may still be written after the error is returned.
+//ReadContext and ReadItemContext read from the source set on ctx with
+//WithReadSource, e.g. SourceDevice to bypass the cache of the server or
+//SourceMaxAge for a max-age read. Without it they read from the default
+//source of the connection, which is SourceCache unless it was changed with
+//WithDefaultReadSource or, on connections implementing ReadSourceSetter, with
+//SetReadSource.
+//ReadContext returns a ReadResult for every added tag, so a failed read can be
+//told apart from a zero value; its error is only set if the call as a whole
+//failed. Read only returns the tags that were read successfully.
 type Connection interface {
 	Add(...string) error
+	AddContext(context.Context, ...string) error
 	Remove(string)
 	Read() map[string]Item
+	ReadContext(context.Context) (map[string]ReadResult, error)
 	ReadItem(string) Item
+	ReadItemContext(context.Context, string) (Item, error)
 	Tags() []string
 	Write(string, interface{}) error
+	WriteContext(context.Context, string, interface{}) error
 	Close()
 	IsConnected() bool
 }
@@ -53,16 +50,30 @@ type Connection interface {
 //Item stores the result of an OPC item from the OPC server.
 type Item struct {
 	Value     interface{}
-	Quality   int16
+	Quality   Quality
 	Timestamp time.Time
 }
 
-//Good checks the quality of the Item
-func (i *Item) Good() bool {
-	if i.Quality == OPCQualityGood || i.Quality == OPCQualityGoodButForced {
-		return true
+//ReadResult stores the Item read for a tag or the error why it could not be read.
+type ReadResult struct {
+	Item
+	Err error
+}
+
+//Items returns the items of the results that were read without an error.
+func Items(results map[string]ReadResult) map[string]Item {
+	items := make(map[string]Item)
+	for tag, result := range results {
+		if result.Err == nil {
+			items[tag] = result.Item
+		}
 	}
-	return false
+	return items
+}
+
+//Good checks the quality of the Item, see Quality.IsGood.
+func (i *Item) Good() bool {
+	return i.Quality.IsGood()
 }
 
 type Browser interface {
@@ -75,3 +86,354 @@ type Browser interface {
 	ShowBranches() []string // sub-branches of the current position
 	ShowLeafs() []Leaf      // items of the current position
 }
+
+//opcConnectionImpl implements the Connection interface.
+//It has the AutomationObject embedded for connecting to the server
+//and an AutomationItems to facilitate the OPC items bookkeeping.
+//Named groups are kept by platformConn and re-created after a reconnect.
+//source is the default source of reads without a source in their context,
+//opts are the options the connection was created with.
+type opcConnectionImpl struct {
+	*AutomationObject
+	*AutomationItems
+	Server string
+	Nodes  []string
+	mu     ctxMutex
+	source ReadSource
+	opts   options
+	platformConn
+	//reconnector re-establishes the connection in the background; it is nil
+	//if the connection could not be created.
+	reconnector *reconnector
+	state       *stateMachine
+}
+
+//State returns the state of the connection without calling the server.
+func (conn *opcConnectionImpl) State() State {
+	return conn.state.current()
+}
+
+//StateChanges returns a channel which receives the state changes of the
+//connection until it is closed.
+func (conn *opcConnectionImpl) StateChanges() <-chan StateEvent {
+	return conn.state.changes()
+}
+
+//log returns the logger of the connection or the package logger.
+func (conn *opcConnectionImpl) log() *log.Logger {
+	return conn.opts.log()
+}
+
+//SetReadSource changes the default source of the reads.
+func (conn *opcConnectionImpl) SetReadSource(src ReadSource) error {
+	if err := src.validate(); err != nil {
+		return err
+	}
+	return conn.mu.do(context.Background(), func() {
+		conn.source = src
+	})
+}
+
+//readSource returns the source set in ctx or the default source of conn.
+//It must be called with the lock held.
+func (conn *opcConnectionImpl) readSource(ctx context.Context) ReadSource {
+	if src, ok := ReadSourceFromContext(ctx); ok {
+		return src
+	}
+	return conn.source
+}
+
+//checkReadSource validates the source set in ctx.
+func checkReadSource(ctx context.Context) error {
+	if src, ok := ReadSourceFromContext(ctx); ok {
+		return src.validate()
+	}
+	return nil
+}
+
+//ReadItem returns an Item for a specific tag.
+//It returns an empty Item if the tag could not be read, use ReadItemContext
+//to get the reason.
+func (conn *opcConnectionImpl) ReadItem(tag string) Item {
+	item, err := conn.ReadItemContext(context.Background(), tag)
+	if err != nil {
+		conn.log().Println(err)
+	}
+	return item
+}
+
+//ReadItemContext returns an Item for a specific tag. It gives up waiting
+//for the server when ctx is done and fails with ErrReconnecting while the
+//connection is re-established.
+func (conn *opcConnectionImpl) ReadItemContext(ctx context.Context, tag string) (Item, error) {
+	return conn.readItem(ctx, func() *AutomationItems { return conn.AutomationItems }, tag)
+}
+
+//readItem reads tag from the items returned by items, which may change
+//during a reconnect, and starts reconnecting if the read fails because the
+//connection is lost.
+func (conn *opcConnectionImpl) readItem(ctx context.Context, items func() *AutomationItems, tag string) (Item, error) {
+	if err := checkReadSource(ctx); err != nil {
+		return Item{}, err
+	}
+	if err := conn.available(); err != nil {
+		return Item{}, err
+	}
+	var item Item
+	var err error
+	cerr := conn.mu.do(ctx, func() {
+		item, err = items().read(tag, conn.readSource(ctx))
+		if err != nil && !errors.Is(err, ErrTagNotFound) {
+			conn.lost(err)
+		}
+	})
+	if cerr != nil {
+		return Item{}, cerr
+	}
+	return item, err
+}
+
+//Write writes a value to the OPC Server.
+//If tag not found, try add it first.
+func (conn *opcConnectionImpl) Write(tag string, value interface{}) error {
+	return conn.WriteContext(context.Background(), tag, value)
+}
+
+//WriteContext writes a value to the OPC Server and gives up waiting when ctx is done.
+//If tag not found, try add it first.
+//The value may still be written after the context error is returned.
+func (conn *opcConnectionImpl) WriteContext(ctx context.Context, tag string, value interface{}) error {
+	return conn.write(ctx, func() *AutomationItems { return conn.AutomationItems }, tag, value)
+}
+
+//write writes value to tag of the items returned by items.
+func (conn *opcConnectionImpl) write(ctx context.Context, items func() *AutomationItems, tag string, value interface{}) error {
+	if err := conn.available(); err != nil {
+		return err
+	}
+	var err error
+	cerr := conn.mu.do(ctx, func() {
+		err = items().write(tag, value)
+		if err != nil && !errors.Is(err, ErrTagNotFound) {
+			conn.lost(err)
+		}
+	})
+	if cerr != nil {
+		return cerr
+	}
+	return err
+}
+
+//WriteMany writes the values to their tags in one operation and returns the
+//errors of the tags which could not be written. Tags which are not added yet
+//are added as write-only tags. See WriteOptions for all-or-nothing writes.
+func (conn *opcConnectionImpl) WriteMany(ctx context.Context, values map[string]interface{}, opts WriteOptions) (map[string]error, error) {
+	return conn.writeMany(ctx, func() *AutomationItems { return conn.AutomationItems }, values, opts)
+}
+
+//writeMany writes values to the items returned by items. The previous values
+//of an all-or-nothing write are read and restored while holding the lock.
+func (conn *opcConnectionImpl) writeMany(ctx context.Context, items func() *AutomationItems, values map[string]interface{}, opts WriteOptions) (map[string]error, error) {
+	if err := conn.available(); err != nil {
+		return nil, err
+	}
+	var failures map[string]error
+	cerr := conn.mu.do(ctx, func() {
+		ai := items()
+		read := func(tags []string) map[string]ReadResult {
+			return ai.readTags(tags, SourceDevice)
+		}
+		failures = writeMany(values, opts, read, ai.writeMany)
+	})
+	if cerr != nil {
+		return nil, cerr
+	}
+	return failures, nil
+}
+
+//Read returns a map of the values of all added tags.
+//Tags that could not be read are left out, use ReadContext to get the reason.
+func (conn *opcConnectionImpl) Read() map[string]Item {
+	results, err := conn.ReadContext(context.Background())
+	if err != nil {
+		conn.log().Println(err)
+	}
+	return Items(results)
+}
+
+//ReadContext returns a map with the result of all added tags. It gives up
+//waiting for the server when ctx is done.
+//If any tag cannot be read, the connection is checked after all tags have
+//been tried and re-established in the background, see ReconnectPolicy.
+func (conn *opcConnectionImpl) ReadContext(ctx context.Context) (map[string]ReadResult, error) {
+	return conn.readAll(ctx, func() *AutomationItems { return conn.AutomationItems })
+}
+
+//readAll reads all tags of the items returned by items. If a tag cannot be
+//read because the connection is lost, it starts reconnecting and returns the
+//results with ErrReconnecting.
+func (conn *opcConnectionImpl) readAll(ctx context.Context, items func() *AutomationItems) (map[string]ReadResult, error) {
+	if err := checkReadSource(ctx); err != nil {
+		return map[string]ReadResult{}, err
+	}
+	if err := conn.available(); err != nil {
+		return map[string]ReadResult{}, err
+	}
+	var err error
+	var results map[string]ReadResult
+	cerr := conn.mu.do(ctx, func() {
+		var failed bool
+		results, failed = items().readAll(ctx, conn.readSource(ctx))
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		if !failed {
+			conn.state.setFrom(StateDegraded, StateConnected, nil)
+			return
+		}
+		cause := errors.New("cannot read all tags")
+		if conn.lost(cause) {
+			err = ErrReconnecting
+			return
+		}
+		conn.state.setFrom(StateConnected, StateDegraded, cause)
+	})
+	if cerr != nil {
+		return map[string]ReadResult{}, cerr
+	}
+	return results, err
+}
+
+//Tags returns the currently active tags
+func (conn *opcConnectionImpl) Tags() []string {
+	var tags []string
+	conn.mu.do(context.Background(), func() {
+		tags = conn.AutomationItems.tags()
+	})
+	return tags
+}
+
+//Avoid read during adding or removing items
+func (conn *opcConnectionImpl) Add(items ...string) error {
+	return conn.AddContext(context.Background(), items...)
+}
+
+//AddContext adds the items and gives up waiting when ctx is done.
+//The items may still be added after the context error is returned.
+func (conn *opcConnectionImpl) AddContext(ctx context.Context, items ...string) error {
+	return conn.add(ctx, func() *AutomationItems { return conn.AutomationItems }, items...)
+}
+
+//add adds tags to the items returned by items.
+func (conn *opcConnectionImpl) add(ctx context.Context, items func() *AutomationItems, tags ...string) error {
+	if err := conn.available(); err != nil {
+		return err
+	}
+	var err error
+	cerr := conn.mu.do(ctx, func() {
+		err = items().Add(tags...)
+	})
+	if cerr != nil {
+		return cerr
+	}
+	return err
+}
+
+func (conn *opcConnectionImpl) Remove(item string) {
+	conn.mu.do(context.Background(), func() {
+		conn.AutomationItems.Remove(item)
+	})
+}
+
+//available returns ErrReconnecting while the connection is re-established.
+func (conn *opcConnectionImpl) available() error {
+	if conn.reconnector != nil && conn.reconnector.reconnecting() {
+		return ErrReconnecting
+	}
+	return nil
+}
+
+//lost checks the connection after an operation failed with err and starts
+//reconnecting in the background if it is lost. It reports if the connection
+//is lost. It must be called with the lock held.
+func (conn *opcConnectionImpl) lost(err error) bool {
+	if conn.IsConnected() {
+		return false
+	}
+	if conn.reconnector != nil {
+		conn.log().Printf("%s. Reconnecting.", err)
+		conn.reconnector.start(err)
+	}
+	return true
+}
+
+//reconnectOnce makes a single attempt to re-establish the connection.
+//It holds the lock only during the attempt, not between the attempts.
+func (conn *opcConnectionImpl) reconnectOnce() error {
+	var err error
+	conn.mu.do(context.Background(), func() {
+		err = conn.fix()
+		if err != nil {
+			conn.log().Println(err)
+		}
+	})
+	return err
+}
+
+//fix reconnects if connection is lost by creating a new connection
+//with AutomationObject and creating a new AutomationItems instance.
+//The named groups are re-created with their settings and tags.
+func (conn *opcConnectionImpl) fix() error {
+	items, err := conn.AutomationObject.reconnect(conn.Server, conn.Nodes, conn.AutomationItems)
+	if err != nil {
+		return err
+	}
+	if items == conn.AutomationItems {
+		return nil
+	}
+	conn.AutomationItems = items
+	conn.recreateGroups(conn.log())
+	return nil
+}
+
+//Close stops reconnecting and closes the embedded types.
+func (conn *opcConnectionImpl) Close() {
+	conn.state.set(StateClosed, nil)
+	if conn.reconnector != nil {
+		conn.reconnector.close()
+	}
+	conn.mu.do(context.Background(), func() {
+		conn.releaseGroups()
+		if conn.AutomationObject != nil {
+			conn.AutomationObject.Close()
+		}
+		if conn.AutomationItems != nil {
+			conn.AutomationItems.Close()
+		}
+	})
+}
+
+func (conn *opcConnectionImpl) IsConnected() bool {
+	if conn.AutomationObject != nil {
+		return conn.AutomationObject.IsConnected()
+	}
+	return false
+}
+
+//newConnection returns a connection of object and its items which is
+//re-established with the reconnect policy of o when it is lost.
+func newConnection(object *AutomationObject, items *AutomationItems, server string, nodes []string, o options, state *stateMachine) *opcConnectionImpl {
+	conn := &opcConnectionImpl{
+		AutomationObject: object,
+		AutomationItems:  items,
+		Server:           server,
+		Nodes:            nodes,
+		mu:               newCtxMutex(),
+		source:           o.source,
+		opts:             o,
+		state:            state,
+	}
+	conn.reconnector = newReconnector(state.observe(o.reconnect), conn.reconnectOnce)
+	state.set(StateConnected, nil)
+	return conn
+}
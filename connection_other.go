@@ -0,0 +1,81 @@
+//go:build !windows
+// +build !windows
+
+package opcda
+
+import (
+	"log"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+// The OPC Automation Wrapper is a COM object which only exists on Windows.
+// On other platforms the same API compiles and returns ErrUnsupportedPlatform,
+// so that portable code and its tests can be built everywhere.
+
+// OleInit does nothing on this platform.
+func OleInit() {}
+
+// OleRelease does nothing on this platform.
+func OleRelease() {}
+
+// QueryAvailableProperties returns ErrUnsupportedPlatform.
+func (ao *AutomationObject) QueryAvailableProperties(itemID string) ([]PropertyInfo, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+// GetItemProperties returns ErrUnsupportedPlatform.
+func (ao *AutomationObject) GetItemProperties(itemID string, ids ...int32) ([]Property, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+// NewAutomationObject returns ErrUnsupportedPlatform.
+func NewAutomationObject() (*AutomationObject, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+// platformItems holds nothing on this platform.
+type platformItems struct{}
+
+func (p *platformItems) close() {}
+
+// newGroupSyncIO returns nil, there are no COM objects on this platform.
+func newGroupSyncIO(group dispatcher) syncIO {
+	return nil
+}
+
+// platformConn holds nothing on this platform, named groups need COM.
+type platformConn struct{}
+
+func (p *platformConn) recreateGroups(out *log.Logger) {}
+
+func (p *platformConn) releaseGroups() {}
+
+// NewAutomationItems returns AutomationItems without OPCItems, which cannot add tags.
+func NewAutomationItems(opcitems *ole.IDispatch) *AutomationItems {
+	return newAutomationItems(nil)
+}
+
+// NewConnection returns ErrUnsupportedPlatform after checking the options.
+func NewConnection(server string, nodes []string, tags []string, opts ...Option) (Connection, error) {
+	if _, err := newOptions(opts); err != nil {
+		return nil, err
+	}
+	return nil, ErrUnsupportedPlatform
+}
+
+// CreateBrowser returns ErrUnsupportedPlatform after checking the options.
+func CreateBrowser(server string, nodes []string, opts ...Option) (*Tree, error) {
+	if _, err := newOptions(opts); err != nil {
+		return nil, err
+	}
+	return nil, ErrUnsupportedPlatform
+}
+
+// NewBrowser returns ErrUnsupportedPlatform after checking the options.
+func NewBrowser(server string, nodes []string, opts ...Option) (Browser, error) {
+	if _, err := newOptions(opts); err != nil {
+		return nil, err
+	}
+	return nil, ErrUnsupportedPlatform
+}
@@ -0,0 +1,28 @@
+//go:build !windows
+// +build !windows
+
+package opcda
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUnsupportedPlatform(t *testing.T) {
+	if _, err := NewConnection("Graybox.Simulator", []string{"localhost"}, nil); !errors.Is(err, ErrUnsupportedPlatform) {
+		t.Errorf("NewConnection: expected ErrUnsupportedPlatform, got %v", err)
+	}
+	if _, err := CreateBrowser("Graybox.Simulator", []string{"localhost"}); !errors.Is(err, ErrUnsupportedPlatform) {
+		t.Errorf("CreateBrowser: expected ErrUnsupportedPlatform, got %v", err)
+	}
+	if _, err := NewBrowser("Graybox.Simulator", []string{"localhost"}); !errors.Is(err, ErrUnsupportedPlatform) {
+		t.Errorf("NewBrowser: expected ErrUnsupportedPlatform, got %v", err)
+	}
+	if _, err := NewAutomationObject(); !errors.Is(err, ErrUnsupportedPlatform) {
+		t.Errorf("NewAutomationObject: expected ErrUnsupportedPlatform, got %v", err)
+	}
+	if _, err := NewConnection("Graybox.Simulator", nil, nil, WithUpdateRate(-time.Second)); err == nil || errors.Is(err, ErrUnsupportedPlatform) {
+		t.Errorf("invalid options should be reported first, got %v", err)
+	}
+}
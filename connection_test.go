@@ -1,9 +1,13 @@
+//go:build windows
+// +build windows
+
 package opcda_test
 
 import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/rxue92/opcda"
 )
@@ -279,3 +283,44 @@ func TestWriteOnly(t *testing.T) {
 		t.Fatal("the map should have only two items")
 	}
 }
+
+func TestGroups(t *testing.T) {
+	client, _ := opcda.NewConnection(
+		"Graybox.Simulator",
+		[]string{"localhost"},
+		[]string{},
+	)
+	defer client.Close()
+
+	manager, ok := client.(opcda.GroupManager)
+	if !ok {
+		t.Fatal("connection should manage groups")
+	}
+
+	fast, err := manager.AddGroup("fast", opcda.GroupSettings{UpdateRate: 250 * time.Millisecond, Active: true, Subscribed: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slow, err := manager.AddGroup("slow", opcda.GroupSettings{UpdateRate: 10 * time.Second, DeadBand: 1, Active: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.AddGroup("fast", opcda.GroupSettings{}); err == nil {
+		t.Fatal("adding a group twice should fail")
+	}
+
+	fast.Add("numeric.sin.float")
+	slow.Add("numeric.saw.float")
+
+	if len(fast.Read()) != 1 || len(slow.Read()) != 1 {
+		t.Fatal("each group should read its own tag")
+	}
+	if !reflect.DeepEqual(manager.Groups(), []string{"fast", "slow"}) {
+		t.Fatal("Groups() did not return the groups")
+	}
+
+	slow.Close()
+	if _, ok := manager.Group("slow"); ok {
+		t.Fatal("closed group should be removed")
+	}
+}
@@ -0,0 +1,61 @@
+package opcda
+
+import (
+	"context"
+)
+
+// ctxMutex is a mutual exclusion lock whose acquisition can be abandoned
+// when a context is done. The zero value is not usable, use newCtxMutex.
+type ctxMutex chan struct{}
+
+// newCtxMutex returns an unlocked ctxMutex.
+func newCtxMutex() ctxMutex {
+	return make(ctxMutex, 1)
+}
+
+// lock acquires the mutex or returns the context error if ctx is done first.
+func (m ctxMutex) lock(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	select {
+	case m <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// unlock releases the mutex.
+func (m ctxMutex) unlock() {
+	<-m
+}
+
+// do acquires the mutex and runs f in its own goroutine. It returns as soon
+// as f has finished or ctx is done, whichever happens first. If ctx is done
+// first, f keeps running in the background and the mutex is released once f
+// returns, so a blocking call can never be abandoned while holding the lock
+// for the next caller. If f has finished by the time ctx is done, its result
+// wins, so a completed call is never reported as canceled.
+func (m ctxMutex) do(ctx context.Context, f func()) error {
+	if err := m.lock(ctx); err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	go func() {
+		defer m.unlock()
+		defer close(done)
+		f()
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		select {
+		case <-done:
+			return nil
+		default:
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package opcda
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCtxMutexLockTimeout(t *testing.T) {
+	mu := newCtxMutex()
+	if err := mu.lock(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := mu.lock(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	mu.unlock()
+	if err := mu.lock(context.Background()); err != nil {
+		t.Fatal("mutex should be free after unlock")
+	}
+}
+
+func TestCtxMutexDoReturnsOnCancel(t *testing.T) {
+	mu := newCtxMutex()
+	release := make(chan struct{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := mu.do(ctx, func() { <-release })
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("do should return when the context is done")
+	}
+
+	// the lock is held until the abandoned call returns
+	ctx2, cancel2 := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel2()
+	if err := mu.lock(ctx2); err == nil {
+		t.Fatal("lock should still be held by the abandoned call")
+	}
+
+	close(release)
+	if err := mu.do(context.Background(), func() {}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCtxMutexDoCanceledBeforeStart(t *testing.T) {
+	mu := newCtxMutex()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	called := false
+	if err := mu.do(ctx, func() { called = true }); err != context.Canceled {
+		t.Fatalf("expected canceled, got %v", err)
+	}
+	if called {
+		t.Fatal("f should not run with a canceled context")
+	}
+}
+
+//lateContext is done only after finished is closed, so both f and the
+//context are done when ctxMutex.do waits for them.
+type lateContext struct {
+	context.Context
+	finished chan struct{}
+	calls    int
+}
+
+func (c *lateContext) Done() <-chan struct{} {
+	c.calls++
+	if c.calls == 1 {
+		return nil
+	}
+	<-c.finished
+	time.Sleep(10 * time.Millisecond)
+	done := make(chan struct{})
+	close(done)
+	return done
+}
+
+func (c *lateContext) Err() error {
+	select {
+	case <-c.finished:
+		return context.Canceled
+	default:
+		return nil
+	}
+}
+
+func TestCtxMutexDoCompletedBeforeCancel(t *testing.T) {
+	mu := newCtxMutex()
+	for i := 0; i < 20; i++ {
+		ctx := &lateContext{Context: context.Background(), finished: make(chan struct{})}
+		if err := mu.do(ctx, func() { close(ctx.finished) }); err != nil {
+			t.Fatalf("completed call should not be reported as %v", err)
+		}
+	}
+}
+
+func TestMockServerReadContext(t *testing.T) {
+	var conn Connection = &OpcMockServerHung{TagList: []string{"tag1"}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := conn.ReadContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if _, err := conn.ReadItemContext(ctx, "tag1"); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if err := conn.WriteContext(ctx, "tag1", 1.0); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	conn = &OpcMockServerStatic{TagList: []string{"tag1"}}
+	item, err := conn.ReadItemContext(context.Background(), "tag1")
+	if err != nil || item.Value.(float64) != 1.0 {
+		t.Fatalf("unexpected result %v, %v", item, err)
+	}
+}
@@ -1,57 +1,135 @@
 package opcda
 
 import (
+	"context"
 	"io"
 	"sync"
 	"time"
 )
 
 //Collector interface
+//Get returns the last value read for a tag regardless of its quality; GetItem
+//also returns the quality, the timestamp of the server, the time the value
+//was received and the error of the last read if it failed, and GetGood only
+//returns values of good quality whose last read succeeded. Snapshot returns a
+//copy of all tags taken at once.
+//Sync reads from the default source of the connection. SyncContext passes ctx
+//to every read and stops when ctx is done; to collect from another source than
+//the default, pass a context made with WithReadSource, e.g.
+//	collector.SyncContext(WithReadSource(ctx, SourceDevice), conn, time.Second)
 type Collector interface {
 	Get(string) (interface{}, bool)
+	GetItem(string) (CollectedItem, bool)
+	GetGood(string) (interface{}, bool)
+	Snapshot() map[string]CollectedItem
 	Sync(Connection, time.Duration) io.Closer
+	SyncContext(context.Context, Connection, time.Duration) io.Closer
+}
+
+//CollectedItem is the last Item read for a tag by a Collector together with
+//the local time it was received. Err is the error of the reads since, if they
+//failed, so the Item is stale.
+type CollectedItem struct {
+	Item
+	Received time.Time
+	Err      error
 }
 
 //data holds the data structure that is refreshed with OPC data.
 type data struct {
-	tags map[string]interface{}
+	tags map[string]CollectedItem
 	mu   sync.RWMutex
 }
 
 //Get is the thread-safe getter for the tags.
 func (d *data) Get(key string) (interface{}, bool) {
+	item, ok := d.GetItem(key)
+	return item.Value, ok
+}
+
+//GetItem is the thread-safe getter for the tags with quality and timestamps.
+func (d *data) GetItem(key string) (CollectedItem, bool) {
 	d.mu.RLock()
-	value, ok := d.tags[key]
+	item, ok := d.tags[key]
 	d.mu.RUnlock()
-	return value, ok
+	return item, ok
 }
 
-//update is a helper function to update map
-func (d *data) update(conn Connection) {
-	update := conn.Read()
+//GetGood returns the value of the tag only if its quality is good and its
+//last read succeeded.
+func (d *data) GetGood(key string) (interface{}, bool) {
+	item, ok := d.GetItem(key)
+	if !ok || !item.Good() || item.Err != nil {
+		return nil, false
+	}
+	return item.Value, true
+}
+
+//Snapshot returns a copy of all tags taken under a single lock, so the items
+//are from the same update.
+func (d *data) Snapshot() map[string]CollectedItem {
+	d.mu.RLock()
+	snapshot := make(map[string]CollectedItem, len(d.tags))
+	for key, item := range d.tags {
+		snapshot[key] = item
+	}
+	d.mu.RUnlock()
+	return snapshot
+}
+
+//update is a helper function to update map.
+//Tags that could not be read keep their previous value with the error. If
+//the whole read failed, all tags get its error unless ctx is done.
+func (d *data) update(ctx context.Context, conn Connection) {
+	update, err := conn.ReadContext(ctx)
+	if err != nil {
+		logger.Println("Cannot update data model:", err)
+	}
+	received := time.Now()
 	d.mu.Lock()
-	for key, item := range update {
-		d.tags[key] = item.Value
+	if err != nil && ctx.Err() == nil {
+		for key, item := range d.tags {
+			item.Err = err
+			d.tags[key] = item
+		}
+	}
+	for key, result := range update {
+		if result.Err != nil {
+			if item, ok := d.tags[key]; ok {
+				item.Err = result.Err
+				d.tags[key] = item
+			}
+			continue
+		}
+		d.tags[key] = CollectedItem{Item: result.Item, Received: received}
 	}
 	d.mu.Unlock()
 }
 
 //Sync synchronizes the opc server and stores the data into the data model.
 func (d *data) Sync(conn Connection, refreshRate time.Duration) io.Closer {
+	return d.SyncContext(context.Background(), conn, refreshRate)
+}
+
+//SyncContext synchronizes the opc server with the reads done with ctx and
+//stores the data into the data model until ctx is done or it is closed.
+func (d *data) SyncContext(ctx context.Context, conn Connection, refreshRate time.Duration) io.Closer {
 
 	control := newControl()
 	ticker := time.NewTicker(refreshRate)
 
-	d.update(conn)
+	d.update(ctx, conn)
 
 	go func() {
+		defer close(control.done)
+		defer ticker.Stop()
 		for {
 			select {
 			case <-ticker.C:
-				d.update(conn)
+				d.update(ctx, conn)
 			case <-control.close:
-				ticker.Stop()
-				control.done <- true
+				return
+			case <-ctx.Done():
 				return
 			}
 		}
@@ -62,7 +140,7 @@ func (d *data) Sync(conn Connection, refreshRate time.Duration) io.Closer {
 
 //NewDataModel returns an OPC Data struct.
 func NewDataModel() Collector {
-	return &data{tags: make(map[string]interface{})}
+	return &data{tags: make(map[string]CollectedItem)}
 }
 
 type control struct {
@@ -72,7 +150,10 @@ type control struct {
 
 func (c *control) Close() error {
 	if c.close != nil && c.done != nil {
-		c.close <- true
+		select {
+		case c.close <- true:
+		case <-c.done:
+		}
 		<-c.done
 	}
 	return nil
@@ -4,6 +4,8 @@ import (
 	// "log"
 	// "fmt"
 
+	"context"
+	"errors"
 	"testing"
 	"time"
 )
@@ -95,3 +97,182 @@ func TestOPCDataStopAfterRecord(t *testing.T) {
 		}
 	}
 }
+
+func TestOPCDataKeepsValueOnReadError(t *testing.T) {
+	server := &OpcMockServerBroken{TagList: []string{"tag1", "tag2"}}
+	odata := NewDataModel()
+	running := odata.Sync(server, 50*time.Millisecond)
+	defer running.Close()
+
+	time.Sleep(100 * time.Millisecond)
+	server.Break("tag1")
+	time.Sleep(200 * time.Millisecond)
+
+	value, ok := odata.Get("tag1")
+	if !ok || value.(float64) != 1.0 {
+		t.Fatalf("tag1 should keep its last good value, got %v", value)
+	}
+	value, ok = odata.Get("tag2")
+	if !ok || value.(float64) != 2.0 {
+		t.Fatalf("tag2 does not match, got %v", value)
+	}
+}
+
+func TestOPCDataMarksFailedReads(t *testing.T) {
+	server := &OpcMockServerBroken{TagList: []string{"tag1", "tag2"}}
+	odata := NewDataModel()
+	running := odata.Sync(server, time.Hour)
+	defer running.Close()
+
+	if value, ok := odata.GetGood("tag1"); !ok || value.(float64) != 1.0 {
+		t.Fatalf("tag1 should be good before it breaks, got %v", value)
+	}
+	server.Break("tag1")
+	odata.(*data).update(context.Background(), server)
+
+	if value, ok := odata.GetGood("tag1"); ok || value != nil {
+		t.Fatalf("GetGood should not return the stale value of tag1, got %v", value)
+	}
+	item, ok := odata.GetItem("tag1")
+	if !ok || item.Err == nil || item.Value.(float64) != 1.0 {
+		t.Fatalf("tag1 should keep its value with the error, got %v", item)
+	}
+	if value, ok := odata.GetGood("tag2"); !ok || value.(float64) != 2.0 {
+		t.Fatalf("tag2 should stay good, got %v", value)
+	}
+	if item, _ := odata.GetItem("tag2"); item.Err != nil {
+		t.Fatalf("tag2 should have no error, got %v", item.Err)
+	}
+}
+
+func TestOPCDataMarksLostConnection(t *testing.T) {
+	server := &OpcMockServerStatic{TagList: []string{"tag1"}}
+	odata := NewDataModel()
+	running := odata.Sync(server, time.Hour)
+	defer running.Close()
+
+	odata.(*data).update(context.Background(), &opcMockServerLost{server})
+	if item, ok := odata.GetItem("tag1"); !ok || !errors.Is(item.Err, ErrNotConnected) || item.Value.(float64) != 1.0 {
+		t.Fatalf("tag1 should keep its value with the error of the read, got %v", item)
+	}
+	if _, ok := odata.GetGood("tag1"); ok {
+		t.Fatal("GetGood should not return values after a failed read")
+	}
+	odata.(*data).update(context.Background(), server)
+	if item, ok := odata.GetItem("tag1"); !ok || item.Err != nil {
+		t.Fatalf("a successful read should clear the error, got %v", item)
+	}
+}
+
+//opcMockServerLost implements an OPC Server whose reads fail as a whole.
+type opcMockServerLost struct {
+	*OpcMockServerStatic
+}
+
+func (oms *opcMockServerLost) ReadContext(ctx context.Context) (map[string]ReadResult, error) {
+	return nil, ErrNotConnected
+}
+
+func TestItems(t *testing.T) {
+	results := map[string]ReadResult{
+		"tag1": {Item: Item{Value: 1.0}},
+		"tag2": {Err: ErrTagNotFound},
+	}
+	items := Items(results)
+	if len(items) != 1 || items["tag1"].Value != 1.0 {
+		t.Fatalf("unexpected items %v", items)
+	}
+
+	item, err := itemFromResults(results, nil, "tag3")
+	if err != ErrTagNotFound || item != (Item{}) {
+		t.Fatal("missing tag should return ErrTagNotFound")
+	}
+}
+
+func TestOPCDataSyncContextReadSource(t *testing.T) {
+	odata := NewDataModel()
+	server := &OpcMockServerSource{TagList: []string{"tag1"}}
+	ctx, cancel := context.WithCancel(WithReadSource(context.Background(), SourceDevice))
+	running := odata.SyncContext(ctx, server, 20*time.Millisecond)
+	defer running.Close()
+
+	time.Sleep(70 * time.Millisecond)
+	if value, ok := odata.Get("tag1"); !ok || value.(float64) != float64(OPCDevice) {
+		t.Fatal("tag1 should be read from the device")
+	}
+	for _, src := range server.Sources() {
+		if src != SourceDevice {
+			t.Fatalf("unexpected read source %v", src)
+		}
+	}
+
+	cancel()
+	time.Sleep(30 * time.Millisecond)
+	reads := len(server.Sources())
+	time.Sleep(70 * time.Millisecond)
+	if len(server.Sources()) != reads {
+		t.Fatal("sync should stop when the context is done")
+	}
+}
+
+//opcMockServerQuality returns the values of OpcMockServerStatic with bad
+//quality for the tags in Bad.
+type opcMockServerQuality struct {
+	*OpcMockServerStatic
+	Bad map[string]bool
+}
+
+func (oms *opcMockServerQuality) ReadContext(ctx context.Context) (map[string]ReadResult, error) {
+	results, err := oms.OpcMockServerStatic.ReadContext(ctx)
+	for tag, result := range results {
+		if oms.Bad[tag] {
+			result.Quality = OPCQualityBad
+			results[tag] = result
+		}
+	}
+	return results, err
+}
+
+func TestOPCDataQuality(t *testing.T) {
+	server := &opcMockServerQuality{
+		OpcMockServerStatic: &OpcMockServerStatic{TagList: []string{"tag1", "tag2"}},
+		Bad:                 map[string]bool{"tag2": true},
+	}
+	odata := NewDataModel()
+	before := time.Now()
+	running := odata.Sync(server, time.Hour)
+	defer running.Close()
+
+	item, ok := odata.GetItem("tag1")
+	if !ok || item.Value.(float64) != 1.0 || !item.Good() {
+		t.Fatalf("unexpected item for tag1: %v", item)
+	}
+	if item.Received.Before(before) || item.Received.After(time.Now()) || item.Timestamp.IsZero() {
+		t.Fatalf("unexpected times for tag1: %v", item)
+	}
+
+	if value, ok := odata.Get("tag2"); !ok || value.(float64) != 2.0 {
+		t.Fatal("Get should return values of bad quality")
+	}
+	if item, ok := odata.GetItem("tag2"); !ok || item.Quality != OPCQualityBad {
+		t.Fatalf("tag2 should have bad quality, got %v", item.Quality)
+	}
+	if value, ok := odata.GetGood("tag2"); ok || value != nil {
+		t.Fatal("GetGood should not return values of bad quality")
+	}
+	if value, ok := odata.GetGood("tag1"); !ok || value.(float64) != 1.0 {
+		t.Fatal("GetGood should return values of good quality")
+	}
+	if _, ok := odata.GetGood("tag3"); ok {
+		t.Fatal("tag3 should not be found")
+	}
+
+	snapshot := odata.Snapshot()
+	if len(snapshot) != 2 || snapshot["tag1"].Received != snapshot["tag2"].Received {
+		t.Fatalf("unexpected snapshot %v", snapshot)
+	}
+	delete(snapshot, "tag1")
+	if _, ok := odata.GetItem("tag1"); !ok {
+		t.Fatal("snapshot should be a copy")
+	}
+}
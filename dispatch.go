@@ -0,0 +1,83 @@
+package opcda
+
+import (
+	"fmt"
+)
+
+//dispatcher is an object of the OPC Automation Wrapper, such as the OPCServer,
+//an OPCGroup or an OPCBrowser. Results which are objects themselves are
+//returned as dispatcher and must be released by the caller; arrays are
+//returned as []interface{}.
+//
+//The go-ole implementation is used on Windows; the tests use an in-memory
+//fake of the object model.
+type dispatcher interface {
+	call(method string, args ...interface{}) (interface{}, error)
+	get(property string, args ...interface{}) (interface{}, error)
+	put(property string, value interface{}) error
+	release()
+}
+
+//callObject calls method and returns the object it returns.
+func callObject(d dispatcher, method string, args ...interface{}) (dispatcher, error) {
+	v, err := d.call(method, args...)
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := v.(dispatcher)
+	if !ok || obj == nil {
+		return nil, fmt.Errorf("%s returned no object", method)
+	}
+	return obj, nil
+}
+
+//getObject returns the object of property.
+func getObject(d dispatcher, property string) (dispatcher, error) {
+	v, err := d.get(property)
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := v.(dispatcher)
+	if !ok || obj == nil {
+		return nil, fmt.Errorf("%s is no object", property)
+	}
+	return obj, nil
+}
+
+//callString calls method and returns the string it returns.
+func callString(d dispatcher, method string, args ...interface{}) (string, error) {
+	v, err := d.call(method, args...)
+	if err != nil {
+		return "", err
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("%s returned %T instead of a string", method, v)
+	}
+	return s, nil
+}
+
+//getInt32 returns the integer value of property.
+func getInt32(d dispatcher, property string) (int32, error) {
+	v, err := d.get(property)
+	if err != nil {
+		return 0, err
+	}
+	i, ok := toInt64(v)
+	if !ok {
+		return 0, fmt.Errorf("%s is %T instead of an integer", property, v)
+	}
+	return int32(i), nil
+}
+
+//nonEmptyStrings returns the non-empty strings of an array result.
+func nonEmptyStrings(v interface{}) []string {
+	var found []string
+	values, _ := v.([]interface{})
+	for _, value := range values {
+		if s, ok := value.(string); ok && s != "" {
+			found = append(found, s)
+		}
+	}
+	return found
+}
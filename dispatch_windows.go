@@ -0,0 +1,76 @@
+//go:build windows
+// +build windows
+
+package opcda
+
+import (
+	ole "github.com/go-ole/go-ole"
+	"github.com/go-ole/go-ole/oleutil"
+)
+
+// oleDispatcher is the dispatcher of a COM object.
+type oleDispatcher struct {
+	*ole.IDispatch
+}
+
+// newOleDispatcher returns a dispatcher for disp, or nil if disp is nil.
+// The dispatcher takes over the reference of disp.
+func newOleDispatcher(disp *ole.IDispatch) dispatcher {
+	if disp == nil {
+		return nil
+	}
+	return &oleDispatcher{disp}
+}
+
+// oleDispatch returns the COM object of d, or nil if d has none.
+func oleDispatch(d dispatcher) *ole.IDispatch {
+	if od, ok := d.(*oleDispatcher); ok {
+		return od.IDispatch
+	}
+	return nil
+}
+
+// result converts v into a dispatcher, an array or a value.
+func result(v *ole.VARIANT) interface{} {
+	if v == nil {
+		return nil
+	}
+	switch {
+	case v.VT == ole.VT_DISPATCH:
+		return newOleDispatcher(v.ToIDispatch())
+	case v.VT&ole.VT_ARRAY != 0:
+		values := v.ToArray().ToValueArray()
+		v.Clear()
+		return values
+	}
+	value := v.Value()
+	if v.VT == ole.VT_BSTR {
+		v.Clear()
+	}
+	return value
+}
+
+func (d *oleDispatcher) call(method string, args ...interface{}) (interface{}, error) {
+	v, err := oleutil.CallMethod(d.IDispatch, method, args...)
+	if err != nil {
+		return nil, err
+	}
+	return result(v), nil
+}
+
+func (d *oleDispatcher) get(property string, args ...interface{}) (interface{}, error) {
+	v, err := oleutil.GetProperty(d.IDispatch, property, args...)
+	if err != nil {
+		return nil, err
+	}
+	return result(v), nil
+}
+
+func (d *oleDispatcher) put(property string, value interface{}) error {
+	_, err := oleutil.PutProperty(d.IDispatch, property, value)
+	return err
+}
+
+func (d *oleDispatcher) release() {
+	d.IDispatch.Release()
+}
@@ -0,0 +1,153 @@
+package opcda
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	//ErrNotConnected is returned when the server is not connected or cannot be
+	//connected. A *ConnectError matches it with errors.Is.
+	ErrNotConnected = errors.New("not connected")
+	//ErrTagNotFound is returned when reading a tag that has not been added.
+	ErrTagNotFound = errors.New("tag not found")
+	//ErrUnsupportedPlatform is returned by the functions which need the OPC
+	//Automation Wrapper on platforms other than Windows.
+	ErrUnsupportedPlatform = errors.New("OPC DA is only supported on windows")
+	//ErrReconnecting is returned while a lost connection is re-established.
+	//It matches ErrNotConnected with errors.Is.
+	ErrReconnecting = fmt.Errorf("reconnecting: %w", ErrNotConnected)
+)
+
+//AddItemError is returned when a tag cannot be added to an OPC group, e.g.
+//because the server does not know it. HRESULT is the code returned by the
+//server, or zero if there was none.
+type AddItemError struct {
+	Tag     string
+	HRESULT HRESULT
+	Err     error
+}
+
+func (e *AddItemError) Error() string {
+	return fmt.Sprintf("cannot add tag %s: %s", e.Tag, e.Err)
+}
+
+//Unwrap returns the cause, which is the HRESULT if the server returned one.
+func (e *AddItemError) Unwrap() error {
+	return e.Err
+}
+
+//ConnectError is returned when the server cannot be connected on a node.
+//It matches ErrNotConnected with errors.Is.
+type ConnectError struct {
+	Server string
+	Node   string
+	Err    error
+}
+
+func (e *ConnectError) Error() string {
+	return fmt.Sprintf("cannot connect to %s on node %s: %s", e.Server, e.Node, e.Err)
+}
+
+func (e *ConnectError) Unwrap() error {
+	return e.Err
+}
+
+//Is makes every ConnectError match ErrNotConnected.
+func (e *ConnectError) Is(target error) bool {
+	return target == ErrNotConnected
+}
+
+//ReconnectError is passed to ReconnectPolicy.OnReconnectFailed when the
+//policy gives up. Err is the error of the last attempt.
+//It matches ErrNotConnected with errors.Is.
+type ReconnectError struct {
+	Attempts int
+	Elapsed  time.Duration
+	Err      error
+}
+
+func (e *ReconnectError) Error() string {
+	return fmt.Sprintf("gave up reconnecting after %d attempts in %s: %s", e.Attempts, e.Elapsed, e.Err)
+}
+
+func (e *ReconnectError) Unwrap() error {
+	return e.Err
+}
+
+//Is makes every ReconnectError match ErrNotConnected.
+func (e *ReconnectError) Is(target error) bool {
+	return target == ErrNotConnected
+}
+
+//MultiError holds the errors of a batch, e.g. of the tags added together or
+//the nodes tried by a connect. errors.Is and errors.As match it if they match
+//any of its errors.
+type MultiError []error
+
+func (m MultiError) Error() string {
+	texts := make([]string, len(m))
+	for i, err := range m {
+		texts[i] = err.Error()
+	}
+	return strings.Join(texts, "; ")
+}
+
+//Is reports if any of the errors matches target.
+func (m MultiError) Is(target error) bool {
+	for _, err := range m {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+//As finds the first error which matches target.
+func (m MultiError) As(target interface{}) bool {
+	for _, err := range m {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+//Unwrap returns the errors.
+func (m MultiError) Unwrap() []error {
+	return m
+}
+
+//multiError returns nil for no errors and the errors as MultiError otherwise.
+func multiError(errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return MultiError(errs)
+}
+
+//oleCallError is a failed COM call. It keeps the text of the OLE error and
+//unwraps to its HRESULT.
+type oleCallError struct {
+	text string
+	code HRESULT
+}
+
+func (e *oleCallError) Error() string {
+	return e.text
+}
+
+func (e *oleCallError) Unwrap() error {
+	return e.code
+}
+
+//hresultOf returns the HRESULT of err, or zero if it has none.
+func hresultOf(err error) HRESULT {
+	var hr HRESULT
+	if errors.As(err, &hr) {
+		return hr
+	}
+	return 0
+}
@@ -0,0 +1,81 @@
+package opcda
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+func TestAddItemError(t *testing.T) {
+	var err error = &AddItemError{Tag: "unknown.tag", HRESULT: OPCErrUnknownItemID, Err: OPCErrUnknownItemID}
+	err = multiError([]error{err, &AddItemError{Tag: "other.tag", Err: errors.New("val is 0")}})
+
+	var addErr *AddItemError
+	if !errors.As(err, &addErr) || addErr.Tag != "unknown.tag" {
+		t.Fatalf("AddItemError should be found in %v", err)
+	}
+	if !errors.Is(err, OPCErrUnknownItemID) {
+		t.Fatal("HRESULT should be found with errors.Is")
+	}
+	if errors.Is(err, ErrNotConnected) {
+		t.Fatal("a bad tag is no connection error")
+	}
+	if err.Error() != "cannot add tag unknown.tag: "+OPCErrUnknownItemID.Error()+"; cannot add tag other.tag: val is 0" {
+		t.Fatalf("unexpected text %q", err)
+	}
+}
+
+func TestConnectError(t *testing.T) {
+	err := multiError([]error{
+		&ConnectError{Server: "Graybox.Simulator", Node: "host1", Err: errors.New("timeout")},
+		&ConnectError{Server: "Graybox.Simulator", Node: "host2", Err: HRESULT(0x800706BA)},
+	})
+	if !errors.Is(err, ErrNotConnected) {
+		t.Fatal("ConnectError should match ErrNotConnected")
+	}
+	var connErr *ConnectError
+	if !errors.As(err, &connErr) || connErr.Node != "host1" {
+		t.Fatal("ConnectError should be found with errors.As")
+	}
+	var hr HRESULT
+	if !errors.As(err, &hr) || hr != 0x800706BA {
+		t.Fatal("HRESULT of the second node should be found")
+	}
+}
+
+func TestMultiError(t *testing.T) {
+	if multiError(nil) != nil {
+		t.Fatal("no errors should be nil")
+	}
+	err := fmt.Errorf("batch failed: %w", multiError([]error{ErrTagNotFound}))
+	if !errors.Is(err, ErrTagNotFound) {
+		t.Fatal("wrapped MultiError should be unwrapped")
+	}
+}
+
+func TestRefineOleError(t *testing.T) {
+	err := refineOleError(ole.NewError(0x80004005))
+	if !errors.Is(err, HRESULT(0x80004005)) || hresultOf(err) != 0x80004005 {
+		t.Fatalf("refined error should unwrap to its HRESULT, got %v", err)
+	}
+	plain := errors.New("plain")
+	if refineOleError(plain) != plain || hresultOf(plain) != 0 {
+		t.Fatal("other errors should be left as they are")
+	}
+}
+
+func TestItemErrorHRESULT(t *testing.T) {
+	io := &fakeSyncIO{errs: map[int32]int32{1: testUnknownItemID}}
+	results, err := syncReadTags(io, OPCCache, map[string]int32{"tag1": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(results["tag1"].Err, OPCErrUnknownItemID) {
+		t.Fatalf("item error should match its HRESULT, got %v", results["tag1"].Err)
+	}
+	if OPCSuccessClamp.Failed() || !OPCErrBadRights.Failed() {
+		t.Fatal("Failed does not distinguish success and error codes")
+	}
+}
@@ -0,0 +1,470 @@
+package opcda
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+//fakeMethod is the behavior of a method of a fakeObject.
+type fakeMethod func(args ...interface{}) (interface{}, error)
+
+//fakeObject is a scriptable dispatcher. Methods and properties can be
+//replaced by tests, and failures injected with fail.
+type fakeObject struct {
+	mu       sync.Mutex
+	name     string
+	methods  map[string]fakeMethod
+	props    map[string]interface{}
+	failures map[string]error
+	calls    []string
+	released int
+}
+
+func newFakeObject(name string) *fakeObject {
+	return &fakeObject{
+		name:     name,
+		methods:  make(map[string]fakeMethod),
+		props:    make(map[string]interface{}),
+		failures: make(map[string]error),
+	}
+}
+
+//on sets the behavior of method.
+func (o *fakeObject) on(method string, f fakeMethod) *fakeObject {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.methods[method] = f
+	return o
+}
+
+//fail makes every call of the method or property name fail with err,
+//or succeed again if err is nil.
+func (o *fakeObject) fail(name string, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if err == nil {
+		delete(o.failures, name)
+		return
+	}
+	o.failures[name] = err
+}
+
+func (o *fakeObject) prop(name string) interface{} {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.props[name]
+}
+
+func (o *fakeObject) setProp(name string, value interface{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.props[name] = value
+}
+
+//called returns the number of calls of method.
+func (o *fakeObject) called(method string) int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	n := 0
+	for _, call := range o.calls {
+		if call == method {
+			n++
+		}
+	}
+	return n
+}
+
+//enter records the call of name and returns its injected failure.
+func (o *fakeObject) enter(name string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.calls = append(o.calls, name)
+	if o.released > 0 {
+		return fmt.Errorf("%s: %s called after release", o.name, name)
+	}
+	return o.failures[name]
+}
+
+func (o *fakeObject) call(method string, args ...interface{}) (interface{}, error) {
+	if err := o.enter(method); err != nil {
+		return nil, err
+	}
+	o.mu.Lock()
+	f, ok := o.methods[method]
+	o.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%s has no method %s", o.name, method)
+	}
+	return f(args...)
+}
+
+func (o *fakeObject) get(property string, args ...interface{}) (interface{}, error) {
+	if err := o.enter(property); err != nil {
+		return nil, err
+	}
+	o.mu.Lock()
+	v, ok := o.props[property]
+	o.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%s has no property %s", o.name, property)
+	}
+	if f, ok := v.(func() interface{}); ok {
+		return f(), nil
+	}
+	return v, nil
+}
+
+func (o *fakeObject) put(property string, value interface{}) error {
+	if err := o.enter(property); err != nil {
+		return err
+	}
+	o.setProp(property, value)
+	return nil
+}
+
+func (o *fakeObject) isReleased() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.released > 0
+}
+
+func (o *fakeObject) release() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.released++
+}
+
+//fakeServer emulates the OPCServer object of the OPC Automation Wrapper with
+//its groups, items and browser over the tags of namespace. The values of the
+//tags are kept in values.
+type fakeServer struct {
+	*fakeObject
+	namespace *Tree
+	mu        sync.Mutex
+	groups    []*fakeGroup
+	items     []*fakeObject
+	browsers  []*fakeObject
+	values    map[string]interface{}
+	handle    int32
+}
+
+func newFakeServer(namespace *Tree) *fakeServer {
+	s := &fakeServer{fakeObject: newFakeObject("OPCServer"), namespace: namespace, values: make(map[string]interface{})}
+	s.setProp("ServerState", int32(OPCDisconnected))
+	s.setProp("PublicGroupNames", []interface{}{})
+	s.setProp("ServerName", "Fake.Simulator")
+	s.setProp("OPCGroups", func() interface{} { return s.newGroups() })
+	s.on("Connect", func(args ...interface{}) (interface{}, error) {
+		s.setProp("ServerState", int32(OPCRunning))
+		s.setProp("ServerNode", args[1])
+		return nil, nil
+	})
+	s.on("Disconnect", func(args ...interface{}) (interface{}, error) {
+		s.setProp("ServerState", int32(OPCDisconnected))
+		return nil, nil
+	})
+	s.on("GetOPCServers", func(args ...interface{}) (interface{}, error) {
+		return []interface{}{"Fake.Simulator", ""}, nil
+	})
+	s.on("CreateBrowser", func(args ...interface{}) (interface{}, error) {
+		return s.newBrowser(), nil
+	})
+	return s
+}
+
+//crash makes the server fail like a lost connection.
+func (s *fakeServer) crash() {
+	s.setProp("ServerState", int32(OPCFailed))
+}
+
+//running reports if the server is connected and has not crashed.
+func (s *fakeServer) running() bool {
+	return s.prop("ServerState") == int32(OPCRunning)
+}
+
+//value returns the value of the tag.
+func (s *fakeServer) value(tag string) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.values[tag]
+}
+
+//setValue changes the value of the tag.
+func (s *fakeServer) setValue(tag string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[tag] = value
+}
+
+//itemOf returns the item ID of the item with the server handle, if the item
+//has not been released.
+func (s *fakeServer) itemOf(handle int32) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, item := range s.items {
+		if item.prop("ServerHandle") == handle && !item.isReleased() {
+			return item.prop("ItemID").(string), true
+		}
+	}
+	return "", false
+}
+
+//newAutomationObject returns an AutomationObject for the fake server.
+func (s *fakeServer) newAutomationObject(opts ...Option) *AutomationObject {
+	o, err := newOptions(opts)
+	if err != nil {
+		panic(err)
+	}
+	return &AutomationObject{opc: s, opts: o}
+}
+
+func (s *fakeServer) itemID(tag string) bool {
+	var found bool
+	var walk func(*Tree)
+	walk = func(t *Tree) {
+		for _, l := range t.Leaves {
+			found = found || l.ItemId == tag
+		}
+		for _, b := range t.Branches {
+			walk(b)
+		}
+	}
+	walk(s.namespace)
+	return found
+}
+
+func (s *fakeServer) newGroups() *fakeObject {
+	groups := newFakeObject("OPCGroups")
+	groups.on("Add", func(args ...interface{}) (interface{}, error) {
+		group := &fakeGroup{fakeObject: newFakeObject("OPCGroup"), server: s}
+		if len(args) > 0 {
+			group.setProp("Name", args[0])
+		}
+		group.setProp("UpdateRate", int32(1000))
+		group.setProp("DeadBand", float32(0))
+		group.setProp("OPCItems", func() interface{} { return s.newItems() })
+		s.mu.Lock()
+		s.groups = append(s.groups, group)
+		s.mu.Unlock()
+		return group, nil
+	})
+	return groups
+}
+
+//fakeGroup is an OPCGroup whose SyncRead and SyncWrite read and write the
+//values of the server. They fail like a lost connection if the server is not
+//running.
+type fakeGroup struct {
+	*fakeObject
+	server *fakeServer
+}
+
+func (g *fakeGroup) syncRead(source int32, handles []int32) ([]interface{}, []Quality, []time.Time, []int32, error) {
+	if err := g.enter("SyncRead"); err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if !g.server.running() {
+		return nil, nil, nil, nil, testServerUnavailable
+	}
+	values := make([]interface{}, len(handles))
+	qualities := make([]Quality, len(handles))
+	timestamps := make([]time.Time, len(handles))
+	errs := make([]int32, len(handles))
+	for i, handle := range handles {
+		tag, ok := g.server.itemOf(handle)
+		if !ok {
+			errs[i] = testInvalidHandle
+			continue
+		}
+		values[i] = g.server.value(tag)
+		qualities[i] = OPCQualityGood
+		timestamps[i] = time.Now()
+	}
+	return values, qualities, timestamps, errs, nil
+}
+
+func (g *fakeGroup) syncWrite(handles []int32, values []interface{}) ([]int32, error) {
+	if err := g.enter("SyncWrite"); err != nil {
+		return nil, err
+	}
+	if !g.server.running() {
+		return nil, testServerUnavailable
+	}
+	errs := make([]int32, len(handles))
+	for i, handle := range handles {
+		tag, ok := g.server.itemOf(handle)
+		if !ok {
+			errs[i] = testInvalidHandle
+			continue
+		}
+		g.server.setValue(tag, values[i])
+	}
+	return errs, nil
+}
+
+//RPC_S_SERVER_UNAVAILABLE and OPC_E_INVALIDHANDLE
+const (
+	testServerUnavailable HRESULT = 0x800706BA
+	testInvalidHandle     int32   = -1073479679
+)
+
+func (s *fakeServer) newItems() *fakeObject {
+	items := newFakeObject("OPCItems")
+	items.on("AddItem", func(args ...interface{}) (interface{}, error) {
+		tag := args[0].(string)
+		if !s.itemID(tag) {
+			return nil, OPCErrUnknownItemID
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.handle++
+		item := newFakeObject("OPCItem " + tag)
+		item.setProp("ItemID", tag)
+		item.setProp("ClientHandle", args[1])
+		item.setProp("ServerHandle", s.handle)
+		s.items = append(s.items, item)
+		return item, nil
+	})
+	return items
+}
+
+//liveItems returns the item IDs of the items which have not been released.
+func (s *fakeServer) liveItems() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var live []string
+	for _, item := range s.items {
+		if !item.isReleased() {
+			live = append(live, item.prop("ItemID").(string))
+		}
+	}
+	return live
+}
+
+func (s *fakeServer) newBrowser() *fakeObject {
+	b := newFakeObject("OPCBrowser")
+	var path []*Tree
+	var collection []string
+	current := func() *Tree {
+		if len(path) == 0 {
+			return s.namespace
+		}
+		return path[len(path)-1]
+	}
+	child := func(name string) *Tree {
+		for _, branch := range current().Branches {
+			if branch.Name == name {
+				return branch
+			}
+		}
+		return nil
+	}
+	b.setProp("Count", func() interface{} { return int32(len(collection)) })
+	b.setProp("CurrentPosition", func() interface{} {
+		names := make([]string, len(path))
+		for i, t := range path {
+			names[i] = t.Name
+		}
+		return strings.Join(names, ".")
+	})
+	b.on("MoveToRoot", func(args ...interface{}) (interface{}, error) {
+		path = nil
+		return nil, nil
+	})
+	b.on("MoveUp", func(args ...interface{}) (interface{}, error) {
+		if len(path) > 0 {
+			path = path[:len(path)-1]
+		}
+		return nil, nil
+	})
+	b.on("MoveDown", func(args ...interface{}) (interface{}, error) {
+		next := child(args[0].(string))
+		if next == nil {
+			return nil, OPCErrInvalidItemID
+		}
+		path = append(path, next)
+		return nil, nil
+	})
+	b.on("MoveTo", func(args ...interface{}) (interface{}, error) {
+		path = nil
+		for _, name := range args[0].([]string) {
+			next := child(name)
+			if next == nil {
+				return nil, OPCErrInvalidItemID
+			}
+			path = append(path, next)
+		}
+		return nil, nil
+	})
+	b.on("ShowBranches", func(args ...interface{}) (interface{}, error) {
+		collection = nil
+		for _, branch := range current().Branches {
+			collection = append(collection, branch.Name)
+		}
+		return nil, nil
+	})
+	b.on("ShowLeafs", func(args ...interface{}) (interface{}, error) {
+		collection = nil
+		for _, leaf := range current().Leaves {
+			collection = append(collection, leaf.Name)
+		}
+		return nil, nil
+	})
+	b.on("Item", func(args ...interface{}) (interface{}, error) {
+		i, _ := toInt64(args[0])
+		if i < 1 || int(i) > len(collection) {
+			return nil, errors.New("index out of range")
+		}
+		return collection[i-1], nil
+	})
+	b.on("GetItemID", func(args ...interface{}) (interface{}, error) {
+		for _, leaf := range current().Leaves {
+			if leaf.Name == args[0] {
+				return leaf.ItemId, nil
+			}
+		}
+		return nil, OPCErrInvalidItemID
+	})
+	s.mu.Lock()
+	s.browsers = append(s.browsers, b)
+	s.mu.Unlock()
+	return b
+}
+
+//fakeNamespace returns a small namespace like the one of the Graybox Simulator.
+func fakeNamespace() *Tree {
+	root := &Tree{Name: "root", Branches: []*Tree{}, Leaves: []Leaf{}}
+	numeric := &Tree{Name: "numeric", Parent: root, Branches: []*Tree{}, Leaves: []Leaf{
+		{Name: "sin.float", ItemId: "numeric.sin.float"},
+		{Name: "saw.int64", ItemId: "numeric.saw.int64"},
+	}}
+	textual := &Tree{Name: "textual", Parent: root, Branches: []*Tree{}, Leaves: []Leaf{
+		{Name: "random", ItemId: "textual.random"},
+	}}
+	nested := &Tree{Name: "nested", Parent: textual, Branches: []*Tree{}, Leaves: []Leaf{
+		{Name: "deep", ItemId: "textual.nested.deep"},
+	}}
+	textual.Branches = append(textual.Branches, nested)
+	root.Branches = append(root.Branches, numeric, textual)
+	root.Leaves = append(root.Leaves, Leaf{Name: "status", ItemId: "status"})
+	return root
+}
+
+//NewFakeConnection returns the connection of the package to a new fake server
+//with the tags of fakeNamespace and no tags added, so that the conformance
+//tests run against opcConnectionImpl.
+func NewFakeConnection() (Connection, error) {
+	server := newFakeServer(fakeNamespace())
+	server.setValue("numeric.sin.float", 1.5)
+	server.setValue("numeric.saw.int64", int64(7))
+	server.setValue("textual.random", "text")
+	ao := server.newAutomationObject(WithReconnectPolicy(ReconnectPolicy{Interval: 10 * time.Millisecond}))
+	items, err := ao.Connect("Fake.Simulator", "localhost")
+	if err != nil {
+		return nil, err
+	}
+	return newConnection(ao, items, "Fake.Simulator", []string{"localhost"}, ao.opts, newStateMachine()), nil
+}
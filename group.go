@@ -0,0 +1,57 @@
+package opcda
+
+import (
+	"errors"
+	"time"
+)
+
+//GroupSettings holds the settings of an OPC group.
+type GroupSettings struct {
+	//UpdateRate is the rate at which the server refreshes the cache of the
+	//group. The server may revise it, Group.Settings returns the revised rate.
+	//Zero requests the fastest rate the server supports.
+	UpdateRate time.Duration
+	//DeadBand is the percent deadband (0 to 100) for analog items: a new value
+	//is only taken into the cache if it differs by more than DeadBand percent
+	//of the engineering unit range.
+	DeadBand float32
+	//Active enables the group. Reading the cache of an inactive group returns
+	//items with bad quality.
+	Active bool
+	//Subscribed makes the server raise DataChange events for the group. Some
+	//servers default to false, so the group never reports changes without it.
+	//A group with asynchronous reads or writes stays subscribed, because their
+	//completions are events too.
+	Subscribed bool
+}
+
+//validate checks the settings before they are sent to the server.
+func (s GroupSettings) validate() error {
+	if s.UpdateRate < 0 {
+		return errors.New("update rate must not be negative")
+	}
+	if s.DeadBand < 0 || s.DeadBand > 100 {
+		return errors.New("deadband must be between 0 and 100 percent")
+	}
+	return nil
+}
+
+//Group is a named OPC group with its own tags and settings. It is a Connection
+//for its tags, so it can be read, written and synchronized like one. Closing
+//a Group removes it from its connection.
+type Group interface {
+	Connection
+	Name() string
+	Settings() GroupSettings
+	SetSettings(GroupSettings) error
+}
+
+//GroupManager is implemented by connections which can manage several named
+//OPC groups next to the group of the tags added to the connection itself.
+//Groups are re-created with their settings and tags after a reconnect.
+type GroupManager interface {
+	AddGroup(name string, settings GroupSettings) (Group, error)
+	Group(name string) (Group, bool)
+	RemoveGroup(name string) error
+	Groups() []string
+}
@@ -0,0 +1,30 @@
+package opcda
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGroupSettingsValidate(t *testing.T) {
+	var config = []struct {
+		Settings GroupSettings
+		Valid    bool
+	}{
+		{GroupSettings{}, true},
+		{GroupSettings{UpdateRate: 250 * time.Millisecond, DeadBand: 0.5, Active: true, Subscribed: true}, true},
+		{GroupSettings{UpdateRate: 10 * time.Second, DeadBand: 100}, true},
+		{GroupSettings{UpdateRate: -time.Second}, false},
+		{GroupSettings{DeadBand: -1}, false},
+		{GroupSettings{DeadBand: 100.1}, false},
+	}
+
+	for _, cfg := range config {
+		err := cfg.Settings.validate()
+		if cfg.Valid && err != nil {
+			t.Errorf("%+v should be valid: %s", cfg.Settings, err)
+		}
+		if !cfg.Valid && err == nil {
+			t.Errorf("%+v should not be valid", cfg.Settings)
+		}
+	}
+}
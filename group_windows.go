@@ -0,0 +1,349 @@
+//go:build windows
+// +build windows
+
+package opcda
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	ole "github.com/go-ole/go-ole"
+	"github.com/go-ole/go-ole/oleutil"
+)
+
+// addGroup adds a named OPC group with settings to the server.
+// It returns the group and the AutomationItems for its OPCItems.
+func (ao *AutomationObject) addGroup(name string, settings GroupSettings) (*ole.IDispatch, *AutomationItems, error) {
+	opcGroups, err := oleutil.GetProperty(oleDispatch(ao.opc), "OPCGroups")
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot get OPCGroups property: %w", refineOleError(err))
+	}
+	defer opcGroups.ToIDispatch().Release()
+
+	opcGrp, err := oleutil.CallMethod(opcGroups.ToIDispatch(), "Add", name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot add OPC Group %s: %w", name, refineOleError(err))
+	}
+	group := opcGrp.ToIDispatch()
+	if err := applyGroupSettings(group, settings); err != nil {
+		oleutil.CallMethod(opcGroups.ToIDispatch(), "Remove", name)
+		group.Release()
+		return nil, nil, err
+	}
+	addItemObject, err := oleutil.GetProperty(group, "OPCItems")
+	if err != nil {
+		oleutil.CallMethod(opcGroups.ToIDispatch(), "Remove", name)
+		group.Release()
+		return nil, nil, fmt.Errorf("cannot get OPC Items: %w", refineOleError(err))
+	}
+	items := NewAutomationItems(addItemObject.ToIDispatch())
+	group.AddRef()
+	items.group = newOleDispatcher(group)
+	items.out = ao.opts.logger
+	return group, items, nil
+}
+
+// removeGroup removes the named OPC group from the server.
+func (ao *AutomationObject) removeGroup(name string) error {
+	opcGroups, err := oleutil.GetProperty(oleDispatch(ao.opc), "OPCGroups")
+	if err != nil {
+		return fmt.Errorf("cannot get OPCGroups property: %w", refineOleError(err))
+	}
+	defer opcGroups.ToIDispatch().Release()
+	if _, err := oleutil.CallMethod(opcGroups.ToIDispatch(), "Remove", name); err != nil {
+		return fmt.Errorf("cannot remove OPC Group %s: %w", name, refineOleError(err))
+	}
+	return nil
+}
+
+// applyGroupSettings sets the update rate, deadband, active and subscribed
+// state of group. It is also called when the group is re-created.
+func applyGroupSettings(group *ole.IDispatch, settings GroupSettings) error {
+	rate := int32(settings.UpdateRate / time.Millisecond)
+	if _, err := oleutil.PutProperty(group, "UpdateRate", rate); err != nil {
+		return fmt.Errorf("cannot set UpdateRate: %w", refineOleError(err))
+	}
+	if _, err := oleutil.PutProperty(group, "DeadBand", settings.DeadBand); err != nil {
+		return fmt.Errorf("cannot set DeadBand: %w", refineOleError(err))
+	}
+	if _, err := oleutil.PutProperty(group, "IsActive", settings.Active); err != nil {
+		return fmt.Errorf("cannot set IsActive: %w", refineOleError(err))
+	}
+	if _, err := oleutil.PutProperty(group, "IsSubscribed", settings.Subscribed); err != nil {
+		return fmt.Errorf("cannot set IsSubscribed: %w", refineOleError(err))
+	}
+	return nil
+}
+
+// revisedUpdateRate returns the update rate the server has chosen for group.
+func revisedUpdateRate(group *ole.IDispatch) (time.Duration, bool) {
+	rate, err := oleutil.GetProperty(group, "UpdateRate")
+	if err != nil {
+		return 0, false
+	}
+	ms, ok := rate.Value().(int32)
+	if !ok {
+		return 0, false
+	}
+	return time.Duration(ms) * time.Millisecond, true
+}
+
+// platformConn holds the named groups of a connection.
+type platformConn struct {
+	groups map[string]*opcGroup
+}
+
+// recreateGroups adds the groups and their tags again after a reconnect.
+func (p *platformConn) recreateGroups(out *log.Logger) {
+	for _, g := range p.groups {
+		if err := g.recreate(); err != nil {
+			out.Printf("Cannot re-create group %s: %s", g.name, err)
+		}
+	}
+}
+
+// releaseGroups releases the groups when the connection is closed.
+func (p *platformConn) releaseGroups() {
+	for name, g := range p.groups {
+		g.release()
+		delete(p.groups, name)
+	}
+}
+
+// opcGroup implements the Group interface for a named group of an opcConnectionImpl.
+// All calls are serialized by the lock of the connection.
+type opcGroup struct {
+	conn     *opcConnectionImpl
+	name     string
+	settings GroupSettings
+	group    *ole.IDispatch
+	items    *AutomationItems
+	lost     []string // tags of a group that could not be re-created
+}
+
+// create adds the group to the server and updates the revised settings.
+func (g *opcGroup) create() error {
+	group, items, err := g.conn.AutomationObject.addGroup(g.name, g.settings)
+	if err != nil {
+		return err
+	}
+	g.group = group
+	g.items = items
+	if rate, ok := revisedUpdateRate(group); ok {
+		g.settings.UpdateRate = rate
+	}
+	return nil
+}
+
+// recreate adds the group and its tags again after a reconnect.
+// If the group cannot be created, its tags are kept for the next attempt.
+func (g *opcGroup) recreate() error {
+	tags := append(g.items.tags(), g.lost...)
+	g.release()
+	if err := g.create(); err != nil {
+		g.items = NewAutomationItems(nil)
+		g.lost = tags
+		return err
+	}
+	g.lost = nil
+	return g.items.Add(tags...)
+}
+
+// release releases the OLE objects of the group.
+func (g *opcGroup) release() {
+	g.items.Close()
+	if g.group != nil {
+		g.group.Release()
+		g.group = nil
+	}
+}
+
+// itemsFunc returns the current items of the group, which change on reconnect.
+func (g *opcGroup) itemsFunc() *AutomationItems {
+	return g.items
+}
+
+func (g *opcGroup) Name() string {
+	return g.name
+}
+
+// Settings returns the settings with the update rate revised by the server.
+func (g *opcGroup) Settings() GroupSettings {
+	var settings GroupSettings
+	g.conn.mu.do(context.Background(), func() {
+		settings = g.settings
+	})
+	return settings
+}
+
+// SetSettings changes the update rate, deadband, active and subscribed state of the group.
+func (g *opcGroup) SetSettings(settings GroupSettings) error {
+	if err := settings.validate(); err != nil {
+		return err
+	}
+	var err error
+	g.conn.mu.do(context.Background(), func() {
+		if g.group == nil {
+			err = errors.New("group " + g.name + " is not available")
+			return
+		}
+		if g.items.async != nil {
+			settings.Subscribed = true
+		}
+		if err = applyGroupSettings(g.group, settings); err != nil {
+			return
+		}
+		g.settings = settings
+		if rate, ok := revisedUpdateRate(g.group); ok {
+			g.settings.UpdateRate = rate
+		}
+	})
+	return err
+}
+
+func (g *opcGroup) Add(tags ...string) error {
+	return g.AddContext(context.Background(), tags...)
+}
+
+func (g *opcGroup) AddContext(ctx context.Context, tags ...string) error {
+	return g.conn.add(ctx, g.itemsFunc, tags...)
+}
+
+func (g *opcGroup) Remove(tag string) {
+	g.conn.mu.do(context.Background(), func() {
+		g.items.Remove(tag)
+	})
+}
+
+func (g *opcGroup) Read() map[string]Item {
+	results, err := g.ReadContext(context.Background())
+	if err != nil {
+		g.conn.log().Println(err)
+	}
+	return Items(results)
+}
+
+func (g *opcGroup) ReadContext(ctx context.Context) (map[string]ReadResult, error) {
+	return g.conn.readAll(ctx, g.itemsFunc)
+}
+
+func (g *opcGroup) ReadItem(tag string) Item {
+	item, err := g.ReadItemContext(context.Background(), tag)
+	if err != nil {
+		g.conn.log().Println(err)
+	}
+	return item
+}
+
+func (g *opcGroup) ReadItemContext(ctx context.Context, tag string) (Item, error) {
+	return g.conn.readItem(ctx, g.itemsFunc, tag)
+}
+
+func (g *opcGroup) Tags() []string {
+	return append(g.items.tags(), g.lost...)
+}
+
+func (g *opcGroup) Write(tag string, value interface{}) error {
+	return g.WriteContext(context.Background(), tag, value)
+}
+
+func (g *opcGroup) WriteContext(ctx context.Context, tag string, value interface{}) error {
+	return g.conn.write(ctx, g.itemsFunc, tag, value)
+}
+
+func (g *opcGroup) WriteMany(ctx context.Context, values map[string]interface{}, opts WriteOptions) (map[string]error, error) {
+	return g.conn.writeMany(ctx, g.itemsFunc, values, opts)
+}
+
+func (g *opcGroup) AsyncRead(ctx context.Context, tags ...string) (*Transaction, error) {
+	return g.conn.asyncRead(ctx, g.itemsFunc, tags)
+}
+
+func (g *opcGroup) AsyncRefresh(ctx context.Context, src ReadSource) (*Transaction, error) {
+	return g.conn.asyncRefresh(ctx, g.itemsFunc, src)
+}
+
+func (g *opcGroup) AsyncWrite(ctx context.Context, values map[string]interface{}) (*Transaction, error) {
+	return g.conn.asyncWrite(ctx, g.itemsFunc, values)
+}
+
+// Close removes the group from the connection.
+func (g *opcGroup) Close() {
+	g.conn.RemoveGroup(g.name)
+}
+
+func (g *opcGroup) IsConnected() bool {
+	return g.conn.IsConnected()
+}
+
+// AddGroup adds a named OPC group with its own settings to the connection.
+func (conn *opcConnectionImpl) AddGroup(name string, settings GroupSettings) (Group, error) {
+	if err := settings.validate(); err != nil {
+		return nil, err
+	}
+	var g *opcGroup
+	var err error
+	conn.mu.do(context.Background(), func() {
+		if _, ok := conn.groups[name]; ok {
+			err = errors.New("group " + name + " already exists")
+			return
+		}
+		g = &opcGroup{conn: conn, name: name, settings: settings}
+		if err = g.create(); err != nil {
+			return
+		}
+		if conn.groups == nil {
+			conn.groups = make(map[string]*opcGroup)
+		}
+		conn.groups[name] = g
+	})
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Group returns the named group.
+func (conn *opcConnectionImpl) Group(name string) (Group, bool) {
+	var g *opcGroup
+	var ok bool
+	conn.mu.do(context.Background(), func() {
+		g, ok = conn.groups[name]
+	})
+	if !ok {
+		return nil, false
+	}
+	return g, true
+}
+
+// RemoveGroup removes the named group and its tags from the server.
+func (conn *opcConnectionImpl) RemoveGroup(name string) error {
+	var err error
+	conn.mu.do(context.Background(), func() {
+		g, ok := conn.groups[name]
+		if !ok {
+			err = errors.New("group " + name + " not found")
+			return
+		}
+		g.release()
+		delete(conn.groups, name)
+		err = conn.AutomationObject.removeGroup(name)
+	})
+	return err
+}
+
+// Groups returns the names of the groups in alphabetical order.
+func (conn *opcConnectionImpl) Groups() []string {
+	var names []string
+	conn.mu.do(context.Background(), func() {
+		for name := range conn.groups {
+			names = append(names, name)
+		}
+	})
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,87 @@
+package opcda
+
+import "fmt"
+
+//HRESULT is an error or status code of a COM call or of a single OPC item.
+//Its Error method describes the code, so it can be matched with errors.Is,
+//e.g. errors.Is(err, OPCErrUnknownItemID).
+type HRESULT uint32
+
+const (
+	//OPCErr defines the error codes of OPC DA:
+	OPCErrInvalidHandle HRESULT = 0xC0040001
+	OPCErrBadType       HRESULT = 0xC0040004
+	OPCErrPublic        HRESULT = 0xC0040005
+	OPCErrBadRights     HRESULT = 0xC0040006
+	OPCErrUnknownItemID HRESULT = 0xC0040007
+	OPCErrInvalidItemID HRESULT = 0xC0040008
+	OPCErrInvalidFilter HRESULT = 0xC0040009
+	OPCErrUnknownPath   HRESULT = 0xC004000A
+	OPCErrRange         HRESULT = 0xC004000B
+	OPCErrDuplicateName HRESULT = 0xC004000C
+	OPCErrInvalidConfig HRESULT = 0xC0040010
+	OPCErrNotFound      HRESULT = 0xC0040011
+	OPCErrInvalidPID    HRESULT = 0xC0040203
+
+	//OPCSuccess defines the success codes of OPC DA which carry a warning:
+	OPCSuccessUnsupportedRate HRESULT = 0x0004000D
+	OPCSuccessClamp           HRESULT = 0x0004000E
+	OPCSuccessInUse           HRESULT = 0x0004000F
+)
+
+//Failed reports if hr is an error code rather than a success code.
+func (hr HRESULT) Failed() bool {
+	return hr&0x80000000 != 0
+}
+
+//Error describes the code, e.g. "OPC_E_BADRIGHTS: ... (0xC0040006)".
+func (hr HRESULT) Error() string {
+	text, ok := hresults[hr]
+	if !ok {
+		text = "unknown error"
+	}
+	return fmt.Sprintf("%s (0x%08X)", text, uint32(hr))
+}
+
+//hresults describes the error and status codes which OPC servers return
+//for single items.
+var hresults = map[HRESULT]string{
+	0x80004001: "E_NOTIMPL: not implemented",
+	0x80004005: "E_FAIL: unspecified error",
+	0x80070005: "E_ACCESSDENIED: access denied",
+	0x8007000E: "E_OUTOFMEMORY: out of memory",
+	0x80070057: "E_INVALIDARG: invalid argument",
+	0x800706BA: "RPC_S_SERVER_UNAVAILABLE: the RPC server is unavailable",
+	0x800706BE: "RPC_S_CALL_FAILED: the remote procedure call failed",
+	0xC0040001: "OPC_E_INVALIDHANDLE: the handle is not valid",
+	0xC0040004: "OPC_E_BADTYPE: the server cannot convert the data type",
+	0xC0040005: "OPC_E_PUBLIC: the operation cannot be performed on a public group",
+	0xC0040006: "OPC_E_BADRIGHTS: the access rights of the item do not allow the operation",
+	0xC0040007: "OPC_E_UNKNOWNITEMID: the item ID is not defined in the address space of the server",
+	0xC0040008: "OPC_E_INVALIDITEMID: the item ID does not conform to the syntax of the server",
+	0xC0040009: "OPC_E_INVALIDFILTER: the filter string is not valid",
+	0xC004000A: "OPC_E_UNKNOWNPATH: the access path of the item is not known to the server",
+	0xC004000B: "OPC_E_RANGE: the value is out of range",
+	0xC004000C: "OPC_E_DUPLICATENAME: duplicate name not allowed",
+	0x0004000D: "OPC_S_UNSUPPORTEDRATE: the server does not support the requested rate",
+	0x0004000E: "OPC_S_CLAMP: the value was accepted but clamped",
+	0x0004000F: "OPC_S_INUSE: the object is still referenced",
+	0xC0040010: "OPC_E_INVALIDCONFIG: the configuration of the server is not valid",
+	0xC0040011: "OPC_E_NOTFOUND: the requested object was not found",
+	0xC0040203: "OPC_E_INVALID_PID: the property ID is not valid for the item",
+}
+
+//hresultFailed reports if code is an error code rather than a success code.
+func hresultFailed(code int32) bool {
+	return HRESULT(code).Failed()
+}
+
+//hresultText returns a readable description of code.
+func hresultText(code int32) string {
+	return HRESULT(code).Error()
+}
+
+//hresultError returns code as an error.
+func hresultError(code int32) error {
+	return HRESULT(code)
+}
@@ -0,0 +1,124 @@
+package wire
+
+import (
+	"errors"
+
+	"github.com/rxue92/opcda"
+)
+
+//ErrReadOnly is returned by add, remove and write of a read-only agent.
+var ErrReadOnly = errors.New("agent is read-only")
+
+//The codes of the errors which are matched with errors.Is after decoding.
+const (
+	CodeNotConnected = "not_connected"
+	CodeReconnecting = "reconnecting"
+	CodeTagNotFound  = "tag_not_found"
+	CodeUnsupported  = "unsupported"
+	CodeAddItem      = "add_item"
+	CodeMulti        = "multi"
+	CodeReadOnly     = "read_only"
+)
+
+//Error is the JSON form of an error.
+type Error struct {
+	Message string        `json:"message"`
+	Code    string        `json:"code,omitempty"`
+	HRESULT opcda.HRESULT `json:"hresult,omitempty"`
+	Tag     string        `json:"tag,omitempty"`
+	Errors  []*Error      `json:"errors,omitempty"`
+}
+
+//EncodeError returns the JSON form of err, or nil if err is nil.
+func EncodeError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	e := &Error{Message: err.Error()}
+	var hr opcda.HRESULT
+	if errors.As(err, &hr) {
+		e.HRESULT = hr
+	}
+	var multi opcda.MultiError
+	var addItem *opcda.AddItemError
+	switch {
+	case errors.As(err, &multi):
+		e.Code = CodeMulti
+		for _, err := range multi {
+			e.Errors = append(e.Errors, EncodeError(err))
+		}
+	case errors.As(err, &addItem):
+		e.Code, e.Tag, e.HRESULT = CodeAddItem, addItem.Tag, addItem.HRESULT
+	case errors.Is(err, opcda.ErrReconnecting):
+		e.Code = CodeReconnecting
+	case errors.Is(err, opcda.ErrNotConnected):
+		e.Code = CodeNotConnected
+	case errors.Is(err, opcda.ErrTagNotFound):
+		e.Code = CodeTagNotFound
+	case errors.Is(err, opcda.ErrUnsupportedPlatform):
+		e.Code = CodeUnsupported
+	case errors.Is(err, ErrReadOnly):
+		e.Code = CodeReadOnly
+	}
+	return e
+}
+
+//Decode returns the error of the JSON form, or nil if e is nil.
+func (e *Error) Decode() error {
+	if e == nil {
+		return nil
+	}
+	switch e.Code {
+	case CodeMulti:
+		multi := make(opcda.MultiError, len(e.Errors))
+		for i, err := range e.Errors {
+			multi[i] = err.Decode()
+		}
+		return multi
+	case CodeAddItem:
+		var cause error = &DecodedError{Message: e.Message, HRESULT: e.HRESULT}
+		if e.HRESULT != 0 {
+			cause = e.HRESULT
+		}
+		return &opcda.AddItemError{Tag: e.Tag, HRESULT: e.HRESULT, Err: cause}
+	}
+	return &DecodedError{Message: e.Message, Code: e.Code, HRESULT: e.HRESULT}
+}
+
+//DecodedError is an error decoded from its JSON form. It matches the errors
+//of opcda with errors.Is, e.g. opcda.ErrNotConnected, and unwraps to the
+//HRESULT of the server if there is one.
+type DecodedError struct {
+	Message string
+	Code    string
+	HRESULT opcda.HRESULT
+}
+
+func (e *DecodedError) Error() string {
+	return e.Message
+}
+
+//Is matches the error of opcda with the same meaning.
+func (e *DecodedError) Is(target error) bool {
+	switch e.Code {
+	case CodeReconnecting:
+		return target == opcda.ErrReconnecting || target == opcda.ErrNotConnected
+	case CodeNotConnected:
+		return target == opcda.ErrNotConnected
+	case CodeTagNotFound:
+		return target == opcda.ErrTagNotFound
+	case CodeUnsupported:
+		return target == opcda.ErrUnsupportedPlatform
+	case CodeReadOnly:
+		return target == ErrReadOnly
+	}
+	return false
+}
+
+//Unwrap returns the HRESULT of the server, if any.
+func (e *DecodedError) Unwrap() error {
+	if e.HRESULT != 0 {
+		return e.HRESULT
+	}
+	return nil
+}
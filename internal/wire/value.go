@@ -0,0 +1,176 @@
+//Package wire holds the JSON form of OPC values and errors. It is shared by
+//the HTTP protocol of remote and the files of recording, so that both keep
+//the Go type of values and the meaning of errors in the same way.
+package wire
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+//Value is the JSON form of an OPC value. Type is the Go type of the value,
+//e.g. "float32", so that the client gets the same type as the server.
+//Values of other types are sent as plain JSON without Type.
+type Value struct {
+	Type  string          `json:"type,omitempty"`
+	Value json.RawMessage `json:"value"`
+}
+
+//EncodeValue returns the JSON form of v.
+func EncodeValue(v interface{}) (Value, error) {
+	var typ string
+	var raw interface{} = v
+	switch x := v.(type) {
+	case nil:
+		return Value{Value: json.RawMessage("null")}, nil
+	case bool:
+		typ = "bool"
+	case int8:
+		typ = "int8"
+	case int16:
+		typ = "int16"
+	case int32:
+		typ = "int32"
+	case int64:
+		typ, raw = "int64", strconv.FormatInt(x, 10)
+	case int:
+		typ, raw = "int", strconv.FormatInt(int64(x), 10)
+	case uint8:
+		typ = "uint8"
+	case uint16:
+		typ = "uint16"
+	case uint32:
+		typ = "uint32"
+	case uint64:
+		typ, raw = "uint64", strconv.FormatUint(x, 10)
+	case uint:
+		typ, raw = "uint", strconv.FormatUint(uint64(x), 10)
+	case float32:
+		typ, raw = "float32", encodeFloat(float64(x))
+	case float64:
+		typ, raw = "float64", encodeFloat(x)
+	case string:
+		typ = "string"
+	case time.Time:
+		typ = "time"
+	}
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return Value{}, fmt.Errorf("cannot encode %T: %s", v, err)
+	}
+	return Value{Type: typ, Value: b}, nil
+}
+
+//encodeFloat returns f or, as JSON has no NaN and infinities, their name.
+func encodeFloat(f float64) interface{} {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return f
+}
+
+//Decode returns the value with its Go type.
+func (v Value) Decode() (interface{}, error) {
+	if len(v.Value) == 0 {
+		return nil, nil
+	}
+	var err error
+	decode := func(dst interface{}) {
+		err = json.Unmarshal(v.Value, dst)
+	}
+	var result interface{}
+	switch v.Type {
+	case "bool":
+		var x bool
+		decode(&x)
+		result = x
+	case "int8":
+		var x int8
+		decode(&x)
+		result = x
+	case "int16":
+		var x int16
+		decode(&x)
+		result = x
+	case "int32":
+		var x int32
+		decode(&x)
+		result = x
+	case "int64", "int":
+		var s string
+		decode(&s)
+		var x int64
+		if err == nil {
+			x, err = strconv.ParseInt(s, 10, 64)
+		}
+		if v.Type == "int" {
+			result = int(x)
+		} else {
+			result = x
+		}
+	case "uint8":
+		var x uint8
+		decode(&x)
+		result = x
+	case "uint16":
+		var x uint16
+		decode(&x)
+		result = x
+	case "uint32":
+		var x uint32
+		decode(&x)
+		result = x
+	case "uint64", "uint":
+		var s string
+		decode(&s)
+		var x uint64
+		if err == nil {
+			x, err = strconv.ParseUint(s, 10, 64)
+		}
+		if v.Type == "uint" {
+			result = uint(x)
+		} else {
+			result = x
+		}
+	case "float32", "float64":
+		var f float64
+		f, err = decodeFloat(v.Value)
+		if v.Type == "float32" {
+			result = float32(f)
+		} else {
+			result = f
+		}
+	case "string":
+		var x string
+		decode(&x)
+		result = x
+	case "time":
+		var x time.Time
+		decode(&x)
+		result = x
+	case "":
+		decode(&result)
+	default:
+		return nil, fmt.Errorf("unknown value type %q", v.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode %s value: %s", v.Type, err)
+	}
+	return result, nil
+}
+
+//decodeFloat decodes a number or the name of NaN or an infinity.
+func decodeFloat(raw json.RawMessage) (float64, error) {
+	var f float64
+	if err := json.Unmarshal(raw, &f); err == nil {
+		return f, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(s, 64)
+}
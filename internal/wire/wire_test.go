@@ -0,0 +1,90 @@
+package wire
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rxue92/opcda"
+)
+
+func TestValue(t *testing.T) {
+	values := []interface{}{
+		nil, true, int8(-8), int16(-16), int32(-32), int64(math.MinInt64), int(-1),
+		uint8(8), uint16(16), uint32(32), uint64(math.MaxUint64), uint(1),
+		float32(1.25), 2.5, math.Inf(1), math.Inf(-1), "text",
+		time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC),
+	}
+	for _, v := range values {
+		enc, err := EncodeValue(v)
+		if err != nil {
+			t.Fatalf("cannot encode %v: %s", v, err)
+		}
+		dec, err := enc.Decode()
+		if err != nil {
+			t.Fatalf("cannot decode %v: %s", v, err)
+		}
+		if !reflect.DeepEqual(dec, v) {
+			t.Errorf("%#v became %#v", v, dec)
+		}
+	}
+	enc, _ := EncodeValue(float32(math.NaN()))
+	if dec, _ := enc.Decode(); !math.IsNaN(float64(dec.(float32))) {
+		t.Errorf("NaN became %v", dec)
+	}
+	if _, err := (Value{Type: "complex", Value: []byte("1")}).Decode(); err == nil {
+		t.Error("unknown types should fail")
+	}
+	if _, err := EncodeValue(make(chan int)); err == nil {
+		t.Error("channels cannot be encoded")
+	}
+}
+
+func TestError(t *testing.T) {
+	for _, err := range []error{opcda.ErrNotConnected, opcda.ErrReconnecting, opcda.ErrTagNotFound, opcda.ErrUnsupportedPlatform} {
+		decoded := EncodeError(fmt.Errorf("wrapped: %w", err)).Decode()
+		if !errors.Is(decoded, err) {
+			t.Errorf("%v should survive the wire, got %#v", err, decoded)
+		}
+	}
+	if !errors.Is(EncodeError(opcda.ErrReconnecting).Decode(), opcda.ErrNotConnected) {
+		t.Error("reconnecting should still be not connected")
+	}
+	if err := EncodeError(opcda.OPCErrBadRights).Decode(); !errors.Is(err, opcda.OPCErrBadRights) {
+		t.Errorf("HRESULT should be unwrapped, got %#v", err)
+	}
+}
+
+func TestErrorEncoding(t *testing.T) {
+	if EncodeError(nil) != nil || (*Error)(nil).Decode() != nil {
+		t.Error("nil should stay nil")
+	}
+	err := EncodeError(fmt.Errorf("cannot read: %w", opcda.ErrTagNotFound)).Decode()
+	if !errors.Is(err, opcda.ErrTagNotFound) || err.Error() != "cannot read: tag not found" {
+		t.Errorf("unexpected error %#v", err)
+	}
+	multi := opcda.MultiError{
+		&opcda.AddItemError{Tag: "a", HRESULT: opcda.OPCErrUnknownItemID, Err: opcda.OPCErrUnknownItemID},
+		ErrReadOnly,
+	}
+	b, _ := json.Marshal(EncodeError(multi))
+	var e *Error
+	if err := json.Unmarshal(b, &e); err != nil {
+		t.Fatal(err)
+	}
+	var decoded opcda.MultiError
+	if !errors.As(e.Decode(), &decoded) || len(decoded) != 2 {
+		t.Fatalf("unexpected error %#v", e.Decode())
+	}
+	var addItem *opcda.AddItemError
+	if !errors.As(decoded[0], &addItem) || addItem.Tag != "a" || !errors.Is(addItem, opcda.OPCErrUnknownItemID) {
+		t.Errorf("unexpected add item error %#v", decoded[0])
+	}
+	if !errors.Is(decoded[1], ErrReadOnly) {
+		t.Errorf("unexpected error %#v", decoded[1])
+	}
+}
@@ -3,6 +3,8 @@ package opcda
 import (
 	// "log"
 	// "fmt"
+	"context"
+	"errors"
 	"math/rand"
 	"sync"
 	"time"
@@ -15,12 +17,28 @@ func init() {
 //Embedded type for mock servers
 type emptyServer struct{}
 
-func (es *emptyServer) Add(...string) error             { return nil }
-func (es *emptyServer) Remove(string)                   {}
-func (es *emptyServer) Write(string, interface{}) error { return nil }
-func (es *emptyServer) Close()                          {}
-func (es *emptyServer) Tags() []string                  { return []string{} }
-func (es *emptyServer) IsConnected() bool               { return true }
+func (es *emptyServer) Add(...string) error                               { return nil }
+func (es *emptyServer) AddContext(ctx context.Context, _ ...string) error { return ctx.Err() }
+func (es *emptyServer) Remove(string)                                     {}
+func (es *emptyServer) Write(string, interface{}) error                   { return nil }
+func (es *emptyServer) WriteContext(ctx context.Context, _ string, _ interface{}) error {
+	return ctx.Err()
+}
+func (es *emptyServer) Close()            {}
+func (es *emptyServer) Tags() []string    { return []string{} }
+func (es *emptyServer) IsConnected() bool { return true }
+
+//itemFromResults picks a single tag from the results of ReadContext.
+func itemFromResults(results map[string]ReadResult, err error, tag string) (Item, error) {
+	if err != nil {
+		return Item{}, err
+	}
+	result, ok := results[tag]
+	if !ok {
+		return Item{}, ErrTagNotFound
+	}
+	return result.Item, result.Err
+}
 
 //OpcMockServerStatic implements an OPC Server that returns the index value plus 1 for each tag.
 type OpcMockServerStatic struct {
@@ -29,16 +47,29 @@ type OpcMockServerStatic struct {
 }
 
 func (oms *OpcMockServerStatic) ReadItem(tag string) Item {
-	items := oms.Read()
-	return items[tag]
+	item, _ := oms.ReadItemContext(context.Background(), tag)
+	return item
+}
+
+func (oms *OpcMockServerStatic) ReadItemContext(ctx context.Context, tag string) (Item, error) {
+	results, err := oms.ReadContext(ctx)
+	return itemFromResults(results, err, tag)
 }
 
 func (oms *OpcMockServerStatic) Read() map[string]Item {
-	answer := make(map[string]Item)
+	results, _ := oms.ReadContext(context.Background())
+	return Items(results)
+}
+
+func (oms *OpcMockServerStatic) ReadContext(ctx context.Context) (map[string]ReadResult, error) {
+	if err := ctx.Err(); err != nil {
+		return map[string]ReadResult{}, err
+	}
+	answer := make(map[string]ReadResult)
 	for i, tag := range oms.TagList {
-		answer[tag] = Item{float64(i) + 1.0, OPCQualityGood, time.Now()}
+		answer[tag] = ReadResult{Item: Item{float64(i) + 1.0, OPCQualityGood, time.Now()}}
 	}
-	return answer
+	return answer, nil
 }
 
 //OpcMockServerRandom implements an OPC Server that returns a random value for each tag.
@@ -48,16 +79,29 @@ type OpcMockServerRandom struct {
 }
 
 func (oms *OpcMockServerRandom) ReadItem(tag string) Item {
-	items := oms.Read()
-	return items[tag]
+	item, _ := oms.ReadItemContext(context.Background(), tag)
+	return item
+}
+
+func (oms *OpcMockServerRandom) ReadItemContext(ctx context.Context, tag string) (Item, error) {
+	results, err := oms.ReadContext(ctx)
+	return itemFromResults(results, err, tag)
 }
 
 func (oms *OpcMockServerRandom) Read() map[string]Item {
-	answer := make(map[string]Item)
+	results, _ := oms.ReadContext(context.Background())
+	return Items(results)
+}
+
+func (oms *OpcMockServerRandom) ReadContext(ctx context.Context) (map[string]ReadResult, error) {
+	if err := ctx.Err(); err != nil {
+		return map[string]ReadResult{}, err
+	}
+	answer := make(map[string]ReadResult)
 	for _, tag := range oms.TagList {
-		answer[tag] = Item{rand.Float64(), OPCQualityGood, time.Now()}
+		answer[tag] = ReadResult{Item: Item{rand.Float64(), OPCQualityGood, time.Now()}}
 	}
-	return answer
+	return answer, nil
 }
 
 //OpcMockServerWakeUp implements an OPC Server that returns 1.0 for a certain duration then a random value for each tag.
@@ -79,25 +123,38 @@ func (oms *OpcMockServerWakeUp) WakeUpAfter(sleep time.Duration) {
 }
 
 func (oms *OpcMockServerWakeUp) ReadItem(tag string) Item {
-	items := oms.Read()
-	return items[tag]
+	item, _ := oms.ReadItemContext(context.Background(), tag)
+	return item
+}
+
+func (oms *OpcMockServerWakeUp) ReadItemContext(ctx context.Context, tag string) (Item, error) {
+	results, err := oms.ReadContext(ctx)
+	return itemFromResults(results, err, tag)
 }
 
 func (oms *OpcMockServerWakeUp) Read() map[string]Item {
-	answer := make(map[string]Item)
+	results, _ := oms.ReadContext(context.Background())
+	return Items(results)
+}
+
+func (oms *OpcMockServerWakeUp) ReadContext(ctx context.Context) (map[string]ReadResult, error) {
+	if err := ctx.Err(); err != nil {
+		return map[string]ReadResult{}, err
+	}
+	answer := make(map[string]ReadResult)
 
 	oms.mu.Lock()
 	defer oms.mu.Unlock()
 
 	for _, tag := range oms.TagList {
 		if oms.AtSleep {
-			answer[tag] = Item{1.0, OPCQualityGood, time.Now()}
+			answer[tag] = ReadResult{Item: Item{1.0, OPCQualityGood, time.Now()}}
 		} else {
-			answer[tag] = Item{rand.Float64(), OPCQualityGood, time.Now()}
+			answer[tag] = ReadResult{Item: Item{rand.Float64(), OPCQualityGood, time.Now()}}
 		}
 	}
 
-	return answer
+	return answer, nil
 }
 
 //FallAsleep Server, sets to 2.0 after time period (opposite of WakeUp server)
@@ -119,23 +176,244 @@ func (oms *OpcMockServerFallAsleep) FallAsleepAfter(sleep time.Duration) {
 }
 
 func (oms *OpcMockServerFallAsleep) ReadItem(tag string) Item {
-	items := oms.Read()
-	return items[tag]
+	item, _ := oms.ReadItemContext(context.Background(), tag)
+	return item
+}
+
+func (oms *OpcMockServerFallAsleep) ReadItemContext(ctx context.Context, tag string) (Item, error) {
+	results, err := oms.ReadContext(ctx)
+	return itemFromResults(results, err, tag)
 }
 
 func (oms *OpcMockServerFallAsleep) Read() map[string]Item {
-	answer := make(map[string]Item)
+	results, _ := oms.ReadContext(context.Background())
+	return Items(results)
+}
+
+func (oms *OpcMockServerFallAsleep) ReadContext(ctx context.Context) (map[string]ReadResult, error) {
+	if err := ctx.Err(); err != nil {
+		return map[string]ReadResult{}, err
+	}
+	answer := make(map[string]ReadResult)
 
 	oms.mu.Lock()
 	defer oms.mu.Unlock()
 
 	for _, tag := range oms.TagList {
 		if oms.AtSleep {
-			answer[tag] = Item{2.0, OPCQualityGood, time.Now()}
+			answer[tag] = ReadResult{Item: Item{2.0, OPCQualityGood, time.Now()}}
 		} else {
-			answer[tag] = Item{rand.Float64(), OPCQualityGood, time.Now()}
+			answer[tag] = ReadResult{Item: Item{rand.Float64(), OPCQualityGood, time.Now()}}
 		}
 	}
 
-	return answer
+	return answer, nil
+}
+
+//OpcMockServerHung implements an OPC Server whose calls block until the context is done,
+//like a DCOM call to a server that stopped answering.
+type OpcMockServerHung struct {
+	*emptyServer
+	TagList []string
+}
+
+func (oms *OpcMockServerHung) ReadItem(tag string) Item {
+	item, _ := oms.ReadItemContext(context.Background(), tag)
+	return item
+}
+
+func (oms *OpcMockServerHung) ReadItemContext(ctx context.Context, tag string) (Item, error) {
+	results, err := oms.ReadContext(ctx)
+	return itemFromResults(results, err, tag)
+}
+
+func (oms *OpcMockServerHung) Read() map[string]Item {
+	results, _ := oms.ReadContext(context.Background())
+	return Items(results)
+}
+
+func (oms *OpcMockServerHung) ReadContext(ctx context.Context) (map[string]ReadResult, error) {
+	<-ctx.Done()
+	return map[string]ReadResult{}, ctx.Err()
+}
+
+func (oms *OpcMockServerHung) WriteContext(ctx context.Context, tag string, value interface{}) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+//OpcMockServerBroken implements an OPC Server that returns the index value plus 1 for each tag
+//and fails to read the tags marked as broken.
+type OpcMockServerBroken struct {
+	*emptyServer
+	TagList []string
+	broken  map[string]bool
+	mu      sync.Mutex
+}
+
+func (oms *OpcMockServerBroken) Break(tag string) {
+	oms.mu.Lock()
+	defer oms.mu.Unlock()
+	if oms.broken == nil {
+		oms.broken = make(map[string]bool)
+	}
+	oms.broken[tag] = true
+}
+
+func (oms *OpcMockServerBroken) ReadItem(tag string) Item {
+	item, _ := oms.ReadItemContext(context.Background(), tag)
+	return item
+}
+
+func (oms *OpcMockServerBroken) ReadItemContext(ctx context.Context, tag string) (Item, error) {
+	results, err := oms.ReadContext(ctx)
+	return itemFromResults(results, err, tag)
+}
+
+func (oms *OpcMockServerBroken) Read() map[string]Item {
+	results, _ := oms.ReadContext(context.Background())
+	return Items(results)
+}
+
+func (oms *OpcMockServerBroken) ReadContext(ctx context.Context) (map[string]ReadResult, error) {
+	if err := ctx.Err(); err != nil {
+		return map[string]ReadResult{}, err
+	}
+	answer := make(map[string]ReadResult)
+
+	oms.mu.Lock()
+	defer oms.mu.Unlock()
+
+	for i, tag := range oms.TagList {
+		if oms.broken[tag] {
+			answer[tag] = ReadResult{Err: errors.New(tag + ": read failed")}
+		} else {
+			answer[tag] = ReadResult{Item: Item{float64(i) + 1.0, OPCQualityGood, time.Now()}}
+		}
+	}
+	return answer, nil
+}
+
+//OpcMockServerSource implements an OPC Server that records the read source of every read.
+type OpcMockServerSource struct {
+	*emptyServer
+	TagList []string
+	mu      sync.Mutex
+	sources []ReadSource
+}
+
+func (oms *OpcMockServerSource) Sources() []ReadSource {
+	oms.mu.Lock()
+	defer oms.mu.Unlock()
+	return append([]ReadSource(nil), oms.sources...)
+}
+
+func (oms *OpcMockServerSource) ReadItem(tag string) Item {
+	item, _ := oms.ReadItemContext(context.Background(), tag)
+	return item
+}
+
+func (oms *OpcMockServerSource) ReadItemContext(ctx context.Context, tag string) (Item, error) {
+	results, err := oms.ReadContext(ctx)
+	return itemFromResults(results, err, tag)
+}
+
+func (oms *OpcMockServerSource) Read() map[string]Item {
+	results, _ := oms.ReadContext(context.Background())
+	return Items(results)
+}
+
+func (oms *OpcMockServerSource) ReadContext(ctx context.Context) (map[string]ReadResult, error) {
+	if err := ctx.Err(); err != nil {
+		return map[string]ReadResult{}, err
+	}
+	src, ok := ReadSourceFromContext(ctx)
+	if !ok {
+		src = SourceCache
+	}
+	oms.mu.Lock()
+	oms.sources = append(oms.sources, src)
+	oms.mu.Unlock()
+	answer := make(map[string]ReadResult)
+	for _, tag := range oms.TagList {
+		answer[tag] = ReadResult{Item: Item{float64(src.Source), OPCQualityGood, time.Now()}}
+	}
+	return answer, nil
+}
+
+//OpcMockServerWritable implements an OPC Server that stores written values.
+//Writes to the tags in FailWrite and reads of the tags in FailRead fail.
+type OpcMockServerWritable struct {
+	*emptyServer
+	mu        sync.Mutex
+	Values    map[string]interface{}
+	FailWrite map[string]bool
+	FailRead  map[string]bool
+	writes    int
+}
+
+func (oms *OpcMockServerWritable) Value(tag string) interface{} {
+	oms.mu.Lock()
+	defer oms.mu.Unlock()
+	return oms.Values[tag]
+}
+
+func (oms *OpcMockServerWritable) Tags() []string {
+	oms.mu.Lock()
+	defer oms.mu.Unlock()
+	var tags []string
+	for tag := range oms.Values {
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+func (oms *OpcMockServerWritable) ReadItem(tag string) Item {
+	item, _ := oms.ReadItemContext(context.Background(), tag)
+	return item
+}
+
+func (oms *OpcMockServerWritable) ReadItemContext(ctx context.Context, tag string) (Item, error) {
+	results, err := oms.ReadContext(ctx)
+	return itemFromResults(results, err, tag)
+}
+
+func (oms *OpcMockServerWritable) Read() map[string]Item {
+	results, _ := oms.ReadContext(context.Background())
+	return Items(results)
+}
+
+func (oms *OpcMockServerWritable) ReadContext(ctx context.Context) (map[string]ReadResult, error) {
+	if err := ctx.Err(); err != nil {
+		return map[string]ReadResult{}, err
+	}
+	oms.mu.Lock()
+	defer oms.mu.Unlock()
+	answer := make(map[string]ReadResult)
+	for tag, value := range oms.Values {
+		if oms.FailRead[tag] {
+			answer[tag] = ReadResult{Err: errors.New("read failed")}
+			continue
+		}
+		answer[tag] = ReadResult{Item: Item{value, OPCQualityGood, time.Now()}}
+	}
+	return answer, nil
+}
+
+func (oms *OpcMockServerWritable) Write(tag string, value interface{}) error {
+	return oms.WriteContext(context.Background(), tag, value)
+}
+
+func (oms *OpcMockServerWritable) WriteContext(ctx context.Context, tag string, value interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	oms.mu.Lock()
+	defer oms.mu.Unlock()
+	oms.writes++
+	if oms.FailWrite[tag] {
+		return errors.New("write failed")
+	}
+	oms.Values[tag] = value
+	return nil
 }
@@ -0,0 +1,162 @@
+package opcdatest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rxue92/opcda"
+)
+
+//missingBranch is a branch which no server should have.
+const missingBranch = "opcdatest.missing.branch"
+
+//The limits of the walk through the namespace, so that the tests finish on
+//large servers.
+const (
+	maxDepth    = 4
+	maxBranches = 50
+)
+
+//TestBrowser runs the conformance tests of opcda.Browser as subtests of t.
+//newBrowser returns a new browser at the root of the namespace. The tests walk
+//through the namespace, so it should have at least one branch.
+func TestBrowser(t *testing.T, newBrowser func() (opcda.Browser, error)) {
+	tests := []struct {
+		name string
+		test func(*testing.T, opcda.Browser)
+	}{
+		{"Root", testRoot},
+		{"Walk", testWalk},
+		{"MoveTo", testMoveTo},
+		{"Missing", testMissingBranch},
+		{"Close", testCloseBrowser},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			b, err := newBrowser()
+			if err != nil {
+				t.Fatal("cannot create browser:", err)
+			}
+			defer b.Close()
+			test.test(t, b)
+		})
+	}
+}
+
+func testRoot(t *testing.T, b opcda.Browser) {
+	if pos := b.Position(); pos != "" {
+		t.Errorf("new browser should be at the root with an empty position, got %q", pos)
+	}
+	if len(b.ShowBranches()) == 0 {
+		t.Fatal("namespace should have branches")
+	}
+	b.MoveUp()
+	if pos := b.Position(); pos != "" {
+		t.Errorf("MoveUp at the root should stay there, got %q", pos)
+	}
+	b.MoveDown(b.ShowBranches()[0])
+	b.MoveToRoot()
+	if pos := b.Position(); pos != "" {
+		t.Errorf("MoveToRoot should move to the root, got %q", pos)
+	}
+}
+
+//walker walks through the namespace with MoveDown and MoveUp.
+type walker struct {
+	t        *testing.T
+	b        opcda.Browser
+	branches int
+}
+
+//walk checks the current branch at path and its sub-branches.
+func (w *walker) walk(path []string) {
+	pos := w.b.Position()
+	for _, leaf := range w.b.ShowLeafs() {
+		if leaf.Name == "" || leaf.ItemId == "" {
+			w.t.Errorf("leaf at %q should have a name and an item ID, got %+v", pos, leaf)
+		}
+	}
+	if len(path) >= maxDepth {
+		return
+	}
+	for _, branch := range w.b.ShowBranches() {
+		if w.branches >= maxBranches {
+			return
+		}
+		w.branches++
+		w.b.MoveDown(branch)
+		sub := w.b.Position()
+		if sub == pos || !strings.HasSuffix(sub, branch) {
+			w.t.Errorf("MoveDown(%q) at %q should end the position with the branch, got %q", branch, pos, sub)
+			w.b.MoveTo(path...)
+			continue
+		}
+		w.walk(append(path[:len(path):len(path)], branch))
+		w.b.MoveUp()
+		if up := w.b.Position(); up != pos {
+			w.t.Errorf("MoveUp from %q should return to %q, got %q", sub, pos, up)
+			w.b.MoveTo(path...)
+		}
+	}
+}
+
+func testWalk(t *testing.T, b opcda.Browser) {
+	w := &walker{t: t, b: b}
+	w.walk(nil)
+	if w.branches == 0 {
+		t.Error("namespace should have branches")
+	}
+}
+
+func testMoveTo(t *testing.T, b opcda.Browser) {
+	var path []string
+	for depth := 0; depth < maxDepth; depth++ {
+		branches := b.ShowBranches()
+		if len(branches) == 0 {
+			break
+		}
+		path = append(path, branches[0])
+		b.MoveDown(branches[0])
+	}
+	if len(path) == 0 {
+		t.Fatal("namespace should have branches")
+	}
+	deep := b.Position()
+	leafs := b.ShowLeafs()
+
+	b.MoveToRoot()
+	b.MoveTo(path...)
+	if pos := b.Position(); pos != deep {
+		t.Errorf("MoveTo(%v) should reach %q like MoveDown, got %q", path, deep, pos)
+	}
+	if got := b.ShowLeafs(); len(got) != len(leafs) {
+		t.Errorf("MoveTo(%v) should show the leafs %v, got %v", path, leafs, got)
+	}
+	b.MoveTo(path[0])
+	if pos := b.Position(); !strings.HasSuffix(pos, path[0]) || (len(path) > 1 && pos == deep) {
+		t.Errorf("MoveTo should take an absolute path, got %q for %q", pos, path[0])
+	}
+}
+
+func testMissingBranch(t *testing.T, b opcda.Browser) {
+	branch := b.ShowBranches()
+	if len(branch) == 0 {
+		t.Fatal("namespace should have branches")
+	}
+	b.MoveDown(branch[0])
+	pos := b.Position()
+	b.MoveDown(missingBranch)
+	if got := b.Position(); got != pos {
+		t.Errorf("MoveDown to a missing branch should stay at %q, got %q", pos, got)
+	}
+	b.MoveTo(branch[0], missingBranch)
+	if got := b.Position(); got != pos {
+		t.Errorf("MoveTo a missing branch should stay at %q, got %q", pos, got)
+	}
+}
+
+func testCloseBrowser(t *testing.T, b opcda.Browser) {
+	b.Close()
+	b.Close()
+}
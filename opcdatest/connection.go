@@ -0,0 +1,374 @@
+//Package opcdatest tests that implementations of opcda.Connection and
+//opcda.Browser, e.g. mocks, proxies or wrappers, behave like the connection
+//and the browser of the OPC Automation interface.
+//
+//	func TestMyConnection(t *testing.T) {
+//		opcdatest.TestConnection(t, opcdatest.ConnectionConfig{
+//			New:     func() (opcda.Connection, error) { return myproxy.Dial(addr) },
+//			Tags:    []string{"numeric.sin.float", "numeric.saw.int64"},
+//			Missing: "no.such.tag",
+//			Values:  map[string]interface{}{"storage.numeric.reg01": 42.0},
+//		})
+//	}
+//
+//Run the tests with -race to check concurrent access.
+package opcdatest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/rxue92/opcda"
+)
+
+//ConnectionConfig describes the connection under test and the tags of its
+//server.
+type ConnectionConfig struct {
+	//New returns a new connection without tags. Every test gets its own
+	//connection and closes it.
+	New func() (opcda.Connection, error)
+	//Tags exist on the server and can be read. At least one is required.
+	Tags []string
+	//Missing is a tag which does not exist on the server. If it is empty,
+	//the tests of missing tags are skipped.
+	Missing string
+	//Values are written to their tags and read back. If it is empty, the
+	//tests of writes are skipped.
+	Values map[string]interface{}
+	//FixedTags is set for connections which read a fixed set of tags and
+	//ignore Add and Remove, like simple mocks. The tests of Add, Remove and
+	//Tags are skipped.
+	FixedTags bool
+}
+
+//TestConnection runs the conformance tests of opcda.Connection as subtests
+//of t.
+func TestConnection(t *testing.T, cfg ConnectionConfig) {
+	if cfg.New == nil || len(cfg.Tags) == 0 {
+		t.Fatal("opcdatest: ConnectionConfig needs New and Tags")
+	}
+	tests := []struct {
+		name  string
+		test  func(*testing.T, opcda.Connection, ConnectionConfig)
+		skip  bool
+		cause string
+	}{
+		{"Connected", testConnected, false, ""},
+		{"Add", testAdd, cfg.FixedTags, "fixed tags"},
+		{"AddMissing", testAddMissing, cfg.FixedTags || cfg.Missing == "", "fixed tags or no missing tag"},
+		{"Read", testRead, false, ""},
+		{"ReadMissing", testReadMissing, cfg.Missing == "", "no missing tag"},
+		{"Remove", testRemove, cfg.FixedTags, "fixed tags"},
+		{"WriteRead", testWriteRead, len(cfg.Values) == 0, "no values"},
+		{"WriteMissing", testWriteMissing, len(cfg.Values) == 0 || cfg.Missing == "", "no values or no missing tag"},
+		{"WriteNotAdded", testWriteNotAdded, cfg.FixedTags || len(cfg.Values) == 0, "fixed tags or no values"},
+		{"Canceled", testCanceled, false, ""},
+		{"Concurrent", testConcurrent, false, ""},
+		{"Close", testClose, false, ""},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			if test.skip {
+				t.Skip("opcdatest:", test.cause)
+			}
+			conn, err := cfg.New()
+			if err != nil {
+				t.Fatal("cannot create connection:", err)
+			}
+			defer conn.Close()
+			test.test(t, conn, cfg)
+		})
+	}
+}
+
+//add adds the tags unless the connection has fixed tags.
+func add(t *testing.T, conn opcda.Connection, cfg ConnectionConfig, tags ...string) {
+	t.Helper()
+	if cfg.FixedTags {
+		return
+	}
+	if err := conn.Add(tags...); err != nil {
+		t.Fatalf("cannot add %v: %s", tags, err)
+	}
+}
+
+//valueTags returns the tags of the values.
+func valueTags(cfg ConnectionConfig) []string {
+	tags := make([]string, 0, len(cfg.Values))
+	for tag := range cfg.Values {
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+//sameValue reports if a value read back is the value written. Servers may
+//convert the type, so values which print the same are equal too.
+func sameValue(written, read interface{}) bool {
+	return reflect.DeepEqual(written, read) || fmt.Sprint(written) == fmt.Sprint(read)
+}
+
+//hasTags reports if tags contains all of want.
+func hasTags(tags []string, want ...string) bool {
+	set := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		set[tag] = true
+	}
+	for _, tag := range want {
+		if !set[tag] {
+			return false
+		}
+	}
+	return true
+}
+
+func testConnected(t *testing.T, conn opcda.Connection, cfg ConnectionConfig) {
+	if !conn.IsConnected() {
+		t.Error("new connection should be connected")
+	}
+}
+
+func testAdd(t *testing.T, conn opcda.Connection, cfg ConnectionConfig) {
+	if tags := conn.Tags(); len(tags) != 0 {
+		t.Errorf("new connection should have no tags, got %v", tags)
+	}
+	if err := conn.Add(cfg.Tags...); err != nil {
+		t.Fatalf("cannot add %v: %s", cfg.Tags, err)
+	}
+	tags := conn.Tags()
+	if len(tags) != len(cfg.Tags) || !hasTags(tags, cfg.Tags...) {
+		t.Errorf("Tags should be %v, got %v", cfg.Tags, tags)
+	}
+	if err := conn.AddContext(context.Background()); err != nil {
+		t.Errorf("adding no tags should not fail: %s", err)
+	}
+}
+
+func testAddMissing(t *testing.T, conn opcda.Connection, cfg ConnectionConfig) {
+	err := conn.Add(cfg.Tags[0], cfg.Missing)
+	var addErr *opcda.AddItemError
+	if !errors.As(err, &addErr) || addErr.Tag != cfg.Missing {
+		t.Fatalf("adding %s should fail with *opcda.AddItemError, got %v", cfg.Missing, err)
+	}
+	tags := conn.Tags()
+	if !hasTags(tags, cfg.Tags[0]) {
+		t.Errorf("%s should be added despite the missing tag, got %v", cfg.Tags[0], tags)
+	}
+	if hasTags(tags, cfg.Missing) {
+		t.Errorf("%s should not be added, got %v", cfg.Missing, tags)
+	}
+}
+
+func testRead(t *testing.T, conn opcda.Connection, cfg ConnectionConfig) {
+	add(t, conn, cfg, cfg.Tags...)
+	results, err := conn.ReadContext(context.Background())
+	if err != nil {
+		t.Fatal("cannot read:", err)
+	}
+	for _, tag := range cfg.Tags {
+		r, ok := results[tag]
+		if !ok {
+			t.Errorf("ReadContext should return %s", tag)
+			continue
+		}
+		if r.Err != nil {
+			t.Errorf("cannot read %s: %s", tag, r.Err)
+		}
+	}
+	items := conn.Read()
+	if !hasTags(keys(items), cfg.Tags...) {
+		t.Errorf("Read should return %v, got %v", cfg.Tags, items)
+	}
+	for _, tag := range cfg.Tags {
+		if _, err := conn.ReadItemContext(context.Background(), tag); err != nil {
+			t.Errorf("cannot read %s: %s", tag, err)
+		}
+		if item := conn.ReadItem(tag); item.Timestamp.IsZero() {
+			t.Errorf("item of %s should have a timestamp, got %v", tag, item)
+		}
+	}
+}
+
+//keys returns the tags of items.
+func keys(items map[string]opcda.Item) []string {
+	tags := make([]string, 0, len(items))
+	for tag := range items {
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+func testReadMissing(t *testing.T, conn opcda.Connection, cfg ConnectionConfig) {
+	add(t, conn, cfg, cfg.Tags...)
+	if _, err := conn.ReadItemContext(context.Background(), cfg.Missing); !errors.Is(err, opcda.ErrTagNotFound) {
+		t.Errorf("reading %s should fail with ErrTagNotFound, got %v", cfg.Missing, err)
+	}
+	if item := conn.ReadItem(cfg.Missing); item.Value != nil || !item.Timestamp.IsZero() {
+		t.Errorf("ReadItem of %s should return an empty item, got %v", cfg.Missing, item)
+	}
+	if results, _ := conn.ReadContext(context.Background()); hasTags(keysOf(results), cfg.Missing) {
+		t.Errorf("ReadContext should not return %s", cfg.Missing)
+	}
+}
+
+//keysOf returns the tags of results.
+func keysOf(results map[string]opcda.ReadResult) []string {
+	tags := make([]string, 0, len(results))
+	for tag := range results {
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+func testRemove(t *testing.T, conn opcda.Connection, cfg ConnectionConfig) {
+	add(t, conn, cfg, cfg.Tags...)
+	removed := cfg.Tags[0]
+	conn.Remove(removed)
+	if tags := conn.Tags(); hasTags(tags, removed) || len(tags) != len(cfg.Tags)-1 {
+		t.Errorf("only %s should be removed, got %v", removed, tags)
+	}
+	results, err := conn.ReadContext(context.Background())
+	if err != nil {
+		t.Fatal("cannot read:", err)
+	}
+	if _, ok := results[removed]; ok {
+		t.Errorf("ReadContext should not return removed %s", removed)
+	}
+	if _, err := conn.ReadItemContext(context.Background(), removed); !errors.Is(err, opcda.ErrTagNotFound) {
+		t.Errorf("reading removed %s should fail with ErrTagNotFound, got %v", removed, err)
+	}
+	conn.Remove(removed)
+	conn.Remove("opcdatest.never.added")
+
+	if err := conn.Add(removed); err != nil {
+		t.Fatalf("cannot add %s again: %s", removed, err)
+	}
+	if _, err := conn.ReadItemContext(context.Background(), removed); err != nil {
+		t.Errorf("cannot read %s added again: %s", removed, err)
+	}
+}
+
+func testWriteRead(t *testing.T, conn opcda.Connection, cfg ConnectionConfig) {
+	add(t, conn, cfg, valueTags(cfg)...)
+	for tag, value := range cfg.Values {
+		if err := conn.Write(tag, value); err != nil {
+			t.Errorf("cannot write %v to %s: %s", value, tag, err)
+			continue
+		}
+		item, err := conn.ReadItemContext(context.Background(), tag)
+		if err != nil {
+			t.Errorf("cannot read %s: %s", tag, err)
+			continue
+		}
+		if !sameValue(value, item.Value) {
+			t.Errorf("%s should read %v after the write, got %v", tag, value, item.Value)
+		}
+		results, err := conn.ReadContext(context.Background())
+		if err != nil || !sameValue(value, results[tag].Value) {
+			t.Errorf("ReadContext should return %v for %s, got %v (%v)", value, tag, results[tag], err)
+		}
+	}
+}
+
+func testWriteMissing(t *testing.T, conn opcda.Connection, cfg ConnectionConfig) {
+	add(t, conn, cfg, valueTags(cfg)...)
+	var value interface{}
+	for _, v := range cfg.Values {
+		value = v
+		break
+	}
+	if err := conn.WriteContext(context.Background(), cfg.Missing, value); err == nil {
+		t.Errorf("writing %s should fail", cfg.Missing)
+	}
+}
+
+//testWriteNotAdded writes to tags which are not added; like the connection of
+//the OPC Automation interface, the write adds them as write-only tags, which
+//are read alone but not by Read and ReadContext.
+func testWriteNotAdded(t *testing.T, conn opcda.Connection, cfg ConnectionConfig) {
+	for tag, value := range cfg.Values {
+		if err := conn.Write(tag, value); err != nil {
+			t.Errorf("writing %s which is not added should add it, got %s", tag, err)
+			continue
+		}
+		if !hasTags(conn.Tags(), tag) {
+			t.Errorf("%s should be added by the write, got %v", tag, conn.Tags())
+		}
+		item, err := conn.ReadItemContext(context.Background(), tag)
+		if err != nil || !sameValue(value, item.Value) {
+			t.Errorf("%s should read %v after the write, got %v (%v)", tag, value, item.Value, err)
+		}
+		results, err := conn.ReadContext(context.Background())
+		if _, ok := results[tag]; ok || err != nil {
+			t.Errorf("ReadContext should not read write-only %s, got %v (%v)", tag, results, err)
+		}
+	}
+}
+
+func testCanceled(t *testing.T, conn opcda.Connection, cfg ConnectionConfig) {
+	add(t, conn, cfg, cfg.Tags...)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := conn.ReadContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("ReadContext should fail with context.Canceled, got %v", err)
+	}
+	if _, err := conn.ReadItemContext(ctx, cfg.Tags[0]); !errors.Is(err, context.Canceled) {
+		t.Errorf("ReadItemContext should fail with context.Canceled, got %v", err)
+	}
+	if err := conn.AddContext(ctx, cfg.Tags[0]); !errors.Is(err, context.Canceled) {
+		t.Errorf("AddContext should fail with context.Canceled, got %v", err)
+	}
+	for tag, value := range cfg.Values {
+		if err := conn.WriteContext(ctx, tag, value); !errors.Is(err, context.Canceled) {
+			t.Errorf("WriteContext should fail with context.Canceled, got %v", err)
+		}
+		break
+	}
+}
+
+//testConcurrent calls all methods at once; it finds races with -race.
+func testConcurrent(t *testing.T, conn opcda.Connection, cfg ConnectionConfig) {
+	add(t, conn, cfg, cfg.Tags...)
+	add(t, conn, cfg, valueTags(cfg)...)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				tag := cfg.Tags[(g+i)%len(cfg.Tags)]
+				conn.ReadContext(context.Background())
+				conn.Read()
+				conn.ReadItem(tag)
+				conn.Tags()
+				conn.IsConnected()
+				for tag, value := range cfg.Values {
+					conn.Write(tag, value)
+				}
+				if !cfg.FixedTags && g%2 == 1 {
+					conn.Remove(tag)
+					conn.Add(tag)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if !cfg.FixedTags && !hasTags(conn.Tags(), cfg.Tags...) {
+		t.Errorf("all tags should be added after the concurrent calls, got %v", conn.Tags())
+	}
+}
+
+func testClose(t *testing.T, conn opcda.Connection, cfg ConnectionConfig) {
+	add(t, conn, cfg, cfg.Tags...)
+	conn.Close()
+	conn.Close()
+	//the calls after Close may fail but must not panic
+	conn.Read()
+	conn.ReadItem(cfg.Tags[0])
+	conn.Tags()
+	conn.IsConnected()
+}
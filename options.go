@@ -0,0 +1,167 @@
+package opcda
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+//DefaultWrappers are the ProgIDs of the OPC Automation Wrappers which are
+//tried in order if no wrappers are given with WithWrappers.
+var DefaultWrappers = []string{"OPC.Automation.1", "Graybox.OPC.DAWrapper.1"}
+
+//Option configures NewConnection, NewBrowser and CreateBrowser.
+type Option func(*options)
+
+//options holds the resolved options. The group settings are only applied
+//if they were given.
+type options struct {
+	wrappers       []string
+	groupName      string
+	updateRate     time.Duration
+	hasUpdateRate  bool
+	deadBand       float32
+	hasDeadBand    bool
+	localeID       uint32
+	hasLocaleID    bool
+	source         ReadSource
+	logger         *log.Logger
+	reconnect      ReconnectPolicy
+	connectTimeout time.Duration
+	properties     bool
+	err            error
+}
+
+//fail records the first invalid option.
+func (o *options) fail(err error) {
+	if o.err == nil {
+		o.err = err
+	}
+}
+
+//newOptions applies opts to the defaults and returns the first invalid option as error.
+func newOptions(opts []Option) (options, error) {
+	o := options{
+		wrappers:  DefaultWrappers,
+		source:    SourceCache,
+		reconnect: DefaultReconnectPolicy,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+	return o, o.err
+}
+
+//log returns the logger of the options or the package logger.
+func (o *options) log() *log.Logger {
+	if o.logger != nil {
+		return o.logger
+	}
+	return logger
+}
+
+//WithWrappers sets the ProgIDs of the OPC Automation Wrappers to try in order,
+//instead of DefaultWrappers.
+func WithWrappers(progIDs ...string) Option {
+	return func(o *options) {
+		if len(progIDs) == 0 {
+			o.fail(errors.New("at least one wrapper is required"))
+			return
+		}
+		o.wrappers = progIDs
+	}
+}
+
+//WithGroupName sets the name of the OPC group of the connection.
+//By default the server chooses a name.
+func WithGroupName(name string) Option {
+	return func(o *options) {
+		o.groupName = name
+	}
+}
+
+//WithUpdateRate sets the update rate of the OPC group of the connection.
+func WithUpdateRate(rate time.Duration) Option {
+	return func(o *options) {
+		if rate < 0 {
+			o.fail(errors.New("update rate must not be negative"))
+			return
+		}
+		o.updateRate = rate
+		o.hasUpdateRate = true
+	}
+}
+
+//WithDeadBand sets the percent deadband of the OPC group of the connection.
+func WithDeadBand(percent float32) Option {
+	return func(o *options) {
+		if percent < 0 || percent > 100 {
+			o.fail(errors.New("deadband must be between 0 and 100 percent"))
+			return
+		}
+		o.deadBand = percent
+		o.hasDeadBand = true
+	}
+}
+
+//WithLocaleID sets the locale of the server, e.g. 0x0409 for English (United States).
+func WithLocaleID(lcid uint32) Option {
+	return func(o *options) {
+		o.localeID = lcid
+		o.hasLocaleID = true
+	}
+}
+
+//WithDefaultReadSource sets the read source of the connection which is used
+//if the context of a read carries none, see WithReadSource.
+func WithDefaultReadSource(src ReadSource) Option {
+	return func(o *options) {
+		if err := src.validate(); err != nil {
+			o.fail(err)
+			return
+		}
+		o.source = src
+	}
+}
+
+//WithLogger sets the logger of the connection instead of the package logger.
+func WithLogger(l *log.Logger) Option {
+	return func(o *options) {
+		o.logger = l
+	}
+}
+
+//WithReconnectPolicy sets how the connection is re-established when it is lost.
+func WithReconnectPolicy(policy ReconnectPolicy) Option {
+	return func(o *options) {
+		if err := policy.validate(); err != nil {
+			o.fail(err)
+			return
+		}
+		o.reconnect = policy
+	}
+}
+
+//WithConnectTimeout limits the time to establish the initial connection.
+//An attempt which takes longer is abandoned: it keeps running in the
+//background and its connection is closed when it returns. By default the
+//time is not limited.
+func WithConnectTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		if timeout < 0 {
+			o.fail(errors.New("connect timeout must not be negative"))
+			return
+		}
+		o.connectTimeout = timeout
+	}
+}
+
+//WithItemProperties makes CreateBrowser read the standard properties of every
+//leaf, which takes two calls to the server per leaf.
+func WithItemProperties() Option {
+	return func(o *options) {
+		o.properties = true
+	}
+}
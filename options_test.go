@@ -0,0 +1,119 @@
+package opcda
+
+import (
+	"bytes"
+	"log"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOptionsDefaults(t *testing.T) {
+	o, err := newOptions(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(o.wrappers, DefaultWrappers) {
+		t.Errorf("wrappers should default to %v, got %v", DefaultWrappers, o.wrappers)
+	}
+	if o.source != SourceCache {
+		t.Errorf("read source should default to the cache, got %v", o.source)
+	}
+	if o.reconnect.Interval != DefaultReconnectPolicy.Interval || o.reconnect.Multiplier != DefaultReconnectPolicy.Multiplier {
+		t.Errorf("reconnect policy should default to %v, got %v", DefaultReconnectPolicy, o.reconnect)
+	}
+	if o.hasUpdateRate || o.hasDeadBand || o.hasLocaleID || o.groupName != "" || o.connectTimeout != 0 || o.properties {
+		t.Errorf("group and server settings should be left to the server: %+v", o)
+	}
+	if o.log() != logger {
+		t.Error("the package logger should be used by default")
+	}
+}
+
+func TestOptionsApplied(t *testing.T) {
+	var buf bytes.Buffer
+	l := log.New(&buf, "", 0)
+	policy := ReconnectPolicy{Interval: time.Second}
+	o, err := newOptions([]Option{
+		WithWrappers("Matrikon.OPC.Automation.1"),
+		WithGroupName("plant"),
+		WithUpdateRate(500 * time.Millisecond),
+		WithDeadBand(2.5),
+		WithLocaleID(0x0409),
+		WithDefaultReadSource(SourceDevice),
+		WithLogger(l),
+		WithReconnectPolicy(policy),
+		WithConnectTimeout(3 * time.Second),
+		WithItemProperties(),
+		nil,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(o.wrappers, []string{"Matrikon.OPC.Automation.1"}) {
+		t.Errorf("unexpected wrappers %v", o.wrappers)
+	}
+	if o.groupName != "plant" {
+		t.Errorf("unexpected group name %q", o.groupName)
+	}
+	if !o.hasUpdateRate || o.updateRate != 500*time.Millisecond {
+		t.Errorf("unexpected update rate %v", o.updateRate)
+	}
+	if !o.hasDeadBand || o.deadBand != 2.5 {
+		t.Errorf("unexpected deadband %v", o.deadBand)
+	}
+	if !o.hasLocaleID || o.localeID != 0x0409 {
+		t.Errorf("unexpected locale %#x", o.localeID)
+	}
+	if o.source != SourceDevice {
+		t.Errorf("unexpected read source %v", o.source)
+	}
+	if o.reconnect.Interval != policy.Interval {
+		t.Errorf("unexpected reconnect policy %v", o.reconnect)
+	}
+	if o.connectTimeout != 3*time.Second {
+		t.Errorf("unexpected connect timeout %v", o.connectTimeout)
+	}
+	if !o.properties {
+		t.Error("item properties should be read")
+	}
+	o.log().Print("hello")
+	if buf.String() != "hello\n" {
+		t.Errorf("the given logger should be used, got %q", buf.String())
+	}
+}
+
+func TestOptionsLastWins(t *testing.T) {
+	o, err := newOptions([]Option{WithGroupName("a"), WithGroupName("b"), WithUpdateRate(0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.groupName != "b" {
+		t.Errorf("the last option should win, got %q", o.groupName)
+	}
+	if !o.hasUpdateRate || o.updateRate != 0 {
+		t.Error("a zero update rate should be passed to the server")
+	}
+}
+
+func TestOptionsInvalid(t *testing.T) {
+	invalid := map[string]Option{
+		"no wrappers":       WithWrappers(),
+		"negative rate":     WithUpdateRate(-time.Second),
+		"deadband":          WithDeadBand(101),
+		"read source":       WithDefaultReadSource(ReadSource{Source: 3}),
+		"reconnect":         WithReconnectPolicy(ReconnectPolicy{}),
+		"negative timeout":  WithConnectTimeout(-time.Second),
+		"negative deadband": WithDeadBand(-1),
+	}
+	for name, opt := range invalid {
+		if _, err := newOptions([]Option{WithGroupName("plant"), opt}); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	_, err := newOptions([]Option{WithDeadBand(-1), WithConnectTimeout(-time.Second)})
+	if err == nil || err.Error() != "deadband must be between 0 and 100 percent" {
+		t.Errorf("the first invalid option should be reported, got %v", err)
+	}
+}
@@ -0,0 +1,207 @@
+package opcda
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	//OPCProperty defines the IDs of the item properties of OPC DA 2.0:
+	//Canonical data type of the item, a VARTYPE
+	OPCPropertyDataType int32 = 1
+	//Current value, quality and timestamp of the item
+	OPCPropertyValue     int32 = 2
+	OPCPropertyQuality   int32 = 3
+	OPCPropertyTimestamp int32 = 4
+	//Access rights, see OPCAccessRights
+	OPCPropertyAccessRights int32 = 5
+	//Fastest rate in milliseconds at which the server scans the item
+	OPCPropertyScanRate int32 = 6
+	//Engineering units
+	OPCPropertyEUType  int32 = 7
+	OPCPropertyEUInfo  int32 = 8
+	OPCPropertyEUUnits int32 = 100
+	//Description of the item
+	OPCPropertyDescription int32 = 101
+	//Upper and lower limit of the engineering unit range
+	OPCPropertyHighEU int32 = 102
+	OPCPropertyLowEU  int32 = 103
+
+	//OPCAccessRights defines the bits of the access rights property:
+	//Readable
+	OPCReadable int32 = 1
+	//Writable
+	OPCWritable int32 = 2
+)
+
+//PropertyInfo describes a property which the server provides for an item.
+type PropertyInfo struct {
+	ID          int32  `json:"id"`
+	Description string `json:"description"`
+	DataType    uint16 `json:"dataType"` // VARTYPE of the value
+}
+
+//Property is the value of an item property or the error why it could not be read.
+type Property struct {
+	ID    int32
+	Value interface{}
+	Err   error
+}
+
+//ItemProperties holds the standard properties of an item. Properties which
+//the server does not provide are left at their zero value.
+type ItemProperties struct {
+	DataType     uint16        `json:"dataType"` // VARTYPE, e.g. 4 for VT_R4
+	AccessRights int32         `json:"accessRights"`
+	ScanRate     time.Duration `json:"scanRate"`
+	EUUnits      string        `json:"euUnits,omitempty"`
+	EUHigh       float64       `json:"euHigh,omitempty"`
+	EULow        float64       `json:"euLow,omitempty"`
+	Description  string        `json:"description,omitempty"`
+}
+
+//Readable checks the access rights for reading.
+func (p *ItemProperties) Readable() bool {
+	return p.AccessRights&OPCReadable != 0
+}
+
+//Writable checks the access rights for writing.
+func (p *ItemProperties) Writable() bool {
+	return p.AccessRights&OPCWritable != 0
+}
+
+//PropertyReader is implemented by connections and browsers which can query
+//the item properties from the server.
+type PropertyReader interface {
+	QueryAvailableProperties(itemID string) ([]PropertyInfo, error)
+	GetItemProperties(itemID string, ids ...int32) ([]Property, error)
+}
+
+//standardProperties are the properties read by ReadItemProperties.
+var standardProperties = []int32{
+	OPCPropertyDataType,
+	OPCPropertyAccessRights,
+	OPCPropertyScanRate,
+	OPCPropertyEUUnits,
+	OPCPropertyDescription,
+	OPCPropertyHighEU,
+	OPCPropertyLowEU,
+}
+
+//ReadItemProperties reads the standard properties of the item which the
+//server provides.
+func ReadItemProperties(r PropertyReader, itemID string) (*ItemProperties, error) {
+	available, err := r.QueryAvailableProperties(itemID)
+	if err != nil {
+		return nil, err
+	}
+	provided := make(map[int32]bool)
+	for _, info := range available {
+		provided[info.ID] = true
+	}
+	var ids []int32
+	for _, id := range standardProperties {
+		if provided[id] {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return &ItemProperties{}, nil
+	}
+	properties, err := r.GetItemProperties(itemID, ids...)
+	if err != nil {
+		return nil, err
+	}
+	return itemPropertiesFrom(properties), nil
+}
+
+//itemPropertiesFrom converts the property values to ItemProperties.
+//Properties with an error or an unexpected type are skipped.
+func itemPropertiesFrom(properties []Property) *ItemProperties {
+	var p ItemProperties
+	for _, property := range properties {
+		if property.Err != nil {
+			continue
+		}
+		switch property.ID {
+		case OPCPropertyDataType:
+			if v, ok := toInt64(property.Value); ok {
+				p.DataType = uint16(v)
+			}
+		case OPCPropertyAccessRights:
+			if v, ok := toInt64(property.Value); ok {
+				p.AccessRights = int32(v)
+			}
+		case OPCPropertyScanRate:
+			if v, ok := toFloat64(property.Value); ok {
+				p.ScanRate = time.Duration(v * float64(time.Millisecond))
+			}
+		case OPCPropertyEUUnits:
+			p.EUUnits = fmt.Sprint(property.Value)
+		case OPCPropertyDescription:
+			p.Description = fmt.Sprint(property.Value)
+		case OPCPropertyHighEU:
+			if v, ok := toFloat64(property.Value); ok {
+				p.EUHigh = v
+			}
+		case OPCPropertyLowEU:
+			if v, ok := toFloat64(property.Value); ok {
+				p.EULow = v
+			}
+		}
+	}
+	return &p
+}
+
+//toInt64 converts the integer types of VARIANTs.
+func toInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case int:
+		return int64(v), true
+	}
+	return 0, false
+}
+
+//toFloat64 converts the numeric types of VARIANTs.
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	if v, ok := toInt64(value); ok {
+		return float64(v), true
+	}
+	return 0, false
+}
+
+//attachProperties reads the properties of all leaves of tree. Leaves whose
+//properties cannot be read are left without.
+func attachProperties(r PropertyReader, tree *Tree) {
+	for i := range tree.Leaves {
+		properties, err := ReadItemProperties(r, tree.Leaves[i].ItemId)
+		if err != nil {
+			logger.Printf("Cannot read properties of %s: %s", tree.Leaves[i].ItemId, err)
+			continue
+		}
+		tree.Leaves[i].Properties = properties
+	}
+	for _, branch := range tree.Branches {
+		attachProperties(r, branch)
+	}
+}
@@ -0,0 +1,115 @@
+package opcda
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+//fakePropertyReader serves the properties of items from a map.
+type fakePropertyReader struct {
+	properties map[string]map[int32]interface{}
+	requested  []int32
+}
+
+func (f *fakePropertyReader) QueryAvailableProperties(itemID string) ([]PropertyInfo, error) {
+	properties, ok := f.properties[itemID]
+	if !ok {
+		return nil, errors.New("unknown item " + itemID)
+	}
+	var infos []PropertyInfo
+	for id := range properties {
+		infos = append(infos, PropertyInfo{ID: id})
+	}
+	return infos, nil
+}
+
+func (f *fakePropertyReader) GetItemProperties(itemID string, ids ...int32) ([]Property, error) {
+	f.requested = append(f.requested, ids...)
+	var properties []Property
+	for _, id := range ids {
+		value, ok := f.properties[itemID][id]
+		if !ok {
+			properties = append(properties, Property{ID: id, Err: errors.New("invalid property")})
+			continue
+		}
+		properties = append(properties, Property{ID: id, Value: value})
+	}
+	return properties, nil
+}
+
+func newFakePropertyReader() *fakePropertyReader {
+	return &fakePropertyReader{properties: map[string]map[int32]interface{}{
+		"numeric.sin.float": {
+			OPCPropertyDataType:     int16(4),
+			OPCPropertyValue:        float32(0.5),
+			OPCPropertyAccessRights: int32(3),
+			OPCPropertyScanRate:     float32(100),
+			OPCPropertyEUUnits:      "degC",
+			OPCPropertyDescription:  "sine wave",
+			OPCPropertyHighEU:       float64(1),
+			OPCPropertyLowEU:        float64(-1),
+		},
+		"storage.bool.reg01": {
+			OPCPropertyDataType:     int16(11),
+			OPCPropertyAccessRights: int32(1),
+		},
+	}}
+}
+
+func TestReadItemProperties(t *testing.T) {
+	r := newFakePropertyReader()
+	properties, err := ReadItemProperties(r, "numeric.sin.float")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &ItemProperties{
+		DataType:     4,
+		AccessRights: 3,
+		ScanRate:     100 * time.Millisecond,
+		EUUnits:      "degC",
+		EUHigh:       1,
+		EULow:        -1,
+		Description:  "sine wave",
+	}
+	if !reflect.DeepEqual(properties, want) {
+		t.Fatalf("got %+v, want %+v", properties, want)
+	}
+	if !properties.Readable() || !properties.Writable() {
+		t.Fatal("item should be readable and writable")
+	}
+	for _, id := range r.requested {
+		if id == OPCPropertyValue {
+			t.Fatal("only the standard properties should be read")
+		}
+	}
+
+	r.requested = nil
+	properties, err = ReadItemProperties(r, "storage.bool.reg01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.requested) != 2 || properties.DataType != 11 || !properties.Readable() || properties.Writable() {
+		t.Fatalf("unexpected properties %+v, requested %v", properties, r.requested)
+	}
+
+	if _, err := ReadItemProperties(r, "unknown"); err == nil {
+		t.Fatal("unknown item should fail")
+	}
+}
+
+func TestAttachProperties(t *testing.T) {
+	root := &Tree{"root", nil, []*Tree{}, []Leaf{}}
+	numeric := &Tree{"numeric", root, []*Tree{}, []Leaf{{Name: "float", ItemId: "numeric.sin.float"}}}
+	root.Branches = append(root.Branches, numeric)
+	root.Leaves = append(root.Leaves, Leaf{Name: "unknown", ItemId: "unknown"})
+
+	attachProperties(newFakePropertyReader(), root)
+	if root.Leaves[0].Properties != nil {
+		t.Error("leaf with unreadable properties should be left without")
+	}
+	if p := numeric.Leaves[0].Properties; p == nil || p.EUUnits != "degC" {
+		t.Errorf("properties should be attached to the leaf, got %+v", p)
+	}
+}
@@ -0,0 +1,154 @@
+//go:build windows
+// +build windows
+
+package opcda
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	ole "github.com/go-ole/go-ole"
+)
+
+// QueryAvailableProperties returns the properties the server provides for the item.
+// It calls QueryAvailableProperties(ItemID, Count, PropertyIDs, Descriptions, DataTypes).
+func (ao *AutomationObject) QueryAvailableProperties(itemID string) ([]PropertyInfo, error) {
+	if !ao.IsConnected() {
+		return nil, fmt.Errorf("cannot query properties: %w", ErrNotConnected)
+	}
+	item, err := variantFromValue(itemID)
+	if err != nil {
+		return nil, err
+	}
+	defer ole.VariantClear(&item)
+
+	var count int32
+	var ids, descriptions, dataTypes *ole.SafeArray
+	_, err = invokeMethod(oleDispatch(ao.opc), "QueryAvailableProperties",
+		item,
+		byrefInt32(&count),
+		byrefArray(ole.VT_I4, &ids),
+		byrefArray(ole.VT_BSTR, &descriptions),
+		byrefArray(ole.VT_I2, &dataTypes),
+	)
+	defer destroySafeArray(ids)
+	defer destroySafeArray(descriptions)
+	defer destroySafeArray(dataTypes)
+	if err != nil {
+		return nil, fmt.Errorf("cannot query properties of %s: %w", itemID, refineOleError(err))
+	}
+	if count == 0 {
+		return []PropertyInfo{}, nil
+	}
+
+	propertyIDs, err := int32sFromSafeArray(ids)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode property IDs: %w", err)
+	}
+	texts, err := stringsFromSafeArray(descriptions)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode property descriptions: %w", err)
+	}
+	types, err := int32sFromSafeArray(dataTypes)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode property data types: %w", err)
+	}
+	if len(texts) != len(propertyIDs) || len(types) != len(propertyIDs) {
+		return nil, errors.New("QueryAvailableProperties returned arrays of unexpected length")
+	}
+	infos := make([]PropertyInfo, len(propertyIDs))
+	for i, id := range propertyIDs {
+		infos[i] = PropertyInfo{ID: id, Description: texts[i], DataType: uint16(types[i])}
+	}
+	return infos, nil
+}
+
+// GetItemProperties reads the properties with the ids of the item.
+// It calls GetItemProperties(ItemID, Count, PropertyIDs, PropertyValues, Errors).
+func (ao *AutomationObject) GetItemProperties(itemID string, ids ...int32) ([]Property, error) {
+	if !ao.IsConnected() {
+		return nil, fmt.Errorf("cannot read properties: %w", ErrNotConnected)
+	}
+	if len(ids) == 0 {
+		return []Property{}, nil
+	}
+	item, err := variantFromValue(itemID)
+	if err != nil {
+		return nil, err
+	}
+	defer ole.VariantClear(&item)
+	propertyIDs, err := safeArrayFromInt32s(ids)
+	if err != nil {
+		return nil, err
+	}
+	defer destroySafeArray(propertyIDs)
+
+	var values, errs *ole.SafeArray
+	_, err = invokeMethod(oleDispatch(ao.opc), "GetItemProperties",
+		item,
+		ole.NewVariant(ole.VT_I4, int64(len(ids))),
+		byrefArray(ole.VT_I4, &propertyIDs),
+		byrefArray(ole.VT_VARIANT, &values),
+		byrefArray(ole.VT_I4, &errs),
+	)
+	defer destroySafeArray(values)
+	defer destroySafeArray(errs)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read properties of %s: %w", itemID, refineOleError(err))
+	}
+
+	propertyValues, err := valuesFromSafeArray(values)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode property values: %w", err)
+	}
+	itemErrs, err := int32sFromSafeArray(errs)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode property errors: %w", err)
+	}
+	if len(propertyValues) != len(ids) || len(itemErrs) != len(ids) {
+		return nil, errors.New("GetItemProperties returned arrays of unexpected length")
+	}
+	properties := make([]Property, len(ids))
+	for i, id := range ids {
+		properties[i] = Property{ID: id, Value: propertyValues[i]}
+		if hresultFailed(itemErrs[i]) {
+			properties[i] = Property{ID: id, Err: fmt.Errorf("cannot read property %d of %s: %w", id, itemID, hresultError(itemErrs[i]))}
+		}
+	}
+	return properties, nil
+}
+
+// QueryAvailableProperties returns the properties the server provides for the item.
+func (conn *opcConnectionImpl) QueryAvailableProperties(itemID string) ([]PropertyInfo, error) {
+	var infos []PropertyInfo
+	var err error
+	conn.mu.do(context.Background(), func() {
+		infos, err = conn.AutomationObject.QueryAvailableProperties(itemID)
+	})
+	return infos, err
+}
+
+// GetItemProperties reads the properties with the ids of the item.
+func (conn *opcConnectionImpl) GetItemProperties(itemID string, ids ...int32) ([]Property, error) {
+	var properties []Property
+	var err error
+	conn.mu.do(context.Background(), func() {
+		properties, err = conn.AutomationObject.GetItemProperties(itemID, ids...)
+	})
+	return properties, err
+}
+
+// QueryAvailableProperties returns the properties the server provides for the item.
+func (b *browserImpl) QueryAvailableProperties(itemID string) ([]PropertyInfo, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.AutomationObject.QueryAvailableProperties(itemID)
+}
+
+// GetItemProperties reads the properties with the ids of the item.
+func (b *browserImpl) GetItemProperties(itemID string, ids ...int32) ([]Property, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.AutomationObject.GetItemProperties(itemID, ids...)
+}
@@ -0,0 +1,135 @@
+package opcda
+
+import "fmt"
+
+//Quality is the OPC quality of an item. Its low byte is laid out as QQSSSSLL:
+//the major status QQ, the substatus SSSS and the limit bits LL. The high byte
+//is vendor specific and ignored when decoding.
+type Quality int16
+
+const (
+	//OPCQuality defines the quality of the OPC items, each with its substatus:
+	//Bad
+	OPCQualityBad                   Quality = 0x00
+	OPCQualityConfigError           Quality = 0x04
+	OPCQualityNotConnected          Quality = 0x08
+	OPCQualityDeviceFailure         Quality = 0x0C
+	OPCQualitySensorFailure         Quality = 0x10
+	OPCQualityLastKnown             Quality = 0x14
+	OPCQualityCommFailure           Quality = 0x18
+	OPCQualityOutOfService          Quality = 0x1C
+	OPCQualityWaitingForInitialData Quality = 0x20
+	//Uncertain
+	OPCQualityUncertain   Quality = 0x40
+	OPCQualityLastUsable  Quality = 0x44
+	OPCQualitySensorCal   Quality = 0x50
+	OPCQualityEGUExceeded Quality = 0x54
+	OPCQualitySubNormal   Quality = 0x58
+	//Good
+	OPCQualityGood          Quality = 0xC0
+	OPCQualityLocalOverride Quality = 0xD8
+	OPCQualityGoodButForced Quality = OPCQualityLocalOverride
+	//Mask of the major status
+	OPCQualityMask Quality = 0xC0
+	//Mask of the major status and the substatus
+	OPCQualityStatusMask Quality = 0xFC
+	//Mask of the limit bits
+	OPCQualityLimitMask Quality = 0x03
+)
+
+//QualityLimit tells if the value of an item is limited.
+type QualityLimit int16
+
+const (
+	//OPCLimit defines the limit bits of the quality:
+	OPCLimitNone     QualityLimit = 0
+	OPCLimitLow      QualityLimit = 1
+	OPCLimitHigh     QualityLimit = 2
+	OPCLimitConstant QualityLimit = 3
+)
+
+//String returns the name of the limit.
+func (l QualityLimit) String() string {
+	switch l {
+	case OPCLimitNone:
+		return "Not Limited"
+	case OPCLimitLow:
+		return "Low Limited"
+	case OPCLimitHigh:
+		return "High Limited"
+	case OPCLimitConstant:
+		return "Constant"
+	}
+	return fmt.Sprintf("QualityLimit(%d)", int16(l))
+}
+
+//qualityNames are the names of the status codes.
+var qualityNames = map[Quality]string{
+	OPCQualityBad:                   "Bad",
+	OPCQualityConfigError:           "Bad: Config Error",
+	OPCQualityNotConnected:          "Bad: Not Connected",
+	OPCQualityDeviceFailure:         "Bad: Device Failure",
+	OPCQualitySensorFailure:         "Bad: Sensor Failure",
+	OPCQualityLastKnown:             "Bad: Last Known Value",
+	OPCQualityCommFailure:           "Bad: Comm Failure",
+	OPCQualityOutOfService:          "Bad: Out of Service",
+	OPCQualityWaitingForInitialData: "Bad: Waiting for Initial Data",
+	OPCQualityUncertain:             "Uncertain",
+	OPCQualityLastUsable:            "Uncertain: Last Usable Value",
+	OPCQualitySensorCal:             "Uncertain: Sensor Not Accurate",
+	OPCQualityEGUExceeded:           "Uncertain: EU Units Exceeded",
+	OPCQualitySubNormal:             "Uncertain: Sub-Normal",
+	OPCQualityGood:                  "Good",
+	OPCQualityLocalOverride:         "Good: Local Override",
+}
+
+//Major returns the major status: OPCQualityGood, OPCQualityUncertain or OPCQualityBad.
+//The unused major status 0x80 is returned as it is.
+func (q Quality) Major() Quality {
+	return q & OPCQualityMask
+}
+
+//Status returns the major status with the substatus, e.g. OPCQualityCommFailure.
+func (q Quality) Status() Quality {
+	return q & OPCQualityStatusMask
+}
+
+//Limit returns the limit bits.
+func (q Quality) Limit() QualityLimit {
+	return QualityLimit(q & OPCQualityLimitMask)
+}
+
+//IsGood checks for the major status good, regardless of substatus and limit.
+func (q Quality) IsGood() bool {
+	return q.Major() == OPCQualityGood
+}
+
+//IsUncertain checks for the major status uncertain.
+func (q Quality) IsUncertain() bool {
+	return q.Major() == OPCQualityUncertain
+}
+
+//IsBad checks for the major status bad. The unused major status is bad too.
+func (q Quality) IsBad() bool {
+	return !q.IsGood() && !q.IsUncertain()
+}
+
+//String returns the status and the limit, e.g. "Uncertain: EU Units Exceeded (High Limited)".
+//Unknown substatus codes are shown with their number.
+func (q Quality) String() string {
+	name, ok := qualityNames[q.Status()]
+	if !ok {
+		major := "Bad"
+		switch q.Major() {
+		case OPCQualityGood:
+			major = "Good"
+		case OPCQualityUncertain:
+			major = "Uncertain"
+		}
+		name = fmt.Sprintf("%s: Substatus %d", major, int16(q.Status()&^OPCQualityMask)>>2)
+	}
+	if q.Limit() != OPCLimitNone {
+		name += " (" + q.Limit().String() + ")"
+	}
+	return name
+}
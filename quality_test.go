@@ -0,0 +1,66 @@
+package opcda
+
+import "testing"
+
+func TestQuality(t *testing.T) {
+	tests := []struct {
+		quality   Quality
+		good      bool
+		uncertain bool
+		bad       bool
+		status    Quality
+		limit     QualityLimit
+		text      string
+	}{
+		{192, true, false, false, OPCQualityGood, OPCLimitNone, "Good"},
+		{193, true, false, false, OPCQualityGood, OPCLimitLow, "Good (Low Limited)"},
+		{194, true, false, false, OPCQualityGood, OPCLimitHigh, "Good (High Limited)"},
+		{195, true, false, false, OPCQualityGood, OPCLimitConstant, "Good (Constant)"},
+		{216, true, false, false, OPCQualityLocalOverride, OPCLimitNone, "Good: Local Override"},
+		{0, false, false, true, OPCQualityBad, OPCLimitNone, "Bad"},
+		{0x04, false, false, true, OPCQualityConfigError, OPCLimitNone, "Bad: Config Error"},
+		{0x08, false, false, true, OPCQualityNotConnected, OPCLimitNone, "Bad: Not Connected"},
+		{0x0C, false, false, true, OPCQualityDeviceFailure, OPCLimitNone, "Bad: Device Failure"},
+		{0x13, false, false, true, OPCQualitySensorFailure, OPCLimitConstant, "Bad: Sensor Failure (Constant)"},
+		{0x14, false, false, true, OPCQualityLastKnown, OPCLimitNone, "Bad: Last Known Value"},
+		{0x18, false, false, true, OPCQualityCommFailure, OPCLimitNone, "Bad: Comm Failure"},
+		{0x1C, false, false, true, OPCQualityOutOfService, OPCLimitNone, "Bad: Out of Service"},
+		{0x20, false, false, true, OPCQualityWaitingForInitialData, OPCLimitNone, "Bad: Waiting for Initial Data"},
+		{0x40, false, true, false, OPCQualityUncertain, OPCLimitNone, "Uncertain"},
+		{0x44, false, true, false, OPCQualityLastUsable, OPCLimitNone, "Uncertain: Last Usable Value"},
+		{0x50, false, true, false, OPCQualitySensorCal, OPCLimitNone, "Uncertain: Sensor Not Accurate"},
+		{0x56, false, true, false, OPCQualityEGUExceeded, OPCLimitHigh, "Uncertain: EU Units Exceeded (High Limited)"},
+		{0x58, false, true, false, OPCQualitySubNormal, OPCLimitNone, "Uncertain: Sub-Normal"},
+		{0x48, false, true, false, 0x48, OPCLimitNone, "Uncertain: Substatus 2"},
+		{0x80, false, false, true, 0x80, OPCLimitNone, "Bad: Substatus 0"},
+		{0x01C0, true, false, false, OPCQualityGood, OPCLimitNone, "Good"}, // vendor bits
+	}
+	for _, test := range tests {
+		q := test.quality
+		if q.IsGood() != test.good || q.IsUncertain() != test.uncertain || q.IsBad() != test.bad {
+			t.Errorf("%d: good %v, uncertain %v, bad %v", q, q.IsGood(), q.IsUncertain(), q.IsBad())
+		}
+		if q.Status()&0xFF != test.status {
+			t.Errorf("%d: status %d, want %d", q, q.Status(), test.status)
+		}
+		if q.Limit() != test.limit {
+			t.Errorf("%d: limit %v, want %v", q, q.Limit(), test.limit)
+		}
+		if q.String() != test.text {
+			t.Errorf("%d: %q, want %q", q, q.String(), test.text)
+		}
+	}
+}
+
+func TestItemGood(t *testing.T) {
+	for q := Quality(192); q <= 195; q++ {
+		item := Item{Quality: q}
+		if !item.Good() {
+			t.Errorf("item with quality %d should be good", q)
+		}
+	}
+	item := Item{Quality: OPCQualityLastUsable}
+	if item.Good() {
+		t.Error("uncertain item should not be good")
+	}
+}
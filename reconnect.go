@@ -0,0 +1,212 @@
+package opcda
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+//ReconnectPolicy configures how a lost connection is re-established. The
+//attempts run in the background; reads and writes fail with ErrReconnecting
+//until the connection is back.
+//
+//The delay after the n-th failed attempt is Interval * Multiplier^(n-1),
+//limited to MaxInterval and randomized by up to ±Jitter of itself.
+//The policy gives up after MaxAttempts attempts or if the next attempt would
+//start later than MaxElapsed after the connection was lost. The next read or
+//write that finds the connection lost starts reconnecting again.
+type ReconnectPolicy struct {
+	//Interval is the delay after the first failed attempt.
+	Interval time.Duration
+	//MaxInterval limits the delay, zero means no limit.
+	MaxInterval time.Duration
+	//Multiplier grows the delay after every failed attempt, values below 1 keep it constant.
+	Multiplier float64
+	//Jitter is the fraction between 0 and 1 by which the delay is randomized.
+	Jitter float64
+	//MaxAttempts is the number of attempts before giving up, zero means no limit.
+	MaxAttempts int
+	//MaxElapsed is the time after which no further attempt is started, zero means no limit.
+	MaxElapsed time.Duration
+
+	//OnDisconnect is called with the cause when the connection is lost.
+	OnDisconnect func(err error)
+	//OnReconnect is called when the connection is back after attempts attempts
+	//and downtime since it was lost.
+	OnReconnect func(attempts int, downtime time.Duration)
+	//OnReconnectFailed is called with a *ReconnectError when the policy gives up.
+	OnReconnectFailed func(err error)
+}
+
+//DefaultReconnectPolicy starts retrying after 100 milliseconds, doubles the
+//delay up to 30 seconds and never gives up.
+var DefaultReconnectPolicy = ReconnectPolicy{
+	Interval:    100 * time.Millisecond,
+	MaxInterval: 30 * time.Second,
+	Multiplier:  2,
+	Jitter:      0.2,
+}
+
+//validate checks the policy before it is used.
+func (p ReconnectPolicy) validate() error {
+	switch {
+	case p.Interval <= 0:
+		return errors.New("reconnect interval must be positive")
+	case p.MaxInterval < 0:
+		return errors.New("maximum reconnect interval must not be negative")
+	case p.MaxInterval > 0 && p.MaxInterval < p.Interval:
+		return errors.New("maximum reconnect interval must not be smaller than the interval")
+	case p.Jitter < 0 || p.Jitter > 1:
+		return errors.New("reconnect jitter must be between 0 and 1")
+	case p.MaxAttempts < 0:
+		return errors.New("maximum reconnect attempts must not be negative")
+	case p.MaxElapsed < 0:
+		return errors.New("maximum reconnect time must not be negative")
+	}
+	return nil
+}
+
+//delay returns the delay after the failed attempt, random is in [0, 1).
+func (p ReconnectPolicy) delay(attempt int, random float64) time.Duration {
+	limit := float64(math.MaxInt64 / 2)
+	if p.MaxInterval > 0 {
+		limit = float64(p.MaxInterval)
+	}
+	d := float64(p.Interval)
+	for i := 1; i < attempt && p.Multiplier > 1 && d < limit; i++ {
+		d *= p.Multiplier
+	}
+	d = math.Min(d, limit)
+	d += d * p.Jitter * (2*random - 1)
+	return time.Duration(d)
+}
+
+//exhausted reports if the policy gives up after attempts failed attempts when
+//the next attempt would start at elapsed.
+func (p ReconnectPolicy) exhausted(attempts int, elapsed time.Duration) bool {
+	if p.MaxAttempts > 0 && attempts >= p.MaxAttempts {
+		return true
+	}
+	return p.MaxElapsed > 0 && elapsed > p.MaxElapsed
+}
+
+//clock is the time source of the reconnects, which is replaced in tests.
+type clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+//reconnector runs the attempts of a ReconnectPolicy in its own goroutine.
+//There is at most one round of attempts at a time; a round ends when an
+//attempt succeeds, the policy gives up or the reconnector is closed.
+type reconnector struct {
+	policy  ReconnectPolicy
+	clock   clock
+	random  func() float64
+	attempt func() error
+
+	mu      sync.Mutex
+	running bool
+	closed  bool
+	done    chan struct{}
+	stop    chan struct{}
+}
+
+//newReconnector returns a reconnector which calls attempt to reconnect once.
+func newReconnector(policy ReconnectPolicy, attempt func() error) *reconnector {
+	return &reconnector{
+		policy:  policy,
+		clock:   realClock{},
+		random:  rand.Float64,
+		attempt: attempt,
+		stop:    make(chan struct{}),
+	}
+}
+
+//reconnecting reports if a round of attempts is running.
+func (r *reconnector) reconnecting() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.running
+}
+
+//start starts a round of attempts because of cause, unless one is running or
+//the reconnector is closed. The returned channel is closed when the round ends.
+func (r *reconnector) start(cause error) <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.running {
+		return r.done
+	}
+	r.done = make(chan struct{})
+	if r.closed {
+		close(r.done)
+		return r.done
+	}
+	r.running = true
+	go r.run(cause, r.done)
+	return r.done
+}
+
+//run makes the attempts of a round and calls the hooks of the policy.
+func (r *reconnector) run(cause error, done chan struct{}) {
+	defer func() {
+		r.mu.Lock()
+		r.running = false
+		r.mu.Unlock()
+		close(done)
+	}()
+	p := r.policy
+	if p.OnDisconnect != nil {
+		p.OnDisconnect(cause)
+	}
+	lost := r.clock.Now()
+	for attempt := 1; ; attempt++ {
+		err := r.attempt()
+		elapsed := r.clock.Now().Sub(lost)
+		if err == nil {
+			if p.OnReconnect != nil {
+				p.OnReconnect(attempt, elapsed)
+			}
+			return
+		}
+		delay := p.delay(attempt, r.random())
+		if p.exhausted(attempt, elapsed+delay) {
+			if p.OnReconnectFailed != nil {
+				p.OnReconnectFailed(&ReconnectError{Attempts: attempt, Elapsed: elapsed, Err: err})
+			}
+			return
+		}
+		select {
+		case <-r.clock.After(delay):
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+//close stops the attempts and waits for a running attempt to finish.
+func (r *reconnector) close() {
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.stop)
+	}
+	running, done := r.running, r.done
+	r.mu.Unlock()
+	if running {
+		<-done
+	}
+}
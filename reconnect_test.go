@@ -0,0 +1,244 @@
+package opcda
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+//fakeClock advances only when told to. Every call of After is reported on
+//waits so that the test knows the reconnector is sleeping.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []fakeTimer
+	waits  chan time.Duration
+}
+
+type fakeTimer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2019, 6, 21, 15, 0, 0, 0, time.UTC), waits: make(chan time.Duration, 100)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := fakeTimer{at: c.now.Add(d), c: make(chan time.Time, 1)}
+	c.timers = append(c.timers, t)
+	c.waits <- d
+	return t.c
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.at.After(c.now) {
+			pending = append(pending, t)
+			continue
+		}
+		t.c <- c.now
+	}
+	c.timers = pending
+}
+
+//wait returns the duration of the next sleep of the reconnector.
+func (c *fakeClock) wait(t *testing.T) time.Duration {
+	t.Helper()
+	select {
+	case d := <-c.waits:
+		return d
+	case <-time.After(time.Second):
+		t.Fatal("reconnector does not wait")
+		return 0
+	}
+}
+
+//newTestReconnector fails the first failures attempts and counts them.
+func newTestReconnector(policy ReconnectPolicy, failures int) (*reconnector, *fakeClock, *int) {
+	attempts := 0
+	r := newReconnector(policy, func() error {
+		attempts++
+		if attempts <= failures {
+			return errors.New("server down")
+		}
+		return nil
+	})
+	clock := newFakeClock()
+	r.clock = clock
+	r.random = func() float64 { return 0.5 }
+	return r, clock, &attempts
+}
+
+func TestReconnectPolicyDelay(t *testing.T) {
+	p := ReconnectPolicy{Interval: 100 * time.Millisecond, MaxInterval: time.Second, Multiplier: 2}
+	expected := []time.Duration{100, 200, 400, 800, 1000, 1000}
+	for i, d := range expected {
+		if delay := p.delay(i+1, 0.5); delay != d*time.Millisecond {
+			t.Errorf("attempt %d: expected %v, got %v", i+1, d*time.Millisecond, delay)
+		}
+	}
+
+	p.Jitter = 0.5
+	if low, high := p.delay(1, 0), p.delay(1, 0.999999); low != 50*time.Millisecond || high < 149*time.Millisecond || high > 150*time.Millisecond {
+		t.Errorf("jitter should randomize by half of the delay, got %v and %v", low, high)
+	}
+
+	constant := ReconnectPolicy{Interval: time.Second}
+	if constant.delay(10, 0.5) != time.Second {
+		t.Error("the delay should be constant without multiplier")
+	}
+	unlimited := ReconnectPolicy{Interval: time.Second, Multiplier: 10}
+	if d := unlimited.delay(1000, 0.5); d <= 0 {
+		t.Errorf("the delay should not overflow, got %v", d)
+	}
+}
+
+func TestReconnectPolicyValidate(t *testing.T) {
+	if err := DefaultReconnectPolicy.validate(); err != nil {
+		t.Fatal(err)
+	}
+	invalid := []ReconnectPolicy{
+		{},
+		{Interval: time.Second, MaxInterval: time.Millisecond},
+		{Interval: time.Second, Jitter: 2},
+		{Interval: time.Second, MaxAttempts: -1},
+		{Interval: time.Second, MaxElapsed: -time.Second},
+	}
+	for _, p := range invalid {
+		if p.validate() == nil {
+			t.Errorf("%+v should be invalid", p)
+		}
+	}
+}
+
+func TestReconnectorBackoff(t *testing.T) {
+	var disconnected error
+	var reconnected int
+	var downtime time.Duration
+	policy := ReconnectPolicy{
+		Interval:     time.Second,
+		Multiplier:   2,
+		OnDisconnect: func(err error) { disconnected = err },
+		OnReconnect: func(attempts int, d time.Duration) {
+			reconnected, downtime = attempts, d
+		},
+	}
+	r, clock, attempts := newTestReconnector(policy, 3)
+	done := r.start(errors.New("read failed"))
+	if !r.reconnecting() {
+		t.Fatal("reconnector should be running")
+	}
+	if r.start(errors.New("second failure")) != done {
+		t.Fatal("a running round should not be started again")
+	}
+	for _, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		d := clock.wait(t)
+		if d != expected {
+			t.Errorf("expected a delay of %v, got %v", expected, d)
+		}
+		clock.Advance(d)
+	}
+	<-done
+	if r.reconnecting() {
+		t.Error("reconnector should have stopped")
+	}
+	if *attempts != 4 || reconnected != 4 {
+		t.Errorf("expected 4 attempts, got %d and %d", *attempts, reconnected)
+	}
+	if downtime != 7*time.Second {
+		t.Errorf("expected a downtime of 7s, got %v", downtime)
+	}
+	if disconnected == nil || disconnected.Error() != "read failed" {
+		t.Errorf("OnDisconnect should get the cause, got %v", disconnected)
+	}
+}
+
+func TestReconnectorMaxAttempts(t *testing.T) {
+	var failed error
+	policy := ReconnectPolicy{Interval: time.Second, MaxAttempts: 2, OnReconnectFailed: func(err error) { failed = err }}
+	r, clock, attempts := newTestReconnector(policy, 10)
+	done := r.start(errors.New("read failed"))
+	clock.Advance(clock.wait(t))
+	<-done
+	if *attempts != 2 {
+		t.Errorf("expected 2 attempts, got %d", *attempts)
+	}
+	var rerr *ReconnectError
+	if !errors.As(failed, &rerr) || rerr.Attempts != 2 || rerr.Elapsed != time.Second {
+		t.Fatalf("OnReconnectFailed should get a ReconnectError, got %v", failed)
+	}
+	if !errors.Is(failed, ErrNotConnected) {
+		t.Error("a ReconnectError should match ErrNotConnected")
+	}
+
+	//a new failure starts reconnecting again
+	done = r.start(errors.New("read failed again"))
+	clock.Advance(clock.wait(t))
+	<-done
+	if *attempts != 4 {
+		t.Errorf("expected 4 attempts, got %d", *attempts)
+	}
+}
+
+func TestReconnectorMaxElapsed(t *testing.T) {
+	var failed error
+	policy := ReconnectPolicy{Interval: time.Second, Multiplier: 2, MaxElapsed: 5 * time.Second, OnReconnectFailed: func(err error) { failed = err }}
+	r, clock, attempts := newTestReconnector(policy, 10)
+	done := r.start(errors.New("read failed"))
+	clock.Advance(clock.wait(t))
+	clock.Advance(clock.wait(t))
+	//the next delay of 4s would end after 7s
+	<-done
+	if *attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", *attempts)
+	}
+	var rerr *ReconnectError
+	if !errors.As(failed, &rerr) || rerr.Elapsed != 3*time.Second {
+		t.Errorf("expected to give up after 3s, got %v", failed)
+	}
+}
+
+func TestReconnectorClose(t *testing.T) {
+	var failed bool
+	policy := ReconnectPolicy{Interval: time.Hour, OnReconnectFailed: func(error) { failed = true }}
+	r, clock, _ := newTestReconnector(policy, 10)
+	done := r.start(errors.New("read failed"))
+	clock.wait(t)
+	r.close()
+	select {
+	case <-done:
+	default:
+		t.Fatal("close should end the round")
+	}
+	if failed {
+		t.Error("closing is not a failed reconnect")
+	}
+	select {
+	case <-r.start(errors.New("after close")):
+	default:
+		t.Fatal("a closed reconnector should not start")
+	}
+	if r.reconnecting() {
+		t.Error("a closed reconnector should not run")
+	}
+}
+
+func TestReconnectingIsNotConnected(t *testing.T) {
+	if !errors.Is(ErrReconnecting, ErrNotConnected) {
+		t.Error("ErrReconnecting should match ErrNotConnected")
+	}
+}
@@ -0,0 +1,326 @@
+package recording
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rxue92/opcda"
+)
+
+//Option configures a Player.
+type Option func(*Player)
+
+//WithSpeed plays the recording speed times faster than it was recorded,
+//e.g. 60 plays an hour in a minute. The default is the original speed.
+func WithSpeed(speed float64) Option {
+	return func(p *Player) {
+		p.speed = speed
+	}
+}
+
+//WithStepping makes the player stay at a recorded call until Step is called,
+//instead of following the clock.
+func WithStepping() Option {
+	return func(p *Player) {
+		p.stepping = true
+	}
+}
+
+//WithClock makes the player take the time from now instead of time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(p *Player) {
+		p.now = now
+	}
+}
+
+//Player is a Connection which plays a recording back. The playback starts
+//when the player is created. ReadContext returns the results of the last
+//recorded read at the playback position, including its error and after its
+//latency; ReadItemContext returns the last recorded item of the tag. Once the
+//recording has been played back, the reads fail with ErrEndOfRecording.
+//
+//Add, Remove and Write do not change the recording and return nil.
+type Player struct {
+	speed    float64
+	stepping bool
+	now      func() time.Time
+
+	mu        sync.Mutex
+	dec       *json.Decoder
+	file      io.Closer
+	next      *event
+	err       error
+	start     time.Time
+	started   time.Time
+	last      *event
+	items     map[string]opcda.ReadResult
+	exhausted bool
+	closed    bool
+}
+
+//NewPlayer returns a player of the recording read from r.
+func NewPlayer(r io.Reader, opts ...Option) (*Player, error) {
+	p := &Player{speed: 1, now: time.Now, items: make(map[string]opcda.ReadResult)}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(p)
+		}
+	}
+	if p.speed <= 0 {
+		return nil, errors.New("speed must be positive")
+	}
+	gz, err := gzip.NewReader(bufio.NewReader(r))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read recording: %s", err)
+	}
+	p.dec = json.NewDecoder(gz)
+	var h header
+	if err := p.dec.Decode(&h); err != nil || h.Format != format {
+		return nil, errors.New("not a recording")
+	}
+	if h.Version != version {
+		return nil, fmt.Errorf("unsupported recording version %d", h.Version)
+	}
+	p.start, p.started = h.Start, p.now()
+	p.peek()
+	return p, nil
+}
+
+//Open returns a player of the recording in the file at path.
+func Open(path string, opts ...Option) (*Player, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := NewPlayer(f, opts...)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	p.file = f
+	return p, nil
+}
+
+//peek decodes the next event. A recording which was cut off, e.g. because
+//the recorder was killed, ends at the last complete event.
+func (p *Player) peek() {
+	var e event
+	err := p.dec.Decode(&e)
+	switch {
+	case err == nil:
+		p.next = &e
+	case err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF):
+		p.next = nil
+	default:
+		p.next, p.err = nil, fmt.Errorf("cannot read recording: %s", err)
+	}
+}
+
+//apply plays the next event back.
+func (p *Player) apply() *event {
+	e := p.next
+	switch e.Op {
+	case opRead:
+		p.last = e
+		for tag, r := range e.Results {
+			p.items[tag] = r.readResult()
+		}
+	case opReadItem:
+		if r, ok := e.Results[e.Tag]; ok {
+			p.items[e.Tag] = r.readResult()
+		}
+	}
+	p.peek()
+	return e
+}
+
+//Position returns the recorded time at which the player is.
+func (p *Player) Position() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.position()
+}
+
+//position must be called with the lock held.
+func (p *Player) position() time.Time {
+	if p.stepping {
+		if p.last != nil {
+			return p.last.Time
+		}
+		return p.start
+	}
+	elapsed := float64(p.now().Sub(p.started)) * p.speed
+	return p.start.Add(time.Duration(elapsed))
+}
+
+//Step plays the recording back up to the next recorded read and reports
+//if there was one. Once there was none, the reads fail with
+//ErrEndOfRecording. It only applies to players created WithStepping.
+func (p *Player) Step() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.stepping {
+		return false
+	}
+	for p.next != nil {
+		if op := p.apply().Op; op == opRead || op == opReadItem {
+			return true
+		}
+	}
+	p.exhausted = true
+	return false
+}
+
+//advance plays the recording back up to the current position and returns
+//ErrEndOfRecording if it had already ended at the previous read or Step. It
+//must be called with the lock held.
+func (p *Player) advance() error {
+	if p.closed {
+		return opcda.ErrNotConnected
+	}
+	if p.exhausted {
+		if p.err != nil {
+			return p.err
+		}
+		return ErrEndOfRecording
+	}
+	if p.stepping {
+		return nil
+	}
+	pos := p.position()
+	for p.next != nil && !p.next.Time.After(pos) {
+		p.apply()
+	}
+	p.exhausted = p.next == nil
+	return nil
+}
+
+//wait waits for the latency of the recorded call at the speed of the player.
+func (p *Player) wait(ctx context.Context, latency time.Duration) error {
+	if p.stepping || latency <= 0 {
+		return nil
+	}
+	t := time.NewTimer(time.Duration(float64(latency) / p.speed))
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+//Add does nothing.
+func (p *Player) Add(...string) error { return nil }
+
+//AddContext does nothing.
+func (p *Player) AddContext(context.Context, ...string) error { return nil }
+
+//Remove does nothing.
+func (p *Player) Remove(string) {}
+
+//Read returns the items of the last recorded read without an error.
+func (p *Player) Read() map[string]opcda.Item {
+	results, err := p.ReadContext(context.Background())
+	if err != nil {
+		return map[string]opcda.Item{}
+	}
+	return opcda.Items(results)
+}
+
+//ReadContext returns the results of the last recorded read.
+func (p *Player) ReadContext(ctx context.Context) (map[string]opcda.ReadResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	if err := p.advance(); err != nil {
+		p.mu.Unlock()
+		return nil, err
+	}
+	last := p.last
+	p.mu.Unlock()
+
+	results := make(map[string]opcda.ReadResult)
+	if last == nil {
+		return results, nil
+	}
+	if err := p.wait(ctx, last.Latency); err != nil {
+		return nil, err
+	}
+	if err := last.callError(); err != nil {
+		return results, err
+	}
+	for tag, r := range last.Results {
+		results[tag] = r.readResult()
+	}
+	return results, nil
+}
+
+//ReadItem returns the last recorded item of the tag, or an empty Item.
+func (p *Player) ReadItem(tag string) opcda.Item {
+	item, _ := p.ReadItemContext(context.Background(), tag)
+	return item
+}
+
+//ReadItemContext returns the last recorded item of the tag.
+func (p *Player) ReadItemContext(ctx context.Context, tag string) (opcda.Item, error) {
+	if err := ctx.Err(); err != nil {
+		return opcda.Item{}, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.advance(); err != nil {
+		return opcda.Item{}, err
+	}
+	r, ok := p.items[tag]
+	if !ok {
+		return opcda.Item{}, fmt.Errorf("%s: %w", tag, opcda.ErrTagNotFound)
+	}
+	return r.Item, r.Err
+}
+
+//Tags returns the tags which have been read so far in alphabetical order.
+func (p *Player) Tags() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	tags := make([]string, 0, len(p.items))
+	for tag := range p.items {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+//Write does nothing.
+func (p *Player) Write(string, interface{}) error { return nil }
+
+//WriteContext does nothing.
+func (p *Player) WriteContext(context.Context, string, interface{}) error { return nil }
+
+//IsConnected reports if the recording is not over and the last recorded
+//read did not fail as a whole.
+func (p *Player) IsConnected() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return !p.closed && !p.exhausted && (p.last == nil || p.last.Err == nil)
+}
+
+//Close closes the file of the recording, if it was opened with Open.
+func (p *Player) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	if p.file != nil {
+		p.file.Close()
+	}
+}
@@ -0,0 +1,266 @@
+package recording
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rxue92/opcda"
+	"github.com/rxue92/opcda/internal/wire"
+)
+
+//RecorderOption configures a Recorder.
+type RecorderOption func(*Recorder)
+
+//WithFlushInterval makes the recorder flush the recording every interval
+//instead of after every call. It compresses better, but if the process dies
+//the calls since the last flush are lost. The default is to flush after
+//every call.
+func WithFlushInterval(interval time.Duration) RecorderOption {
+	return func(r *Recorder) {
+		r.interval = interval
+	}
+}
+
+//Recorder is a Connection which records the reads, writes, adds and removes
+//of the connection it wraps. The recording is compressed and flushed after
+//every call, or every flush interval, so a recording cut off by a crash
+//can be played back up to its last flushed call.
+type Recorder struct {
+	conn     opcda.Connection
+	now      func() time.Time
+	interval time.Duration
+	done     chan struct{} //closed by Stop to end the flushing
+
+	mu      sync.Mutex
+	gz      *gzip.Writer
+	enc     *json.Encoder
+	file    io.Closer
+	err     error
+	stopped bool
+}
+
+//NewRecorder returns a recorder of conn which writes the recording to w.
+func NewRecorder(conn opcda.Connection, w io.Writer, opts ...RecorderOption) *Recorder {
+	return newRecorder(conn, w, time.Now, opts...)
+}
+
+//newRecorder returns a recorder which takes the time from now.
+func newRecorder(conn opcda.Connection, w io.Writer, now func() time.Time, opts ...RecorderOption) *Recorder {
+	gz := gzip.NewWriter(w)
+	r := &Recorder{conn: conn, now: now, done: make(chan struct{}), gz: gz, enc: json.NewEncoder(gz)}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(r)
+		}
+	}
+	r.err = r.enc.Encode(header{Format: format, Version: version, Start: r.now()})
+	if r.err == nil {
+		r.err = r.gz.Flush()
+	}
+	if r.interval > 0 {
+		go r.flushEvery(r.interval)
+	}
+	return r
+}
+
+//Create returns a recorder of conn which writes the recording to a new file
+//at path.
+func Create(path string, conn opcda.Connection, opts ...RecorderOption) (*Recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	r := NewRecorder(conn, f, opts...)
+	r.file = f
+	return r, nil
+}
+
+//record writes e unless the recording is stopped or failed.
+func (r *Recorder) record(e *event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopped || r.err != nil {
+		return
+	}
+	r.err = r.enc.Encode(e)
+	if r.err == nil && r.interval <= 0 {
+		r.err = r.gz.Flush()
+	}
+}
+
+//flushEvery flushes the recording every interval until it is stopped.
+func (r *Recorder) flushEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+			r.Flush()
+		}
+	}
+}
+
+//call runs f and records the event returned by f with the start time and
+//the latency of the call.
+func (r *Recorder) call(f func() *event) {
+	start := r.now()
+	e := f()
+	e.Time, e.Latency = start, r.now().Sub(start)
+	r.record(e)
+}
+
+//Err returns the first error of writing the recording. Once it failed the
+//calls are passed on without being recorded.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+//Flush writes the buffered calls to the recording, e.g. before a pause of a
+//recorder with a long flush interval.
+func (r *Recorder) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopped || r.err != nil {
+		return r.err
+	}
+	r.err = r.gz.Flush()
+	return r.err
+}
+
+//Stop ends the recording and closes its file, if it was created with
+//Create. The connection stays open and is used without recording.
+func (r *Recorder) Stop() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopped {
+		return r.err
+	}
+	r.stopped = true
+	close(r.done)
+	if err := r.gz.Close(); r.err == nil {
+		r.err = err
+	}
+	if r.file != nil {
+		if err := r.file.Close(); r.err == nil {
+			r.err = err
+		}
+	}
+	return r.err
+}
+
+//Add adds the tags and records them.
+func (r *Recorder) Add(tags ...string) error {
+	return r.AddContext(context.Background(), tags...)
+}
+
+//AddContext adds the tags and records them.
+func (r *Recorder) AddContext(ctx context.Context, tags ...string) error {
+	var err error
+	r.call(func() *event {
+		err = r.conn.AddContext(ctx, tags...)
+		return &event{Op: opAdd, Tags: tags, Err: wire.EncodeError(err)}
+	})
+	return err
+}
+
+//Remove removes the tag and records it.
+func (r *Recorder) Remove(tag string) {
+	r.call(func() *event {
+		r.conn.Remove(tag)
+		return &event{Op: opRemove, Tag: tag}
+	})
+}
+
+//Read reads the tags and records the items.
+func (r *Recorder) Read() map[string]opcda.Item {
+	var items map[string]opcda.Item
+	r.call(func() *event {
+		items = r.conn.Read()
+		results := make(map[string]*result, len(items))
+		for tag, item := range items {
+			results[tag] = newResult(item, nil)
+		}
+		return &event{Op: opRead, Results: results}
+	})
+	return items
+}
+
+//ReadContext reads the tags and records the results.
+func (r *Recorder) ReadContext(ctx context.Context) (map[string]opcda.ReadResult, error) {
+	var results map[string]opcda.ReadResult
+	var err error
+	r.call(func() *event {
+		results, err = r.conn.ReadContext(ctx)
+		recorded := make(map[string]*result, len(results))
+		for tag, res := range results {
+			recorded[tag] = newResult(res.Item, res.Err)
+		}
+		return &event{Op: opRead, Results: recorded, Err: wire.EncodeError(err)}
+	})
+	return results, err
+}
+
+//ReadItem reads the tag and records the item.
+func (r *Recorder) ReadItem(tag string) opcda.Item {
+	var item opcda.Item
+	r.call(func() *event {
+		item = r.conn.ReadItem(tag)
+		return &event{Op: opReadItem, Tag: tag, Results: map[string]*result{tag: newResult(item, nil)}}
+	})
+	return item
+}
+
+//ReadItemContext reads the tag and records the item or the error.
+func (r *Recorder) ReadItemContext(ctx context.Context, tag string) (opcda.Item, error) {
+	var item opcda.Item
+	var err error
+	r.call(func() *event {
+		item, err = r.conn.ReadItemContext(ctx, tag)
+		return &event{Op: opReadItem, Tag: tag, Results: map[string]*result{tag: newResult(item, err)}}
+	})
+	return item, err
+}
+
+//Tags returns the tags of the connection.
+func (r *Recorder) Tags() []string {
+	return r.conn.Tags()
+}
+
+//Write writes value to the tag and records it.
+func (r *Recorder) Write(tag string, value interface{}) error {
+	return r.WriteContext(context.Background(), tag, value)
+}
+
+//WriteContext writes value to the tag and records it.
+func (r *Recorder) WriteContext(ctx context.Context, tag string, value interface{}) error {
+	var err error
+	r.call(func() *event {
+		err = r.conn.WriteContext(ctx, tag, value)
+		e := &event{Op: opWrite, Tag: tag, Err: wire.EncodeError(err)}
+		if v, verr := wire.EncodeValue(value); verr == nil {
+			e.Value = &v
+		}
+		return e
+	})
+	return err
+}
+
+//IsConnected reports if the connection is connected.
+func (r *Recorder) IsConnected() bool {
+	return r.conn.IsConnected()
+}
+
+//Close stops the recording and closes the connection.
+func (r *Recorder) Close() {
+	r.Stop()
+	r.conn.Close()
+}
@@ -0,0 +1,96 @@
+//Package recording records the calls of an opcda.Connection to a file and
+//plays them back, e.g. to rerun analytics on the data of last night.
+//
+//	rec, err := recording.Create("night.rec.gz", conn)
+//	collector.Sync(rec, time.Second)
+//	...
+//	player, err := recording.Open("night.rec.gz", recording.WithSpeed(60))
+//	collector.Sync(player, time.Second)
+//
+//A recording is a gzip compressed stream of JSON lines. The first line is a
+//header, every other line a call with its time, latency, tags, values with
+//their Go types, qualities, timestamps and errors.
+package recording
+
+import (
+	"errors"
+	"time"
+
+	"github.com/rxue92/opcda"
+	"github.com/rxue92/opcda/internal/wire"
+)
+
+//format and version identify recordings in their header.
+const (
+	format  = "opcda-recording"
+	version = 1
+)
+
+//ErrEndOfRecording is returned by the reads of a Player after the recording
+//has been played back.
+var ErrEndOfRecording = errors.New("end of recording")
+
+//The operations of the recorded calls.
+const (
+	opAdd      = "add"
+	opRemove   = "remove"
+	opRead     = "read"
+	opReadItem = "readitem"
+	opWrite    = "write"
+)
+
+type header struct {
+	Format  string    `json:"format"`
+	Version int       `json:"version"`
+	Start   time.Time `json:"start"`
+}
+
+//event is a recorded call. Results holds the items of reads, the written
+//value is Value and Err is the error of the call as a whole.
+type event struct {
+	Time    time.Time          `json:"t"`
+	Latency time.Duration      `json:"lat,omitempty"`
+	Op      string             `json:"op"`
+	Tag     string             `json:"tag,omitempty"`
+	Tags    []string           `json:"tags,omitempty"`
+	Value   *wire.Value        `json:"v,omitempty"`
+	Results map[string]*result `json:"r,omitempty"`
+	Err     *wire.Error        `json:"err,omitempty"`
+}
+
+//result is a recorded item or the error why it could not be read.
+type result struct {
+	Value     wire.Value    `json:"v"`
+	Quality   opcda.Quality `json:"q"`
+	Timestamp time.Time     `json:"ts"`
+	Err       *wire.Error   `json:"err,omitempty"`
+}
+
+//newResult returns the result of an item and its error.
+func newResult(item opcda.Item, err error) *result {
+	if err != nil {
+		return &result{Err: wire.EncodeError(err)}
+	}
+	v, err := wire.EncodeValue(item.Value)
+	if err != nil {
+		return &result{Err: wire.EncodeError(err)}
+	}
+	return &result{Value: v, Quality: item.Quality, Timestamp: item.Timestamp}
+}
+
+//readResult returns the recorded result as it was read.
+func (r *result) readResult() opcda.ReadResult {
+	if r.Err != nil {
+		return opcda.ReadResult{Err: r.Err.Decode()}
+	}
+	v, err := r.Value.Decode()
+	if err != nil {
+		return opcda.ReadResult{Err: err}
+	}
+	return opcda.ReadResult{Item: opcda.Item{Value: v, Quality: r.Quality, Timestamp: r.Timestamp}}
+}
+
+//callError returns the recorded error of the call, or nil.
+func (e *event) callError() error {
+	return e.Err.Decode()
+}
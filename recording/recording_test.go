@@ -0,0 +1,340 @@
+package recording
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rxue92/opcda"
+	"github.com/rxue92/opcda/opcdatest"
+	"github.com/rxue92/opcda/simulator"
+)
+
+//testClock is a clock which only moves when told.
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func (c *testClock) Add(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+//record records a night of a saw and a step with an outage in between:
+//reads every second for 10 seconds, the server is lost from 4s to 6s.
+func record(t *testing.T, clock *testClock) *bytes.Buffer {
+	sim, err := simulator.New([]simulator.Tag{
+		{Name: "saw", Waveform: simulator.Saw{Min: 0, Max: 10, Period: 10 * time.Second}},
+		{Name: "mode", Waveform: simulator.Schedule{Steps: []simulator.Step{{At: 0, Value: "auto"}, {At: 5 * time.Second, Value: "manual"}}}},
+		{Name: "setpoint", Writable: true},
+	}, simulator.WithClock(clock.Now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, _ := sim.Connect()
+
+	var buf bytes.Buffer
+	rec := newRecorder(conn, &buf, clock.Now)
+	if err := rec.Add("saw", "mode", "setpoint", "missing"); err == nil {
+		t.Fatal("missing tag should fail")
+	}
+	for i := 0; i < 10; i++ {
+		if i == 4 {
+			sim.SetConnected(false)
+		}
+		if i == 6 {
+			sim.SetConnected(true)
+		}
+		rec.ReadContext(context.Background())
+		if i == 7 {
+			rec.Write("setpoint", int32(42))
+			rec.ReadItem("setpoint")
+		}
+		clock.Add(time.Second)
+	}
+	rec.Remove("mode")
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if !rec.IsConnected() || len(rec.Tags()) != 2 {
+		t.Error("connection should stay open after stop")
+	}
+	rec.Read()
+	rec.Close()
+	if conn.IsConnected() {
+		t.Error("close should close the connection")
+	}
+	return &buf
+}
+
+func TestReplay(t *testing.T) {
+	clock := &testClock{now: time.Unix(1000, 0)}
+	recorded := record(t, clock)
+
+	replay := &testClock{now: time.Unix(5000, 0)}
+	p, err := NewPlayer(recorded, WithClock(replay.Now), WithSpeed(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	results, err := p.ReadContext(context.Background())
+	if err != nil || results["saw"].Value != 0.0 || results["mode"].Value != "auto" {
+		t.Fatalf("unexpected first read %v: %v", results, err)
+	}
+	if _, ok := results["missing"]; ok {
+		t.Error("missing tag was never read")
+	}
+
+	replay.Add(1500 * time.Millisecond)
+	if pos := p.Position(); !pos.Equal(time.Unix(1003, 0)) {
+		t.Errorf("position should move at twice the speed, got %s", pos)
+	}
+	if v := p.ReadItem("saw").Value; v != 3.0 {
+		t.Errorf("expected saw at 3, got %v", v)
+	}
+
+	replay.Add(time.Second)
+	if _, err := p.ReadContext(context.Background()); !errors.Is(err, opcda.ErrNotConnected) {
+		t.Errorf("outage should be replayed, got %v", err)
+	}
+	if p.IsConnected() {
+		t.Error("player should be disconnected during the outage")
+	}
+	if v := p.ReadItem("mode").Value; v != "auto" {
+		t.Errorf("last item should be kept during the outage, got %v", v)
+	}
+
+	replay.Add(1500 * time.Millisecond)
+	items := p.Read()
+	if items["mode"].Value != "manual" || items["saw"].Value != 8.0 {
+		t.Errorf("unexpected items after the outage %v", items)
+	}
+	if v := p.ReadItem("setpoint").Value; v != int32(42) {
+		t.Errorf("written value should be read back with its type, got %#v", v)
+	}
+
+	replay.Add(time.Hour)
+	if items := p.Read(); items["saw"].Value != 9.0 {
+		t.Errorf("last read should be returned once, got %v", items)
+	}
+	if _, err := p.ReadContext(context.Background()); err != ErrEndOfRecording {
+		t.Errorf("expected end of recording, got %v", err)
+	}
+	if p.IsConnected() {
+		t.Error("player should be disconnected at the end")
+	}
+}
+
+func TestReplayStepping(t *testing.T) {
+	recorded := record(t, &testClock{now: time.Unix(1000, 0)})
+	p, err := NewPlayer(recorded, WithStepping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results, _ := p.ReadContext(context.Background()); len(results) != 0 {
+		t.Errorf("nothing should be read before the first step, got %v", results)
+	}
+	var saws []interface{}
+	for p.Step() {
+		if item, err := p.ReadItemContext(context.Background(), "saw"); err == nil {
+			saws = append(saws, item.Value)
+		}
+	}
+	if len(saws) != 11 || saws[0] != 0.0 || saws[10] != 9.0 {
+		t.Errorf("unexpected steps %v", saws)
+	}
+	if p.Position().IsZero() || len(p.Tags()) != 3 {
+		t.Errorf("unexpected state at the end: %s %v", p.Position(), p.Tags())
+	}
+	if _, err := p.ReadContext(context.Background()); !errors.Is(err, ErrEndOfRecording) {
+		t.Errorf("expected end of recording after the last step, got %v", err)
+	}
+	if _, err := p.ReadItemContext(context.Background(), "saw"); !errors.Is(err, ErrEndOfRecording) {
+		t.Errorf("expected end of recording after the last step, got %v", err)
+	}
+	if p.IsConnected() || p.Step() {
+		t.Error("player should stay at the end")
+	}
+}
+
+func TestReplayLatency(t *testing.T) {
+	var buf bytes.Buffer
+	rec := NewRecorder(&slowConn{delay: 50 * time.Millisecond}, &buf)
+	rec.ReadContext(context.Background())
+	rec.Stop()
+
+	p, _ := NewPlayer(&buf)
+	p.started = p.started.Add(-time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if _, err := p.ReadContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("read should wait for the recorded latency, got %v", err)
+	}
+}
+
+//slowConn is a connection which takes delay to read.
+type slowConn struct {
+	opcda.Connection
+	delay time.Duration
+}
+
+func (c *slowConn) ReadContext(ctx context.Context) (map[string]opcda.ReadResult, error) {
+	time.Sleep(c.delay)
+	return map[string]opcda.ReadResult{"tag": {Item: opcda.Item{Value: 1.0}}}, nil
+}
+
+func TestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recording")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "night.rec.gz")
+	sim, _ := simulator.New([]simulator.Tag{{Name: "tag"}})
+	conn, _ := sim.Connect("tag")
+	rec, err := Create(path, conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.Read()
+	if err := rec.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	rec.Close()
+	if err := rec.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := Open(path, WithStepping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if !p.Step() || p.ReadItem("tag").Value != 0.0 || p.Step() {
+		t.Error("file should contain one read")
+	}
+}
+
+//syncBuffer is a buffer which is written by the flushing of a recorder.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+//steps returns the number of calls which can be played from the buffer.
+func (b *syncBuffer) steps(t *testing.T) int {
+	b.mu.Lock()
+	recorded := append([]byte(nil), b.buf.Bytes()...)
+	b.mu.Unlock()
+	p, err := NewPlayer(bytes.NewReader(recorded), WithStepping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := 0
+	for p.Step() {
+		steps++
+	}
+	return steps
+}
+
+func TestFlush(t *testing.T) {
+	sim, _ := simulator.New([]simulator.Tag{{Name: "tag"}})
+	conn, _ := sim.Connect("tag")
+
+	//without a call to Flush or Stop the calls survive a crash
+	var buf syncBuffer
+	rec := NewRecorder(conn, &buf)
+	rec.Read()
+	rec.ReadItem("tag")
+	if steps := buf.steps(t); steps != 2 {
+		t.Errorf("recording should be flushed after every call, got %d calls", steps)
+	}
+
+	var long syncBuffer
+	rec = NewRecorder(conn, &long, WithFlushInterval(time.Hour))
+	rec.Read()
+	if steps := long.steps(t); steps != 0 {
+		t.Errorf("recording should be flushed every hour, got %d calls", steps)
+	}
+	rec.Flush()
+	if steps := long.steps(t); steps != 1 {
+		t.Errorf("flushed recording should contain the read, got %d calls", steps)
+	}
+	rec.Stop()
+
+	var short syncBuffer
+	rec = NewRecorder(conn, &short, WithFlushInterval(10*time.Millisecond))
+	defer rec.Stop()
+	rec.Read()
+	deadline := time.Now().Add(5 * time.Second)
+	for short.steps(t) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("recording should be flushed by the interval")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPlayerErrors(t *testing.T) {
+	if _, err := NewPlayer(bytes.NewBufferString("not gzip")); err == nil {
+		t.Error("garbage should not be played")
+	}
+	var buf bytes.Buffer
+	NewRecorder(nil, &buf).Stop()
+	if _, err := NewPlayer(bytes.NewReader(buf.Bytes()), WithSpeed(-1)); err == nil {
+		t.Error("negative speed should fail")
+	}
+	if _, err := Open("does-not-exist"); err == nil {
+		t.Error("missing file should fail")
+	}
+
+	//a recording cut off by a crash ends at its last complete call
+	recorded := record(t, &testClock{now: time.Unix(1000, 0)}).Bytes()
+	p, err := NewPlayer(bytes.NewReader(recorded[:len(recorded)*2/3]), WithStepping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := 0
+	for p.Step() {
+		steps++
+	}
+	if steps == 0 {
+		t.Error("cut off recording should be played up to the cut")
+	}
+}
+
+func TestConformance(t *testing.T) {
+	sim, err := simulator.New([]simulator.Tag{
+		{Name: "a", Waveform: simulator.Ramp{Rate: 1}},
+		{Name: "b", Waveform: simulator.Constant{Value: true}},
+		{Name: "c", Writable: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opcdatest.TestConnection(t, opcdatest.ConnectionConfig{
+		New: func() (opcda.Connection, error) {
+			conn, _ := sim.Connect()
+			return NewRecorder(conn, ioutil.Discard), nil
+		},
+		Tags:    []string{"a", "b"},
+		Missing: "missing",
+		Values:  map[string]interface{}{"c": "text"},
+	})
+}
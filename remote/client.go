@@ -0,0 +1,314 @@
+package remote
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/rxue92/opcda"
+)
+
+//Client is a connection and a browser served by an agent. It implements
+//opcda.Connection, opcda.Browser and opcda.Subscriber. The position of the
+//browser is kept by the client, so several clients can browse at once.
+//Errors of the agent are returned as *Error; the methods without error
+//result return zero values instead.
+type Client struct {
+	url    string
+	client *http.Client
+	token  string
+
+	mu     sync.Mutex
+	path   []string
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+//ClientOption configures a Client.
+type ClientOption func(*Client)
+
+//WithToken sends token to an agent which requires it, see RequireToken.
+//Use an "https" url so that the token is not sent in plain text.
+func WithToken(token string) ClientOption {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+//NewClient returns a client for the agent at url, e.g. "https://host:8080".
+//If client is nil, http.DefaultClient is used; for an agent with its own
+//certificate authority or client certificates, pass a client whose
+//transport has the tls.Config.
+func NewClient(url string, client *http.Client, opts ...ClientOption) *Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Client{url: strings.TrimSuffix(url, "/"), client: client, ctx: ctx, cancel: cancel}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+//do sends r to the agent with the token of the client.
+func (c *Client) do(ctx context.Context, r *http.Request) (*http.Response, error) {
+	if c.token != "" {
+		r.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return c.client.Do(r.WithContext(ctx))
+}
+
+//call posts req to the agent and decodes the response. The error of the
+//response is returned as error.
+func (c *Client) call(ctx context.Context, name string, req request) (response, error) {
+	if src, ok := opcda.ReadSourceFromContext(ctx); ok {
+		req.Source, req.MaxAge = src.Source, src.MaxAge
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return response{}, err
+	}
+	r, err := http.NewRequest(http.MethodPost, c.url+"/v1/"+name, bytes.NewReader(body))
+	if err != nil {
+		return response{}, err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	res, err := c.do(ctx, r)
+	if err != nil {
+		return response{}, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+		return response{}, fmt.Errorf("agent: %s: %s", res.Status, bytes.TrimSpace(msg))
+	}
+	var resp response
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return response{}, fmt.Errorf("cannot decode response of agent: %s", err)
+	}
+	return resp, resp.Error.Decode()
+}
+
+//Add adds the tags on the agent.
+func (c *Client) Add(tags ...string) error {
+	return c.AddContext(context.Background(), tags...)
+}
+
+//AddContext adds the tags on the agent.
+func (c *Client) AddContext(ctx context.Context, tags ...string) error {
+	_, err := c.call(ctx, "add", request{Tags: tags})
+	return err
+}
+
+//Remove removes the tag on the agent.
+func (c *Client) Remove(tag string) {
+	c.call(context.Background(), "remove", request{Tag: tag})
+}
+
+//Read returns the tags which were read successfully.
+func (c *Client) Read() map[string]opcda.Item {
+	results, err := c.ReadContext(context.Background())
+	if err != nil {
+		return map[string]opcda.Item{}
+	}
+	return opcda.Items(results)
+}
+
+//ReadContext reads all added tags.
+func (c *Client) ReadContext(ctx context.Context) (map[string]opcda.ReadResult, error) {
+	resp, err := c.call(ctx, "read", request{})
+	if err != nil {
+		return nil, err
+	}
+	results := make(map[string]opcda.ReadResult, len(resp.Results))
+	for tag, r := range resp.Results {
+		if r.Error != nil {
+			results[tag] = opcda.ReadResult{Err: r.Error.Decode()}
+			continue
+		}
+		i, err := r.Item.decode()
+		results[tag] = opcda.ReadResult{Item: i, Err: err}
+	}
+	return results, nil
+}
+
+//ReadItem reads the tag and returns an empty Item if it fails.
+func (c *Client) ReadItem(tag string) opcda.Item {
+	i, _ := c.ReadItemContext(context.Background(), tag)
+	return i
+}
+
+//ReadItemContext reads the tag.
+func (c *Client) ReadItemContext(ctx context.Context, tag string) (opcda.Item, error) {
+	resp, err := c.call(ctx, "readitem", request{Tag: tag})
+	if err != nil {
+		return opcda.Item{}, err
+	}
+	return resp.Item.decode()
+}
+
+//Tags returns the tags added on the agent.
+func (c *Client) Tags() []string {
+	resp, _ := c.call(context.Background(), "tags", request{})
+	if resp.Tags == nil {
+		return []string{}
+	}
+	return resp.Tags
+}
+
+//Write writes value to the tag.
+func (c *Client) Write(tag string, value interface{}) error {
+	return c.WriteContext(context.Background(), tag, value)
+}
+
+//WriteContext writes value to the tag. The value keeps its Go type, see Value.
+func (c *Client) WriteContext(ctx context.Context, tag string, value interface{}) error {
+	v, err := EncodeValue(value)
+	if err != nil {
+		return err
+	}
+	_, err = c.call(ctx, "write", request{Tag: tag, Value: &v})
+	return err
+}
+
+//IsConnected reports if the agent is reachable and connected to the server.
+func (c *Client) IsConnected() bool {
+	resp, err := c.call(context.Background(), "connected", request{})
+	return err == nil && resp.Connected
+}
+
+//Close ends the subscriptions of the client. The connection of the agent
+//stays open for other clients.
+func (c *Client) Close() {
+	c.cancel()
+	c.client.CloseIdleConnections()
+}
+
+//Subscribe streams the changes of the tags from the agent. The channel is
+//closed when the subscription is closed, the client is closed or the stream
+//breaks.
+func (c *Client) Subscribe(tags []string, opts opcda.SubscriptionOptions) (<-chan opcda.ItemChange, io.Closer) {
+	buffer := opts.Buffer
+	if buffer <= 0 {
+		buffer = 100
+	}
+	out := make(chan opcda.ItemChange, buffer)
+	ctx, cancel := context.WithCancel(c.ctx)
+	sub := &subscription{cancel: cancel, done: make(chan struct{})}
+
+	query := url.Values{"tag": tags}
+	if opts.UpdateRate > 0 {
+		query.Set("rate", opts.UpdateRate.String())
+	}
+	go func() {
+		defer close(sub.done)
+		defer close(out)
+		r, err := http.NewRequest(http.MethodGet, c.url+"/v1/subscribe?"+query.Encode(), nil)
+		if err != nil {
+			return
+		}
+		res, err := c.do(ctx, r)
+		if err != nil {
+			return
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return
+		}
+		scanner := bufio.NewScanner(res.Body)
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			var ch change
+			if json.Unmarshal(scanner.Bytes(), &ch) != nil {
+				continue
+			}
+			i, err := ch.Item.decode()
+			if err != nil {
+				continue
+			}
+			select {
+			case out <- opcda.ItemChange{Tag: ch.Tag, Item: i}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, sub
+}
+
+//subscription stops the stream of a subscription.
+type subscription struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+//Close stops the stream and waits until the channel is closed.
+func (s *subscription) Close() error {
+	s.cancel()
+	<-s.done
+	return nil
+}
+
+//browse asks the agent for the branches, leafs or position at the path of
+//the client.
+func (c *Client) browse(show string) response {
+	c.mu.Lock()
+	path := append([]string(nil), c.path...)
+	c.mu.Unlock()
+	resp, _ := c.call(context.Background(), "browse", request{Path: path, Show: show})
+	return resp
+}
+
+//MoveTo moves the browser to the absolute path of branches.
+func (c *Client) MoveTo(branches ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.path = append([]string(nil), branches...)
+}
+
+//MoveToRoot moves the browser to the root.
+func (c *Client) MoveToRoot() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.path = nil
+}
+
+//MoveUp moves the browser to the parent branch.
+func (c *Client) MoveUp() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.path) > 0 {
+		c.path = c.path[:len(c.path)-1]
+	}
+}
+
+//MoveDown moves the browser to the sub-branch.
+func (c *Client) MoveDown(branch string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.path = append(c.path, branch)
+}
+
+//Position returns the position of the browser as reported by the agent.
+func (c *Client) Position() string {
+	return c.browse("position").Position
+}
+
+//ShowBranches returns the sub-branches of the current position.
+func (c *Client) ShowBranches() []string {
+	return c.browse("branches").Branches
+}
+
+//ShowLeafs returns the items of the current position.
+func (c *Client) ShowLeafs() []opcda.Leaf {
+	return c.browse("leafs").Leaves
+}
@@ -0,0 +1,101 @@
+//Package remote serves an opcda.Connection and an opcda.Browser over HTTP
+//with JSON, and provides a Client which implements both interfaces on any
+//platform. A Windows machine with the OPC server runs the Server, e.g. with
+//cmd/opcda-agent, and Linux services use the Client like a local connection.
+//
+//Every call is a POST of a JSON request to /v1/<call> which is answered with
+//a JSON response. Subscriptions are streamed as one JSON object per line.
+//Values keep their Go type across the wire, see Value.
+//
+//The Server gives write access to the plant to everyone who reaches it. It
+//has no authentication of its own unless RequireToken is set, and the token
+//and values are only protected by TLS, e.g. with http.ListenAndServeTLS.
+//Use ReadOnly for clients which only read.
+package remote
+
+import (
+	"time"
+
+	"github.com/rxue92/opcda"
+	"github.com/rxue92/opcda/internal/wire"
+)
+
+//Value is the JSON form of an OPC value. Type is the Go type of the value,
+//e.g. "float32", so that the client gets the same type as the server.
+//Values of other types are sent as plain JSON without Type.
+type Value = wire.Value
+
+//EncodeValue returns the JSON form of v.
+func EncodeValue(v interface{}) (Value, error) {
+	return wire.EncodeValue(v)
+}
+
+//item is the JSON form of an opcda.Item.
+type item struct {
+	Value     Value         `json:"value"`
+	Quality   opcda.Quality `json:"quality"`
+	Timestamp time.Time     `json:"timestamp"`
+}
+
+func encodeItem(i opcda.Item) (*item, error) {
+	v, err := EncodeValue(i.Value)
+	if err != nil {
+		return nil, err
+	}
+	return &item{Value: v, Quality: i.Quality, Timestamp: i.Timestamp}, nil
+}
+
+func (i *item) decode() (opcda.Item, error) {
+	if i == nil {
+		return opcda.Item{}, nil
+	}
+	v, err := i.Value.Decode()
+	if err != nil {
+		return opcda.Item{}, err
+	}
+	return opcda.Item{Value: v, Quality: i.Quality, Timestamp: i.Timestamp}, nil
+}
+
+//result is the JSON form of an opcda.ReadResult.
+type result struct {
+	Item  *item       `json:"item,omitempty"`
+	Error *wire.Error `json:"error,omitempty"`
+}
+
+//change is a line of a subscription stream.
+type change struct {
+	Tag  string `json:"tag"`
+	Item *item  `json:"item"`
+}
+
+//request holds the arguments of all calls.
+type request struct {
+	Tags  []string `json:"tags,omitempty"`
+	Tag   string   `json:"tag,omitempty"`
+	Value *Value   `json:"value,omitempty"`
+	Path  []string `json:"path,omitempty"`
+	Show  string   `json:"show,omitempty"`
+	//Source and MaxAge are the read source of the context, if any.
+	Source int32         `json:"source,omitempty"`
+	MaxAge time.Duration `json:"maxAge,omitempty"`
+}
+
+//response holds the results of all calls.
+type response struct {
+	Error     *wire.Error       `json:"error,omitempty"`
+	Results   map[string]result `json:"results,omitempty"`
+	Item      *item             `json:"item,omitempty"`
+	Tags      []string          `json:"tags,omitempty"`
+	Connected bool              `json:"connected,omitempty"`
+	Branches  []string          `json:"branches,omitempty"`
+	Leaves    []opcda.Leaf      `json:"leaves,omitempty"`
+	Position  string            `json:"position,omitempty"`
+}
+
+//ErrReadOnly is returned by add, remove and write of a read-only Server.
+var ErrReadOnly = wire.ErrReadOnly
+
+//Error is an error returned by the agent. It matches the errors of opcda
+//with errors.Is, e.g. opcda.ErrNotConnected, and unwraps to the HRESULT of
+//the server if there is one.
+type Error = wire.DecodedError
@@ -0,0 +1,403 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rxue92/opcda"
+	"github.com/rxue92/opcda/opcdatest"
+	"github.com/rxue92/opcda/simulator"
+)
+
+//mockConn is a connection with a fixed set of known tags.
+type mockConn struct {
+	mu     sync.Mutex
+	values map[string]interface{}
+	added  map[string]bool
+	source opcda.ReadSource
+}
+
+func newMockConn() *mockConn {
+	return &mockConn{
+		values: map[string]interface{}{
+			"float": float32(1.5),
+			"int":   int64(math.MaxInt64),
+			"text":  "hello",
+			"nan":   math.NaN(),
+		},
+		added: make(map[string]bool),
+	}
+}
+
+func (m *mockConn) Add(tags ...string) error { return m.AddContext(context.Background(), tags...) }
+func (m *mockConn) AddContext(ctx context.Context, tags ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var errs opcda.MultiError
+	for _, tag := range tags {
+		if _, ok := m.values[tag]; !ok {
+			errs = append(errs, &opcda.AddItemError{Tag: tag, HRESULT: opcda.OPCErrUnknownItemID, Err: opcda.OPCErrUnknownItemID})
+			continue
+		}
+		m.added[tag] = true
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+func (m *mockConn) Remove(tag string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.added, tag)
+}
+func (m *mockConn) Read() map[string]opcda.Item {
+	results, _ := m.ReadContext(context.Background())
+	return opcda.Items(results)
+}
+func (m *mockConn) ReadContext(ctx context.Context) (map[string]opcda.ReadResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if src, ok := opcda.ReadSourceFromContext(ctx); ok {
+		m.source = src
+	}
+	results := make(map[string]opcda.ReadResult)
+	for tag := range m.added {
+		results[tag] = opcda.ReadResult{Item: opcda.Item{Value: m.values[tag], Quality: opcda.OPCQualityGood, Timestamp: time.Unix(1, 0)}}
+	}
+	return results, nil
+}
+func (m *mockConn) ReadItem(tag string) opcda.Item {
+	item, _ := m.ReadItemContext(context.Background(), tag)
+	return item
+}
+func (m *mockConn) ReadItemContext(ctx context.Context, tag string) (opcda.Item, error) {
+	results, _ := m.ReadContext(ctx)
+	result, ok := results[tag]
+	if !ok {
+		return opcda.Item{}, fmt.Errorf("%s: %w", tag, opcda.ErrTagNotFound)
+	}
+	return result.Item, nil
+}
+func (m *mockConn) Tags() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tags := []string{}
+	for tag := range m.added {
+		tags = append(tags, tag)
+	}
+	return tags
+}
+func (m *mockConn) Write(tag string, value interface{}) error {
+	return m.WriteContext(context.Background(), tag, value)
+}
+func (m *mockConn) WriteContext(ctx context.Context, tag string, value interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.added[tag] {
+		return fmt.Errorf("%s: %w", tag, opcda.ErrTagNotFound)
+	}
+	m.values[tag] = value
+	return nil
+}
+func (m *mockConn) Close()            {}
+func (m *mockConn) IsConnected() bool { return true }
+
+//newMockBrowser browses plant.line.speed.
+func newMockBrowser() opcda.Browser {
+	root := &opcda.Tree{Name: "root"}
+	plant := &opcda.Tree{Name: "plant", Parent: root}
+	line := &opcda.Tree{Name: "line", Parent: plant, Leaves: []opcda.Leaf{{Name: "speed", ItemId: "plant.line.speed"}}}
+	plant.Branches = []*opcda.Tree{line}
+	root.Branches = []*opcda.Tree{plant}
+	return opcda.NewTreeBrowser(root)
+}
+
+//newTestClient serves a mock connection and browser. The returned func
+//stops the agent.
+func newTestClient() (*Client, *mockConn, func()) {
+	conn := newMockConn()
+	ts := httptest.NewServer(NewServer(conn, newMockBrowser()))
+	client := NewClient(ts.URL, ts.Client())
+	return client, conn, func() {
+		client.Close()
+		ts.Close()
+	}
+}
+
+func TestClientConnection(t *testing.T) {
+	client, conn, stop := newTestClient()
+	defer stop()
+
+	if !client.IsConnected() {
+		t.Fatal("client should be connected")
+	}
+	if err := client.Add("float", "int", "text"); err != nil {
+		t.Fatal(err)
+	}
+	items := client.Read()
+	if len(items) != 3 || items["float"].Value != float32(1.5) || items["int"].Value != int64(math.MaxInt64) {
+		t.Fatalf("unexpected items %v", items)
+	}
+	if text := items["text"]; !text.Good() || !text.Timestamp.Equal(time.Unix(1, 0)) {
+		t.Errorf("quality and timestamp should be kept: %v", items["text"])
+	}
+
+	if err := client.Write("float", float32(2.5)); err != nil {
+		t.Fatal(err)
+	}
+	if v := client.ReadItem("float").Value; v != float32(2.5) {
+		t.Errorf("expected written value, got %#v", v)
+	}
+
+	ctx := opcda.WithReadSource(context.Background(), opcda.SourceMaxAge(time.Second))
+	if _, err := client.ReadContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if conn.source != opcda.SourceMaxAge(time.Second) {
+		t.Errorf("read source should be forwarded, got %v", conn.source)
+	}
+
+	client.Remove("text")
+	if tags := client.Tags(); len(tags) != 2 {
+		t.Errorf("expected 2 tags, got %v", tags)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	client, _, stop := newTestClient()
+	defer stop()
+
+	if _, err := client.ReadItemContext(context.Background(), "missing"); !errors.Is(err, opcda.ErrTagNotFound) {
+		t.Errorf("expected ErrTagNotFound, got %v", err)
+	}
+	if err := client.Write("missing", 1); !errors.Is(err, opcda.ErrTagNotFound) {
+		t.Errorf("expected ErrTagNotFound, got %v", err)
+	}
+
+	err := client.Add("float", "unknown")
+	var addErr *opcda.AddItemError
+	if !errors.As(err, &addErr) || addErr.Tag != "unknown" || !errors.Is(err, opcda.OPCErrUnknownItemID) {
+		t.Errorf("expected AddItemError for unknown, got %#v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.ReadContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected canceled read, got %v", err)
+	}
+
+	down := NewClient("http://127.0.0.1:1", nil)
+	defer down.Close()
+	if down.IsConnected() || len(down.Read()) != 0 {
+		t.Error("unreachable agent should not be connected")
+	}
+}
+
+func TestClientBrowser(t *testing.T) {
+	client, _, stop := newTestClient()
+	defer stop()
+
+	if b := client.ShowBranches(); !reflect.DeepEqual(b, []string{"plant"}) {
+		t.Errorf("unexpected root branches %v", b)
+	}
+	client.MoveDown("plant")
+	client.MoveDown("line")
+	if pos := client.Position(); pos != "plant.line" {
+		t.Errorf("unexpected position %q", pos)
+	}
+	if leafs := client.ShowLeafs(); len(leafs) != 1 || leafs[0].ItemId != "plant.line.speed" {
+		t.Errorf("unexpected leafs %v", leafs)
+	}
+	client.MoveUp()
+	if pos := client.Position(); pos != "plant" {
+		t.Errorf("unexpected position %q", pos)
+	}
+	client.MoveTo("plant", "line")
+	client.MoveToRoot()
+	if pos := client.Position(); pos != "" {
+		t.Errorf("expected root, got %q", pos)
+	}
+
+	conn := newMockConn()
+	ts := httptest.NewServer(NewServer(conn, nil))
+	defer ts.Close()
+	if NewClient(ts.URL, ts.Client()).ShowBranches() != nil {
+		t.Error("agent without browser should show nothing")
+	}
+}
+
+func TestServerReadOnly(t *testing.T) {
+	conn := newMockConn()
+	conn.Add("float")
+	ts := httptest.NewServer(NewServer(conn, nil, ReadOnly()))
+	defer ts.Close()
+	client := NewClient(ts.URL, ts.Client())
+	defer client.Close()
+
+	if err := client.Write("float", float32(2.5)); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly on write, got %v", err)
+	}
+	if err := client.Add("int"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly on add, got %v", err)
+	}
+	client.Remove("float")
+	if v := client.ReadItem("float").Value; v != float32(1.5) {
+		t.Errorf("read-only agent should read the unchanged value, got %#v", v)
+	}
+
+	changes, _ := client.Subscribe([]string{"float", "int"}, opcda.SubscriptionOptions{})
+	for range changes {
+		t.Error("subscription to a tag which is not added should be refused")
+	}
+	if tags := conn.Tags(); len(tags) != 1 {
+		t.Errorf("subscription should not add tags to a read-only agent, got %v", tags)
+	}
+	res, err := ts.Client().Get(ts.URL + "/v1/subscribe?tag=int")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resp response
+	json.NewDecoder(res.Body).Decode(&resp)
+	res.Body.Close()
+	if res.StatusCode != http.StatusForbidden || !errors.Is(resp.Error.Decode(), ErrReadOnly) {
+		t.Errorf("expected 403 with ErrReadOnly, got %s %v", res.Status, resp.Error)
+	}
+	changes, sub := client.Subscribe([]string{"float"}, opcda.SubscriptionOptions{UpdateRate: 10 * time.Millisecond})
+	select {
+	case c := <-changes:
+		if c.Value != float32(1.5) {
+			t.Errorf("unexpected change %v", c)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("subscription to an added tag should work")
+	}
+	sub.Close()
+}
+
+func TestServerRequestSize(t *testing.T) {
+	ts := httptest.NewServer(NewServer(newMockConn(), nil))
+	defer ts.Close()
+	body := `{"tags": ["` + strings.Repeat("a", maxRequestSize) + `"]}`
+	res, err := ts.Client().Post(ts.URL+"/v1/add", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for a request over the limit, got %s", res.Status)
+	}
+}
+
+func TestServerToken(t *testing.T) {
+	conn := newMockConn()
+	conn.Add("float")
+	ts := httptest.NewServer(NewServer(conn, nil, RequireToken("secret")))
+	defer ts.Close()
+
+	for _, c := range []*Client{NewClient(ts.URL, ts.Client()), NewClient(ts.URL, ts.Client(), WithToken("wrong"))} {
+		if _, err := c.ReadItemContext(context.Background(), "float"); err == nil || !strings.Contains(err.Error(), "401") {
+			t.Errorf("expected 401 without the token, got %v", err)
+		}
+		if err := c.Write("float", float32(2.5)); err == nil {
+			t.Error("write without the token should fail")
+		}
+		changes, _ := c.Subscribe([]string{"float"}, opcda.SubscriptionOptions{})
+		for range changes {
+			t.Error("subscription without the token should be closed")
+		}
+		c.Close()
+	}
+
+	client := NewClient(ts.URL, ts.Client(), WithToken("secret"))
+	defer client.Close()
+	if err := client.Write("float", float32(2.5)); err != nil {
+		t.Fatal(err)
+	}
+	changes, sub := client.Subscribe([]string{"float"}, opcda.SubscriptionOptions{UpdateRate: 10 * time.Millisecond})
+	defer sub.Close()
+	select {
+	case c := <-changes:
+		if c.Value != float32(2.5) {
+			t.Errorf("unexpected change %v", c)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change received with the token")
+	}
+}
+
+func TestClientSubscribe(t *testing.T) {
+	client, conn, stop := newTestClient()
+	defer stop()
+
+	changes, sub := client.Subscribe([]string{"float"}, opcda.SubscriptionOptions{UpdateRate: 10 * time.Millisecond})
+	next := func() opcda.ItemChange {
+		select {
+		case c := <-changes:
+			return c
+		case <-time.After(5 * time.Second):
+			t.Fatal("no change received")
+		}
+		return opcda.ItemChange{}
+	}
+	if c := next(); c.Tag != "float" || c.Value != float32(1.5) {
+		t.Fatalf("unexpected first change %v", c)
+	}
+	conn.Write("float", float32(3))
+	if c := next(); c.Value != float32(3) {
+		t.Fatalf("unexpected change %v", c)
+	}
+	sub.Close()
+	for range changes {
+	}
+
+	changes, _ = client.Subscribe([]string{"float"}, opcda.SubscriptionOptions{})
+	next()
+	client.Close()
+	for range changes {
+	}
+}
+
+//agentClient closes its agent with the client.
+type agentClient struct {
+	*Client
+	agent *httptest.Server
+}
+
+func (c *agentClient) Close() {
+	c.Client.Close()
+	c.agent.Close()
+}
+
+func TestConformance(t *testing.T) {
+	sim, err := simulator.New([]simulator.Tag{
+		{Name: "numeric.sin", Waveform: simulator.Sine{Amplitude: 1, Period: time.Minute}},
+		{Name: "numeric.setpoint", Writable: true},
+		{Name: "textual.mode", Waveform: simulator.Constant{Value: "auto"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve := func() *agentClient {
+		conn, _ := sim.Connect()
+		agent := httptest.NewServer(NewServer(conn, sim.Browser()))
+		return &agentClient{NewClient(agent.URL, agent.Client()), agent}
+	}
+	opcdatest.TestConnection(t, opcdatest.ConnectionConfig{
+		New:     func() (opcda.Connection, error) { return serve(), nil },
+		Tags:    []string{"numeric.sin", "textual.mode"},
+		Missing: "numeric.missing",
+		Values:  map[string]interface{}{"numeric.setpoint": int64(-7)},
+	})
+	opcdatest.TestBrowser(t, func() (opcda.Browser, error) { return serve(), nil })
+}
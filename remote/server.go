@@ -0,0 +1,278 @@
+package remote
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rxue92/opcda"
+	"github.com/rxue92/opcda/internal/wire"
+)
+
+//maxRequestSize limits the size of the body of a call.
+const maxRequestSize = 1 << 20
+
+//Server serves a connection and a browser over HTTP. The browser is
+//optional; without it the browse calls fail. The position of the browser is
+//kept by the clients, so the browse calls are serialized by the server.
+//
+//Without options anyone who reaches the Server can write to the OPC server.
+//Serve it on a loopback address, or require a token with RequireToken and
+//serve it with TLS, and use ReadOnly if the clients only read.
+type Server struct {
+	conn     opcda.Connection
+	browser  opcda.Browser
+	token    string
+	readOnly bool
+	mu       sync.Mutex
+	mux      *http.ServeMux
+}
+
+//ServerOption configures a Server.
+type ServerOption func(*Server)
+
+//RequireToken makes the Server answer only requests with the header
+//"Authorization: Bearer <token>", see WithToken. Other requests get
+//401 Unauthorized. The token is sent in plain text unless the Server is
+//served with TLS.
+func RequireToken(token string) ServerOption {
+	return func(s *Server) {
+		s.token = token
+	}
+}
+
+//ReadOnly makes the Server refuse add, remove and write with ErrReadOnly.
+//Reads, subscriptions and browsing still work on the tags of the connection;
+//subscriptions to other tags are refused, since they would add them.
+func ReadOnly() ServerOption {
+	return func(s *Server) {
+		s.readOnly = true
+	}
+}
+
+//NewServer returns a Server for conn and browser, which may be nil.
+//The Server does not close them.
+func NewServer(conn opcda.Connection, browser opcda.Browser, opts ...ServerOption) *Server {
+	s := &Server{conn: conn, browser: browser, mux: http.NewServeMux()}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.handle("add", s.add)
+	s.handle("remove", s.remove)
+	s.handle("read", s.read)
+	s.handle("readitem", s.readItem)
+	s.handle("tags", s.tags)
+	s.handle("write", s.write)
+	s.handle("connected", s.connected)
+	s.handle("browse", s.browse)
+	s.mux.HandleFunc("/v1/subscribe", s.subscribe)
+	return s
+}
+
+//ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+//authorized reports whether r has the token of the Server, if it has one.
+func (s *Server) authorized(r *http.Request) bool {
+	if s.token == "" {
+		return true
+	}
+	got := []byte(r.Header.Get("Authorization"))
+	return subtle.ConstantTimeCompare(got, []byte("Bearer "+s.token)) == 1
+}
+
+//handle registers a call which decodes a request and encodes the response.
+func (s *Server) handle(call string, f func(context.Context, request) response) {
+	s.mux.HandleFunc("/v1/"+call, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req request
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "cannot decode request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		ctx := r.Context()
+		if req.Source != 0 {
+			ctx = opcda.WithReadSource(ctx, opcda.ReadSource{Source: req.Source, MaxAge: req.MaxAge})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(f(ctx, req))
+	})
+}
+
+func (s *Server) add(ctx context.Context, req request) response {
+	if s.readOnly {
+		return response{Error: wire.EncodeError(ErrReadOnly)}
+	}
+	return response{Error: wire.EncodeError(s.conn.AddContext(ctx, req.Tags...))}
+}
+
+func (s *Server) remove(ctx context.Context, req request) response {
+	if s.readOnly {
+		return response{Error: wire.EncodeError(ErrReadOnly)}
+	}
+	s.conn.Remove(req.Tag)
+	return response{}
+}
+
+func (s *Server) read(ctx context.Context, req request) response {
+	results, err := s.conn.ReadContext(ctx)
+	if err != nil {
+		return response{Error: wire.EncodeError(err)}
+	}
+	resp := response{Results: make(map[string]result, len(results))}
+	for tag, r := range results {
+		if r.Err != nil {
+			resp.Results[tag] = result{Error: wire.EncodeError(r.Err)}
+			continue
+		}
+		i, err := encodeItem(r.Item)
+		if err != nil {
+			resp.Results[tag] = result{Error: wire.EncodeError(err)}
+			continue
+		}
+		resp.Results[tag] = result{Item: i}
+	}
+	return resp
+}
+
+func (s *Server) readItem(ctx context.Context, req request) response {
+	it, err := s.conn.ReadItemContext(ctx, req.Tag)
+	if err != nil {
+		return response{Error: wire.EncodeError(err)}
+	}
+	i, err := encodeItem(it)
+	return response{Item: i, Error: wire.EncodeError(err)}
+}
+
+func (s *Server) tags(ctx context.Context, req request) response {
+	return response{Tags: s.conn.Tags()}
+}
+
+func (s *Server) write(ctx context.Context, req request) response {
+	if s.readOnly {
+		return response{Error: wire.EncodeError(ErrReadOnly)}
+	}
+	if req.Value == nil {
+		return response{Error: wire.EncodeError(errors.New("no value to write"))}
+	}
+	value, err := req.Value.Decode()
+	if err != nil {
+		return response{Error: wire.EncodeError(err)}
+	}
+	return response{Error: wire.EncodeError(s.conn.WriteContext(ctx, req.Tag, value))}
+}
+
+func (s *Server) connected(ctx context.Context, req request) response {
+	return response{Connected: s.conn.IsConnected()}
+}
+
+//browse moves the browser to the path of the request and shows the
+//branches, the leafs or the position there.
+func (s *Server) browse(ctx context.Context, req request) response {
+	if s.browser == nil {
+		return response{Error: &wire.Error{Message: "agent has no browser", Code: wire.CodeUnsupported}}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(req.Path) == 0 {
+		s.browser.MoveToRoot()
+	} else {
+		s.browser.MoveTo(req.Path...)
+	}
+	switch req.Show {
+	case "branches":
+		return response{Branches: s.browser.ShowBranches()}
+	case "leafs":
+		return response{Leaves: s.browser.ShowLeafs()}
+	case "position":
+		return response{Position: s.browser.Position()}
+	}
+	return response{Error: &wire.Error{Message: "unknown browse request " + req.Show}}
+}
+
+//subscribe streams the changes of the tags as lines of JSON until the client
+//goes away. The tags are the tag parameters of the URL and rate is the update
+//rate, e.g. "500ms". A read-only Server answers 403 Forbidden with
+//ErrReadOnly if a tag is not added to the connection.
+func (s *Server) subscribe(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	var opts opcda.SubscriptionOptions
+	if rate := r.URL.Query().Get("rate"); rate != "" {
+		d, err := time.ParseDuration(rate)
+		if err != nil {
+			http.Error(w, "invalid rate: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		opts.UpdateRate = d
+	}
+	tags := r.URL.Query()["tag"]
+	if s.readOnly {
+		if err := s.added(tags); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(response{Error: wire.EncodeError(err)})
+			return
+		}
+	}
+	changes, sub := opcda.Subscribe(s.conn, tags, opts)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	enc := json.NewEncoder(w)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case c, ok := <-changes:
+			if !ok {
+				return
+			}
+			i, err := encodeItem(c.Item)
+			if err != nil {
+				continue
+			}
+			if enc.Encode(change{Tag: c.Tag, Item: i}) != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+//added returns ErrReadOnly for the first of the tags which is not added to
+//the connection.
+func (s *Server) added(tags []string) error {
+	added := make(map[string]bool)
+	for _, tag := range s.conn.Tags() {
+		added[tag] = true
+	}
+	for _, tag := range tags {
+		if !added[tag] {
+			return fmt.Errorf("cannot subscribe to %s: %w", tag, ErrReadOnly)
+		}
+	}
+	return nil
+}
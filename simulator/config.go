@@ -0,0 +1,161 @@
+package simulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/rxue92/opcda"
+)
+
+//Load returns a simulator with the tags of a JSON configuration:
+//
+//	{"tags": [
+//		{"name": "plant.line.speed", "waveform": {"type": "sine", "amplitude": 5, "offset": 50, "period": "1m"},
+//		 "faults": [{"after": "10s", "for": "2s", "every": "30s", "quality": 24}]},
+//		{"name": "plant.line.setpoint", "waveform": {"type": "constant", "value": 50}, "writable": true},
+//		{"name": "plant.line.mode", "waveform": {"type": "schedule", "period": "1h",
+//		 "steps": [{"at": "0s", "value": "auto"}, {"at": "50m", "value": "manual"}]}}
+//	]}
+//
+//The waveform types are constant (value), sine (amplitude, offset, period,
+//phase), saw (min, max, period), square (low, high, period, duty), ramp
+//(start, rate), walk (start, step, min, max, interval, seed) and schedule
+//(steps, period). Durations are strings like "1m30s", qualities are numbers.
+func Load(r io.Reader, opts ...Option) (*Simulator, error) {
+	var cfg config
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("cannot decode simulator configuration: %s", err)
+	}
+	tags := make([]Tag, len(cfg.Tags))
+	for i, t := range cfg.Tags {
+		tag, err := t.tag()
+		if err != nil {
+			return nil, err
+		}
+		tags[i] = tag
+	}
+	return New(tags, opts...)
+}
+
+//LoadFile returns a simulator with the tags of a JSON file, see Load.
+func LoadFile(path string, opts ...Option) (*Simulator, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Load(f, opts...)
+}
+
+type config struct {
+	Tags []tagConfig `json:"tags"`
+}
+
+type tagConfig struct {
+	Name     string          `json:"name"`
+	Waveform *waveformConfig `json:"waveform"`
+	Writable bool            `json:"writable"`
+	Faults   []faultConfig   `json:"faults"`
+}
+
+//tag returns the Tag of the configuration.
+func (c tagConfig) tag() (Tag, error) {
+	t := Tag{Name: c.Name, Writable: c.Writable}
+	if c.Waveform != nil {
+		w, err := c.Waveform.waveform()
+		if err != nil {
+			return Tag{}, fmt.Errorf("tag %s: %s", c.Name, err)
+		}
+		t.Waveform = w
+	}
+	for _, f := range c.Faults {
+		t.Faults = append(t.Faults, Fault{
+			After:       time.Duration(f.After),
+			For:         time.Duration(f.For),
+			Every:       time.Duration(f.Every),
+			Quality:     f.Quality,
+			Probability: f.Probability,
+		})
+	}
+	return t, nil
+}
+
+type faultConfig struct {
+	After       duration      `json:"after"`
+	For         duration      `json:"for"`
+	Every       duration      `json:"every"`
+	Quality     opcda.Quality `json:"quality"`
+	Probability float64       `json:"probability"`
+}
+
+type waveformConfig struct {
+	Type      string       `json:"type"`
+	Value     interface{}  `json:"value"`
+	Amplitude float64      `json:"amplitude"`
+	Offset    float64      `json:"offset"`
+	Period    duration     `json:"period"`
+	Phase     float64      `json:"phase"`
+	Min       float64      `json:"min"`
+	Max       float64      `json:"max"`
+	Low       float64      `json:"low"`
+	High      float64      `json:"high"`
+	Duty      float64      `json:"duty"`
+	Start     float64      `json:"start"`
+	Rate      float64      `json:"rate"`
+	Step      float64      `json:"step"`
+	Interval  duration     `json:"interval"`
+	Seed      int64        `json:"seed"`
+	Steps     []stepConfig `json:"steps"`
+}
+
+type stepConfig struct {
+	At    duration    `json:"at"`
+	Value interface{} `json:"value"`
+}
+
+//waveform returns the Waveform of the configuration.
+func (c waveformConfig) waveform() (Waveform, error) {
+	period := time.Duration(c.Period)
+	switch c.Type {
+	case "constant":
+		return Constant{Value: c.Value}, nil
+	case "sine":
+		return Sine{Amplitude: c.Amplitude, Offset: c.Offset, Period: period, Phase: c.Phase}, nil
+	case "saw":
+		return Saw{Min: c.Min, Max: c.Max, Period: period}, nil
+	case "square":
+		return Square{Low: c.Low, High: c.High, Period: period, Duty: c.Duty}, nil
+	case "ramp":
+		return Ramp{Start: c.Start, Rate: c.Rate}, nil
+	case "walk":
+		return &RandomWalk{Start: c.Start, Step: c.Step, Min: c.Min, Max: c.Max, Interval: time.Duration(c.Interval), Seed: c.Seed}, nil
+	case "schedule":
+		s := Schedule{Period: period}
+		for _, step := range c.Steps {
+			s.Steps = append(s.Steps, Step{At: time.Duration(step.At), Value: step.Value})
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("unknown waveform type %q", c.Type)
+}
+
+//duration is a time.Duration written as a string like "1m30s" in JSON.
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"1m30s\": %s", b)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
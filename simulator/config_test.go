@@ -0,0 +1,71 @@
+package simulator
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rxue92/opcda"
+)
+
+const testConfig = `{"tags": [
+	{"name": "plant.speed", "waveform": {"type": "sine", "amplitude": 5, "offset": 50, "period": "1m"},
+	 "faults": [{"after": "10s", "for": "2s", "every": "30s", "quality": 24}]},
+	{"name": "plant.setpoint", "waveform": {"type": "constant", "value": 50}, "writable": true},
+	{"name": "plant.level", "waveform": {"type": "saw", "min": 0, "max": 100, "period": "10s"}},
+	{"name": "plant.pump", "waveform": {"type": "square", "low": 0, "high": 1, "period": "4s", "duty": 0.25}},
+	{"name": "plant.counter", "waveform": {"type": "ramp", "start": 1, "rate": 2}},
+	{"name": "plant.temperature", "waveform": {"type": "walk", "start": 20, "step": 1, "interval": "1s", "seed": 3}},
+	{"name": "plant.mode", "waveform": {"type": "schedule", "period": "1h",
+	 "steps": [{"at": "0s", "value": "auto"}, {"at": "30m", "value": "manual"}]}}
+]}`
+
+func TestLoad(t *testing.T) {
+	clock := &testClock{now: time.Unix(0, 0)}
+	sim, err := Load(strings.NewReader(testConfig), WithClock(clock.Now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := sim.Connect(sim.Tags()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.now = clock.now.Add(45 * time.Minute)
+	items := conn.Read()
+	if len(items) != 7 {
+		t.Fatalf("expected 7 tags, got %v", items)
+	}
+	if v := items["plant.mode"].Value; v != "manual" {
+		t.Errorf("expected manual, got %v", v)
+	}
+	if v := items["plant.counter"].Value; v != 5401.0 {
+		t.Errorf("expected 5401, got %v", v)
+	}
+	if q := items["plant.speed"].Quality; q != opcda.OPCQualityGood {
+		t.Errorf("expected good quality, got %s", q)
+	}
+	if err := conn.Write("plant.setpoint", 60.0); err != nil {
+		t.Errorf("setpoint should be writable: %s", err)
+	}
+	if err := conn.Write("plant.speed", 60.0); err == nil {
+		t.Error("speed should not be writable")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, cfg := range []string{
+		`{"tags": [{"name": "a", "waveform": {"type": "noise"}}]}`,
+		`{"tags": [{"name": "a", "waveform": {"type": "sine", "period": 10}}]}`,
+		`{"tags": [{"name": "a", "waveform": {"type": "sine", "period": "1 minute"}}]}`,
+		`{"tags": [{"name": "a", "waveform": {"type": "sine"}}]}`,
+		`{"tags": [{"name": "a", "color": "red"}]}`,
+		`{"tags": [`,
+	} {
+		if _, err := Load(strings.NewReader(cfg)); err == nil {
+			t.Errorf("%s should be invalid", cfg)
+		}
+	}
+	if _, err := LoadFile("does-not-exist.json"); err == nil {
+		t.Error("missing file should fail")
+	}
+}
@@ -0,0 +1,179 @@
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/rxue92/opcda"
+)
+
+//Connection is a connection to a Simulator. It implements opcda.Connection.
+//Connections of the same simulator see the values written by each other.
+type Connection struct {
+	sim    *Simulator
+	mu     sync.Mutex
+	tags   map[string]bool //added tags, true if added by a write only
+	closed bool
+}
+
+//Connect returns a connection with the tags added, like opcda.NewConnection.
+//Tags which do not exist are reported as *opcda.AddItemError while the
+//others are added.
+func (s *Simulator) Connect(tags ...string) (*Connection, error) {
+	conn := &Connection{sim: s, tags: make(map[string]bool)}
+	return conn, conn.Add(tags...)
+}
+
+//available returns the error of calls on a closed connection.
+//It must be called with the lock held.
+func (c *Connection) available() error {
+	if c.closed {
+		return opcda.ErrNotConnected
+	}
+	return nil
+}
+
+//Add adds the tags.
+func (c *Connection) Add(tags ...string) error {
+	return c.AddContext(context.Background(), tags...)
+}
+
+//AddContext adds the tags.
+func (c *Connection) AddContext(ctx context.Context, tags ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.available(); err != nil {
+		return err
+	}
+	added, err := c.sim.add(tags)
+	for _, tag := range added {
+		c.tags[tag] = false
+	}
+	return err
+}
+
+//Remove removes the tag.
+func (c *Connection) Remove(tag string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.tags, tag)
+}
+
+//Read returns the added tags which were read successfully.
+func (c *Connection) Read() map[string]opcda.Item {
+	results, err := c.ReadContext(context.Background())
+	if err != nil {
+		return map[string]opcda.Item{}
+	}
+	return opcda.Items(results)
+}
+
+//ReadContext reads all added tags except those added by a write.
+func (c *Connection) ReadContext(ctx context.Context) (map[string]opcda.ReadResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.available(); err != nil {
+		return nil, err
+	}
+	c.sim.mu.Lock()
+	defer c.sim.mu.Unlock()
+	if !c.sim.connected {
+		return nil, opcda.ErrNotConnected
+	}
+	results := make(map[string]opcda.ReadResult, len(c.tags))
+	for tag, writeOnly := range c.tags {
+		if writeOnly {
+			continue
+		}
+		item, err := c.sim.read(tag)
+		results[tag] = opcda.ReadResult{Item: item, Err: err}
+	}
+	return results, nil
+}
+
+//ReadItem reads the tag and returns an empty Item if it fails.
+func (c *Connection) ReadItem(tag string) opcda.Item {
+	item, _ := c.ReadItemContext(context.Background(), tag)
+	return item
+}
+
+//ReadItemContext reads an added tag.
+func (c *Connection) ReadItemContext(ctx context.Context, tag string) (opcda.Item, error) {
+	if err := ctx.Err(); err != nil {
+		return opcda.Item{}, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.available(); err != nil {
+		return opcda.Item{}, err
+	}
+	if _, ok := c.tags[tag]; !ok {
+		return opcda.Item{}, fmt.Errorf("%s: %w", tag, opcda.ErrTagNotFound)
+	}
+	c.sim.mu.Lock()
+	defer c.sim.mu.Unlock()
+	return c.sim.read(tag)
+}
+
+//Tags returns the added tags in alphabetical order.
+func (c *Connection) Tags() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tags := make([]string, 0, len(c.tags))
+	for tag := range c.tags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+//Write writes value to the tag.
+func (c *Connection) Write(tag string, value interface{}) error {
+	return c.WriteContext(context.Background(), tag, value)
+}
+
+//WriteContext writes value to the tag. The tag keeps the value until it is
+//written again. Like the connection of opcda, a tag which is not added is
+//added first as write-only: it is not read by Read and ReadContext until it
+//is added with Add.
+func (c *Connection) WriteContext(ctx context.Context, tag string, value interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.available(); err != nil {
+		return err
+	}
+	if _, ok := c.tags[tag]; !ok {
+		if _, err := c.sim.add([]string{tag}); err != nil {
+			return err
+		}
+		c.tags[tag] = true
+	}
+	return c.sim.write(tag, value)
+}
+
+//Close closes the connection. The simulator keeps running.
+func (c *Connection) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	c.tags = make(map[string]bool)
+}
+
+//IsConnected reports if the connection is open and the simulator connected.
+func (c *Connection) IsConnected() bool {
+	c.mu.Lock()
+	closed := c.closed
+	c.mu.Unlock()
+	return !closed && c.sim.IsConnected()
+}
@@ -0,0 +1,49 @@
+package simulator
+
+import (
+	"errors"
+	"time"
+
+	"github.com/rxue92/opcda"
+)
+
+//Fault overrides the quality of a tag while it is active. It starts After the
+//start of the simulator and lasts For, or forever if For is zero. If Every is
+//positive the fault repeats in every period. With a Probability between 0
+//and 1 an active fault only hits that fraction of the reads.
+type Fault struct {
+	After       time.Duration
+	For         time.Duration
+	Every       time.Duration
+	Quality     opcda.Quality
+	Probability float64
+}
+
+//active reports if the fault applies to a read at elapsed. random returns a
+//number in [0, 1).
+func (f Fault) active(elapsed time.Duration, random func() float64) bool {
+	if elapsed < f.After {
+		return false
+	}
+	since := elapsed - f.After
+	if f.Every > 0 {
+		since %= f.Every
+	}
+	if f.For > 0 && since >= f.For {
+		return false
+	}
+	return f.Probability == 0 || random() < f.Probability
+}
+
+func (f Fault) validate() error {
+	if f.After < 0 || f.For < 0 || f.Every < 0 {
+		return errors.New("fault durations must not be negative")
+	}
+	if f.Every > 0 && (f.For == 0 || f.For > f.Every) {
+		return errors.New("repeated fault must last between zero and its period")
+	}
+	if f.Probability < 0 || f.Probability > 1 {
+		return errors.New("fault probability must be between 0 and 1")
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+//Package simulator provides an OPC server simulation for tests and demos. A
+//Simulator holds a namespace of tags whose values follow waveforms, with
+//faults of their quality. Its connections implement opcda.Connection and its
+//browsers opcda.Browser, so code written against a real OPC server runs
+//against the simulator without changes.
+//
+//	sim, err := simulator.New([]simulator.Tag{
+//		{Name: "plant.line.speed", Waveform: simulator.Sine{Amplitude: 5, Offset: 50, Period: time.Minute}},
+//		{Name: "plant.line.setpoint", Waveform: simulator.Constant{Value: 50.0}, Writable: true},
+//	})
+//	conn, err := sim.Connect("plant.line.speed", "plant.line.setpoint")
+//
+//The tags can also be loaded from a JSON file, see Load.
+package simulator
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rxue92/opcda"
+)
+
+//Tag is a simulated OPC item. Its name is the item ID; the dots of the name
+//form the branches of the namespace. A tag without a Waveform holds the
+//value 0.0 until it is written.
+type Tag struct {
+	Name     string
+	Waveform Waveform
+	//Writable tags accept writes and keep the written value instead of
+	//their waveform. Writes to other tags fail with opcda.OPCErrBadRights.
+	Writable bool
+	//Faults are checked in order; the first active fault sets the quality.
+	Faults []Fault
+}
+
+func (t Tag) validate() error {
+	if t.Name == "" || strings.HasPrefix(t.Name, ".") || strings.HasSuffix(t.Name, ".") || strings.Contains(t.Name, "..") {
+		return fmt.Errorf("invalid tag name %q", t.Name)
+	}
+	if v, ok := t.Waveform.(validator); ok {
+		if err := v.validate(); err != nil {
+			return fmt.Errorf("tag %s: %s", t.Name, err)
+		}
+	}
+	for _, f := range t.Faults {
+		if err := f.validate(); err != nil {
+			return fmt.Errorf("tag %s: %s", t.Name, err)
+		}
+	}
+	return nil
+}
+
+//tag is a Tag with its state.
+type tag struct {
+	Tag
+	written   bool
+	value     interface{}
+	timestamp time.Time
+	quality   *opcda.Quality
+}
+
+//Option configures a Simulator.
+type Option func(*Simulator)
+
+//WithClock makes the simulator take the time from now instead of time.Now,
+//e.g. to step through the waveforms in tests.
+func WithClock(now func() time.Time) Option {
+	return func(s *Simulator) {
+		s.now = now
+	}
+}
+
+//WithSeed seeds the random numbers of the faults with a probability.
+func WithSeed(seed int64) Option {
+	return func(s *Simulator) {
+		s.rand = rand.New(rand.NewSource(seed))
+	}
+}
+
+//Simulator is a simulated OPC server. It is safe for concurrent use.
+type Simulator struct {
+	mu        sync.Mutex
+	now       func() time.Time
+	start     time.Time
+	rand      *rand.Rand
+	tags      map[string]*tag
+	names     []string
+	connected bool
+}
+
+//New returns a simulator with the tags. The waveforms start now.
+func New(tags []Tag, opts ...Option) (*Simulator, error) {
+	s := &Simulator{
+		now:       time.Now,
+		rand:      rand.New(rand.NewSource(time.Now().UnixNano())),
+		tags:      make(map[string]*tag),
+		connected: true,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+	for _, t := range tags {
+		if err := t.validate(); err != nil {
+			return nil, err
+		}
+		if _, ok := s.tags[t.Name]; ok {
+			return nil, fmt.Errorf("duplicate tag %s", t.Name)
+		}
+		s.tags[t.Name] = &tag{Tag: t}
+		s.names = append(s.names, t.Name)
+	}
+	sort.Strings(s.names)
+	s.start = s.now()
+	return s, nil
+}
+
+//Tags returns the names of all tags in alphabetical order.
+func (s *Simulator) Tags() []string {
+	return append([]string{}, s.names...)
+}
+
+//Tree returns the namespace of the simulator like opcda.CreateBrowser.
+func (s *Simulator) Tree() *opcda.Tree {
+	root := &opcda.Tree{Name: "root", Branches: []*opcda.Tree{}, Leaves: []opcda.Leaf{}}
+	for _, name := range s.names {
+		parts := strings.Split(name, ".")
+		branch := root
+		for _, part := range parts[:len(parts)-1] {
+			branch = subtree(branch, part)
+		}
+		branch.Leaves = append(branch.Leaves, opcda.Leaf{Name: parts[len(parts)-1], ItemId: name})
+	}
+	return root
+}
+
+//subtree returns the branch of tree with name and adds it if necessary.
+func subtree(tree *opcda.Tree, name string) *opcda.Tree {
+	for _, b := range tree.Branches {
+		if b.Name == name {
+			return b
+		}
+	}
+	b := &opcda.Tree{Name: name, Parent: tree, Branches: []*opcda.Tree{}, Leaves: []opcda.Leaf{}}
+	tree.Branches = append(tree.Branches, b)
+	return b
+}
+
+//SetQuality overrides the quality of tag, including its faults, until
+//ClearQuality is called.
+func (s *Simulator) SetQuality(name string, q opcda.Quality) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tags[name]
+	if !ok {
+		return fmt.Errorf("%s: %w", name, opcda.ErrTagNotFound)
+	}
+	t.quality = &q
+	return nil
+}
+
+//ClearQuality removes the quality set with SetQuality.
+func (s *Simulator) ClearQuality(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.tags[name]; ok {
+		t.quality = nil
+	}
+}
+
+//SetConnected simulates the loss and the return of the server. While it is
+//not connected all calls of its connections fail with opcda.ErrNotConnected.
+func (s *Simulator) SetConnected(connected bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.connected = connected
+}
+
+//IsConnected reports if the server is available, see SetConnected.
+func (s *Simulator) IsConnected() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connected
+}
+
+//lookup returns the tag with name. It must be called with the lock held.
+func (s *Simulator) lookup(name string) (*tag, error) {
+	if !s.connected {
+		return nil, opcda.ErrNotConnected
+	}
+	t, ok := s.tags[name]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", name, opcda.ErrTagNotFound)
+	}
+	return t, nil
+}
+
+//read returns the current item of the tag. It must be called with the lock
+//held.
+func (s *Simulator) read(name string) (opcda.Item, error) {
+	t, err := s.lookup(name)
+	if err != nil {
+		return opcda.Item{}, err
+	}
+	now := s.now()
+	elapsed := now.Sub(s.start)
+	item := opcda.Item{Value: 0.0, Quality: opcda.OPCQualityGood, Timestamp: now}
+	switch {
+	case t.written:
+		item.Value, item.Timestamp = t.value, t.timestamp
+	case t.Waveform != nil:
+		item.Value = t.Waveform.At(elapsed)
+	}
+	if t.quality != nil {
+		item.Quality = *t.quality
+		return item, nil
+	}
+	for _, f := range t.Faults {
+		if f.active(elapsed, s.rand.Float64) {
+			item.Quality = f.Quality
+			break
+		}
+	}
+	return item, nil
+}
+
+//write keeps value as the value of the tag.
+func (s *Simulator) write(name string, value interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, err := s.lookup(name)
+	if err != nil {
+		return err
+	}
+	if !t.Writable {
+		return fmt.Errorf("cannot write %s: %w", name, opcda.OPCErrBadRights)
+	}
+	t.written, t.value, t.timestamp = true, value, s.now()
+	return nil
+}
+
+//add checks that the tags exist like adding them to an OPC group.
+func (s *Simulator) add(names []string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.connected {
+		return nil, opcda.ErrNotConnected
+	}
+	var added []string
+	var errs opcda.MultiError
+	for _, name := range names {
+		if _, ok := s.tags[name]; !ok {
+			errs = append(errs, &opcda.AddItemError{Tag: name, HRESULT: opcda.OPCErrUnknownItemID, Err: opcda.OPCErrUnknownItemID})
+			continue
+		}
+		added = append(added, name)
+	}
+	if len(errs) > 0 {
+		return added, errs
+	}
+	return added, nil
+}
+
+//Browser returns a browser of the namespace at its root.
+func (s *Simulator) Browser() opcda.Browser {
+	return opcda.NewTreeBrowser(s.Tree())
+}
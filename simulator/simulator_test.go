@@ -0,0 +1,245 @@
+package simulator
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rxue92/opcda"
+	"github.com/rxue92/opcda/opcdatest"
+)
+
+//testClock is a clock which only moves when told.
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func newTestSimulator(t *testing.T) (*Simulator, *testClock) {
+	clock := &testClock{now: time.Unix(1000, 0)}
+	sim, err := New([]Tag{
+		{Name: "numeric.saw", Waveform: Saw{Min: 0, Max: 10, Period: 10 * time.Second}},
+		{Name: "numeric.setpoint", Writable: true},
+		{Name: "numeric.flaky", Waveform: Constant{Value: int32(1)}, Faults: []Fault{
+			{After: 5 * time.Second, For: time.Second, Every: 10 * time.Second, Quality: opcda.OPCQualityCommFailure},
+		}},
+		{Name: "textual.mode", Waveform: Constant{Value: "auto"}},
+		{Name: "root"},
+	}, WithClock(clock.Now), WithSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sim, clock
+}
+
+func TestNew(t *testing.T) {
+	for _, tags := range [][]Tag{
+		{{Name: ""}},
+		{{Name: "a..b"}},
+		{{Name: "a."}},
+		{{Name: "a"}, {Name: "a"}},
+		{{Name: "a", Waveform: Sine{}}},
+		{{Name: "a", Faults: []Fault{{Every: time.Second}}}},
+		{{Name: "a", Faults: []Fault{{Probability: 2}}}},
+	} {
+		if _, err := New(tags); err == nil {
+			t.Errorf("%v should be invalid", tags)
+		}
+	}
+}
+
+func TestConnection(t *testing.T) {
+	sim, clock := newTestSimulator(t)
+	conn, err := sim.Connect("numeric.saw", "numeric.setpoint", "missing")
+	var addErr *opcda.AddItemError
+	if !errors.As(err, &addErr) || addErr.Tag != "missing" || !errors.Is(err, opcda.OPCErrUnknownItemID) {
+		t.Fatalf("expected AddItemError for missing, got %v", err)
+	}
+	if tags := conn.Tags(); !reflect.DeepEqual(tags, []string{"numeric.saw", "numeric.setpoint"}) {
+		t.Fatalf("unexpected tags %v", tags)
+	}
+
+	clock.now = clock.now.Add(3 * time.Second)
+	items := conn.Read()
+	if saw := items["numeric.saw"]; saw.Value != 3.0 || !saw.Good() || !saw.Timestamp.Equal(clock.now) {
+		t.Errorf("unexpected saw %v", saw)
+	}
+	if v := items["numeric.setpoint"].Value; v != 0.0 {
+		t.Errorf("tags without waveform should start at 0, got %v", v)
+	}
+
+	if err := conn.Write("numeric.setpoint", int16(7)); err != nil {
+		t.Fatal(err)
+	}
+	other, _ := sim.Connect("numeric.setpoint", "numeric.saw")
+	if v := other.ReadItem("numeric.setpoint").Value; v != int16(7) {
+		t.Errorf("written value should be kept, got %v", v)
+	}
+	if err := other.Write("numeric.saw", 1.0); !errors.Is(err, opcda.OPCErrBadRights) {
+		t.Errorf("expected OPCErrBadRights, got %v", err)
+	}
+	if _, err := conn.ReadItemContext(context.Background(), "textual.mode"); !errors.Is(err, opcda.ErrTagNotFound) {
+		t.Errorf("expected ErrTagNotFound for tag not added, got %v", err)
+	}
+
+	writer, _ := sim.Connect()
+	if err := writer.Write("numeric.setpoint", int16(9)); err != nil {
+		t.Fatalf("write should add the tag, got %v", err)
+	}
+	if tags := writer.Tags(); !reflect.DeepEqual(tags, []string{"numeric.setpoint"}) {
+		t.Errorf("written tag should be added, got %v", tags)
+	}
+	if items := writer.Read(); len(items) != 0 {
+		t.Errorf("tag added by a write should not be read, got %v", items)
+	}
+	if v := writer.ReadItem("numeric.setpoint").Value; v != int16(9) {
+		t.Errorf("tag added by a write should be read alone, got %v", v)
+	}
+	if err := writer.Write("missing", 1.0); !errors.As(err, &addErr) || writer.Tags()[0] != "numeric.setpoint" || len(writer.Tags()) != 1 {
+		t.Errorf("expected AddItemError for missing, got %v", err)
+	}
+	writer.Add("numeric.setpoint")
+	if items := writer.Read(); items["numeric.setpoint"].Value != int16(9) {
+		t.Errorf("tag added after the write should be read, got %v", items)
+	}
+
+	conn.Remove("numeric.saw")
+	if len(conn.Read()) != 1 {
+		t.Error("removed tag should not be read")
+	}
+
+	conn.Close()
+	if conn.IsConnected() || !other.IsConnected() {
+		t.Error("only the closed connection should be disconnected")
+	}
+	if err := conn.Add("numeric.saw"); !errors.Is(err, opcda.ErrNotConnected) {
+		t.Errorf("expected ErrNotConnected after close, got %v", err)
+	}
+}
+
+func TestSetConnected(t *testing.T) {
+	sim, _ := newTestSimulator(t)
+	conn, _ := sim.Connect("textual.mode")
+
+	sim.SetConnected(false)
+	if conn.IsConnected() {
+		t.Error("connection should follow the simulator")
+	}
+	if _, err := conn.ReadContext(context.Background()); !errors.Is(err, opcda.ErrNotConnected) {
+		t.Errorf("expected ErrNotConnected, got %v", err)
+	}
+	if err := conn.Write("textual.mode", "manual"); !errors.Is(err, opcda.ErrNotConnected) {
+		t.Errorf("expected ErrNotConnected, got %v", err)
+	}
+
+	sim.SetConnected(true)
+	if v := conn.ReadItem("textual.mode").Value; v != "auto" {
+		t.Errorf("expected auto after reconnect, got %v", v)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := conn.ReadContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected canceled read, got %v", err)
+	}
+}
+
+func TestFaults(t *testing.T) {
+	sim, clock := newTestSimulator(t)
+	conn, _ := sim.Connect("numeric.flaky")
+	start := clock.now
+	quality := func(at time.Duration) opcda.Quality {
+		clock.now = start.Add(at)
+		return conn.ReadItem("numeric.flaky").Quality
+	}
+	for at, want := range map[time.Duration]opcda.Quality{
+		0:                       opcda.OPCQualityGood,
+		5 * time.Second:         opcda.OPCQualityCommFailure,
+		5500 * time.Millisecond: opcda.OPCQualityCommFailure,
+		6 * time.Second:         opcda.OPCQualityGood,
+		25 * time.Second:        opcda.OPCQualityCommFailure,
+	} {
+		if q := quality(at); q != want {
+			t.Errorf("at %s expected %s, got %s", at, want, q)
+		}
+	}
+
+	sim.SetQuality("numeric.flaky", opcda.OPCQualityLocalOverride)
+	if q := quality(5 * time.Second); q != opcda.OPCQualityLocalOverride {
+		t.Errorf("set quality should override faults, got %s", q)
+	}
+	sim.ClearQuality("numeric.flaky")
+	if q := quality(0); q != opcda.OPCQualityGood {
+		t.Errorf("expected good after clear, got %s", q)
+	}
+	if err := sim.SetQuality("missing", 0); !errors.Is(err, opcda.ErrTagNotFound) {
+		t.Errorf("expected ErrTagNotFound, got %v", err)
+	}
+
+	random := Fault{Probability: 0.5, Quality: opcda.OPCQualityBad}
+	hits := 0
+	for i := 0; i < 1000; i++ {
+		if random.active(0, sim.rand.Float64) {
+			hits++
+		}
+	}
+	if hits < 400 || hits > 600 {
+		t.Errorf("expected about half of the reads to fail, got %d", hits)
+	}
+}
+
+func TestBrowser(t *testing.T) {
+	sim, _ := newTestSimulator(t)
+	b := sim.Browser()
+	defer b.Close()
+
+	if branches := b.ShowBranches(); !reflect.DeepEqual(branches, []string{"numeric", "textual"}) {
+		t.Errorf("unexpected root branches %v", branches)
+	}
+	if leafs := b.ShowLeafs(); len(leafs) != 1 || leafs[0].ItemId != "root" {
+		t.Errorf("unexpected root leafs %v", leafs)
+	}
+	b.MoveDown("numeric")
+	if leafs := b.ShowLeafs(); len(leafs) != 3 || leafs[0].Name != "flaky" || leafs[0].ItemId != "numeric.flaky" {
+		t.Errorf("unexpected leafs %v", leafs)
+	}
+	b.MoveDown("missing")
+	if pos := b.Position(); pos != "numeric" {
+		t.Errorf("unknown branch should not move, got %q", pos)
+	}
+	b.MoveTo("textual")
+	if pos := b.Position(); pos != "textual" {
+		t.Errorf("expected textual, got %q", pos)
+	}
+	b.MoveTo("numeric", "missing")
+	b.MoveUp()
+	if pos := b.Position(); pos != "" {
+		t.Errorf("expected root, got %q", pos)
+	}
+	b.MoveTo("textual")
+	b.MoveToRoot()
+	if pos := b.Position(); pos != "" {
+		t.Errorf("expected root, got %q", pos)
+	}
+
+	tags := opcda.CollectTags(sim.Tree())
+	if len(tags) != 5 {
+		t.Errorf("tree should contain all tags, got %v", tags)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	sim, _ := newTestSimulator(t)
+	opcdatest.TestConnection(t, opcdatest.ConnectionConfig{
+		New:     func() (opcda.Connection, error) { return sim.Connect() },
+		Tags:    []string{"numeric.saw", "numeric.flaky", "textual.mode"},
+		Missing: "numeric.missing",
+		Values:  map[string]interface{}{"numeric.setpoint": int32(42)},
+	})
+	opcdatest.TestBrowser(t, func() (opcda.Browser, error) { return sim.Browser(), nil })
+}
@@ -0,0 +1,202 @@
+package simulator
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+//Waveform generates the value of a tag. At returns the value at the time
+//elapsed since the start of the simulator.
+type Waveform interface {
+	At(elapsed time.Duration) interface{}
+}
+
+//validator is implemented by waveforms which can be misconfigured.
+type validator interface {
+	validate() error
+}
+
+//errPeriod is returned for periodic waveforms without a period.
+var errPeriod = errors.New("period must be positive")
+
+//phase returns the fraction of the period elapsed in the current period.
+func phase(elapsed, period time.Duration) float64 {
+	return float64(elapsed%period) / float64(period)
+}
+
+//Constant always returns Value, which may be of any type.
+type Constant struct {
+	Value interface{}
+}
+
+func (c Constant) At(time.Duration) interface{} {
+	return c.Value
+}
+
+//Sine oscillates around Offset by Amplitude. Phase shifts the wave in
+//radians.
+type Sine struct {
+	Amplitude float64
+	Offset    float64
+	Period    time.Duration
+	Phase     float64
+}
+
+func (s Sine) At(elapsed time.Duration) interface{} {
+	return s.Offset + s.Amplitude*math.Sin(2*math.Pi*phase(elapsed, s.Period)+s.Phase)
+}
+
+func (s Sine) validate() error {
+	if s.Period <= 0 {
+		return errPeriod
+	}
+	return nil
+}
+
+//Saw rises linearly from Min to Max in every period.
+type Saw struct {
+	Min    float64
+	Max    float64
+	Period time.Duration
+}
+
+func (s Saw) At(elapsed time.Duration) interface{} {
+	return s.Min + (s.Max-s.Min)*phase(elapsed, s.Period)
+}
+
+func (s Saw) validate() error {
+	if s.Period <= 0 {
+		return errPeriod
+	}
+	return nil
+}
+
+//Square is High for the Duty fraction of every period and Low for the rest.
+//A zero Duty is a duty cycle of one half.
+type Square struct {
+	Low    float64
+	High   float64
+	Period time.Duration
+	Duty   float64
+}
+
+func (s Square) At(elapsed time.Duration) interface{} {
+	duty := s.Duty
+	if duty == 0 {
+		duty = 0.5
+	}
+	if phase(elapsed, s.Period) < duty {
+		return s.High
+	}
+	return s.Low
+}
+
+func (s Square) validate() error {
+	if s.Period <= 0 {
+		return errPeriod
+	}
+	if s.Duty < 0 || s.Duty > 1 {
+		return errors.New("duty must be between 0 and 1")
+	}
+	return nil
+}
+
+//Ramp starts at Start and changes by Rate per second without bound.
+type Ramp struct {
+	Start float64
+	Rate  float64
+}
+
+func (r Ramp) At(elapsed time.Duration) interface{} {
+	return r.Start + r.Rate*elapsed.Seconds()
+}
+
+//RandomWalk starts at Start and moves by a random amount of up to Step in
+//every Interval, limited to Min and Max unless both are zero. The walk is
+//the same for the same Seed. A RandomWalk keeps its position, so it must be
+//used as a pointer and only for one tag.
+type RandomWalk struct {
+	Start    float64
+	Step     float64
+	Min      float64
+	Max      float64
+	Interval time.Duration
+	Seed     int64
+
+	mu    sync.Mutex
+	rand  *rand.Rand
+	steps int64
+	value float64
+}
+
+func (w *RandomWalk) At(elapsed time.Duration) interface{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.rand == nil {
+		w.rand = rand.New(rand.NewSource(w.Seed))
+		w.value = w.Start
+	}
+	for steps := int64(elapsed / w.Interval); w.steps < steps; w.steps++ {
+		w.value += w.Step * (2*w.rand.Float64() - 1)
+		if w.Min != 0 || w.Max != 0 {
+			w.value = math.Max(w.Min, math.Min(w.Max, w.value))
+		}
+	}
+	return w.value
+}
+
+func (w *RandomWalk) validate() error {
+	if w.Interval <= 0 {
+		return errors.New("interval must be positive")
+	}
+	if w.Min > w.Max {
+		return errors.New("min must not be greater than max")
+	}
+	return nil
+}
+
+//Step is a value of a Schedule which applies from At on.
+type Step struct {
+	At    time.Duration
+	Value interface{}
+}
+
+//Schedule returns the value of the last step which has been reached, or of
+//the first step before that. The steps must be in order of time. If Period
+//is positive the schedule starts over in every period.
+type Schedule struct {
+	Steps  []Step
+	Period time.Duration
+}
+
+func (s Schedule) At(elapsed time.Duration) interface{} {
+	if s.Period > 0 {
+		elapsed %= s.Period
+	}
+	value := s.Steps[0].Value
+	for _, step := range s.Steps {
+		if step.At > elapsed {
+			break
+		}
+		value = step.Value
+	}
+	return value
+}
+
+func (s Schedule) validate() error {
+	if len(s.Steps) == 0 {
+		return errors.New("schedule has no steps")
+	}
+	for i := 1; i < len(s.Steps); i++ {
+		if s.Steps[i].At < s.Steps[i-1].At {
+			return errors.New("steps must be in order of time")
+		}
+	}
+	if s.Period < 0 {
+		return errPeriod
+	}
+	return nil
+}
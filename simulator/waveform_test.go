@@ -0,0 +1,74 @@
+package simulator
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestWaveforms(t *testing.T) {
+	second := time.Second
+	tests := []struct {
+		name     string
+		waveform Waveform
+		at       time.Duration
+		want     interface{}
+	}{
+		{"constant", Constant{Value: "on"}, time.Hour, "on"},
+		{"sine start", Sine{Amplitude: 2, Offset: 1, Period: 4 * second}, 0, 1.0},
+		{"sine peak", Sine{Amplitude: 2, Offset: 1, Period: 4 * second}, 5 * second, 3.0},
+		{"sine phase", Sine{Amplitude: 2, Period: 4 * second, Phase: math.Pi / 2}, 0, 2.0},
+		{"saw", Saw{Min: 10, Max: 20, Period: 10 * second}, 13 * second, 13.0},
+		{"square high", Square{Low: 0, High: 1, Period: 10 * second}, 4 * second, 1.0},
+		{"square low", Square{Low: 0, High: 1, Period: 10 * second}, 5 * second, 0.0},
+		{"square duty", Square{Low: 0, High: 1, Period: 10 * second, Duty: 0.8}, 7 * second, 1.0},
+		{"ramp", Ramp{Start: 5, Rate: -2}, 3 * second, -1.0},
+		{"schedule before", Schedule{Steps: []Step{{At: second, Value: 1}, {At: 2 * second, Value: 2}}}, 0, 1},
+		{"schedule", Schedule{Steps: []Step{{At: 0, Value: 1}, {At: 2 * second, Value: 2}}}, 3 * second, 2},
+		{"schedule period", Schedule{Steps: []Step{{At: 0, Value: 1}, {At: 2 * second, Value: 2}}, Period: 3 * second}, 4 * second, 1},
+	}
+	for _, test := range tests {
+		got := test.waveform.At(test.at)
+		if f, ok := got.(float64); ok {
+			got = math.Round(f*1e9) / 1e9
+		}
+		if got != test.want {
+			t.Errorf("%s: expected %v, got %v", test.name, test.want, got)
+		}
+	}
+}
+
+func TestRandomWalk(t *testing.T) {
+	walk := func() *RandomWalk {
+		return &RandomWalk{Start: 50, Step: 10, Min: 45, Max: 55, Interval: time.Second, Seed: 7}
+	}
+	a, b := walk(), walk()
+	if a.At(0) != 50.0 {
+		t.Fatalf("walk should start at 50, got %v", a.At(0))
+	}
+	for i := 1; i <= 100; i++ {
+		v := a.At(time.Duration(i) * time.Second).(float64)
+		if v < 45 || v > 55 {
+			t.Fatalf("walk left its limits: %v", v)
+		}
+	}
+	if b.At(100*time.Second) != a.At(100*time.Second) {
+		t.Error("walks with the same seed should be equal")
+	}
+	if a.At(100500*time.Millisecond) != a.At(100*time.Second) {
+		t.Error("walk should only move once per interval")
+	}
+}
+
+func TestWaveformValidate(t *testing.T) {
+	invalid := []validator{
+		Sine{}, Saw{}, Square{}, Square{Period: time.Second, Duty: 2},
+		&RandomWalk{}, &RandomWalk{Interval: time.Second, Min: 1},
+		Schedule{}, Schedule{Steps: []Step{{At: time.Second}, {At: 0}}},
+	}
+	for _, w := range invalid {
+		if w.validate() == nil {
+			t.Errorf("%#v should be invalid", w)
+		}
+	}
+}
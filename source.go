@@ -0,0 +1,67 @@
+package opcda
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+//ReadSource selects where the OPC server takes the values of a read from.
+type ReadSource struct {
+	//Source is OPCCache or OPCDevice.
+	Source int32
+	//MaxAge only applies to OPCCache: values in the cache older than MaxAge
+	//are read again from the device. Zero accepts values of any age.
+	//The OPC Automation 2.0 interface has no max-age read, so it is emulated
+	//with a second read from the device for the stale tags.
+	MaxAge time.Duration
+}
+
+var (
+	//SourceCache reads the values from the cache of the server. It is the default.
+	SourceCache = ReadSource{Source: OPCCache}
+	//SourceDevice bypasses the cache and reads the values from the device.
+	SourceDevice = ReadSource{Source: OPCDevice}
+)
+
+//SourceMaxAge reads the values from the cache unless they are older than maxAge.
+func SourceMaxAge(maxAge time.Duration) ReadSource {
+	return ReadSource{Source: OPCCache, MaxAge: maxAge}
+}
+
+//validate checks the source before it is used.
+func (src ReadSource) validate() error {
+	if src.Source != OPCCache && src.Source != OPCDevice {
+		return errors.New("read source must be OPCCache or OPCDevice")
+	}
+	if src.MaxAge < 0 {
+		return errors.New("max age must not be negative")
+	}
+	return nil
+}
+
+//stale reports if item has to be read again from the device.
+func (src ReadSource) stale(item Item, now time.Time) bool {
+	return src.Source == OPCCache && src.MaxAge > 0 && now.Sub(item.Timestamp) > src.MaxAge
+}
+
+//ReadSourceSetter is implemented by connections whose default read source
+//can be changed. The default applies to all reads without a source in their
+//context, including the reads of Collector.Sync.
+type ReadSourceSetter interface {
+	SetReadSource(ReadSource) error
+}
+
+type readSourceKey struct{}
+
+//WithReadSource returns a context which makes ReadContext and ReadItemContext
+//read from src instead of the default source of the connection.
+func WithReadSource(ctx context.Context, src ReadSource) context.Context {
+	return context.WithValue(ctx, readSourceKey{}, src)
+}
+
+//ReadSourceFromContext returns the read source set with WithReadSource.
+func ReadSourceFromContext(ctx context.Context) (ReadSource, bool) {
+	src, ok := ctx.Value(readSourceKey{}).(ReadSource)
+	return src, ok
+}
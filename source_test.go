@@ -0,0 +1,38 @@
+package opcda
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestReadSource(t *testing.T) {
+	for _, src := range []ReadSource{SourceCache, SourceDevice, SourceMaxAge(time.Second)} {
+		if err := src.validate(); err != nil {
+			t.Errorf("%v should be valid: %s", src, err)
+		}
+	}
+	for _, src := range []ReadSource{{}, {Source: 3}, SourceMaxAge(-time.Second)} {
+		if src.validate() == nil {
+			t.Errorf("%v should be invalid", src)
+		}
+	}
+
+	old := Item{Timestamp: time.Now().Add(-time.Minute)}
+	if SourceCache.stale(old, time.Now()) || SourceDevice.stale(old, time.Now()) {
+		t.Error("only a max age makes values stale")
+	}
+	if !SourceMaxAge(time.Second).stale(old, time.Now()) || SourceMaxAge(time.Hour).stale(old, time.Now()) {
+		t.Error("values older than max age should be stale")
+	}
+}
+
+func TestReadSourceFromContext(t *testing.T) {
+	if _, ok := ReadSourceFromContext(context.Background()); ok {
+		t.Fatal("background context should have no read source")
+	}
+	ctx := WithReadSource(context.Background(), SourceDevice)
+	if src, ok := ReadSourceFromContext(ctx); !ok || src != SourceDevice {
+		t.Fatal("read source should be taken from the context")
+	}
+}
@@ -0,0 +1,191 @@
+package opcda
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+//State is the state of a connection, as opposed to the ServerState reported
+//by the server.
+type State int32
+
+const (
+	//StateConnecting while the connection is created.
+	StateConnecting State = iota
+	//StateConnected while all tags can be read.
+	StateConnected
+	//StateDegraded while the server is connected but some tags cannot be read.
+	StateDegraded
+	//StateReconnecting while a lost connection is re-established.
+	StateReconnecting
+	//StateClosed after the connection has been closed.
+	StateClosed
+)
+
+//String returns the name of the state.
+func (s State) String() string {
+	switch s {
+	case StateConnecting:
+		return "Connecting"
+	case StateConnected:
+		return "Connected"
+	case StateDegraded:
+		return "Degraded"
+	case StateReconnecting:
+		return "Reconnecting"
+	case StateClosed:
+		return "Closed"
+	}
+	return fmt.Sprintf("State(%d)", int32(s))
+}
+
+//StateEvent reports a change of the state of a connection at Time.
+//Cause is the error which led to the change, if any. An event from
+//StateReconnecting to itself reports that the ReconnectPolicy gave up; Cause
+//is the *ReconnectError then and the next failing read starts reconnecting again.
+type StateEvent struct {
+	From  State
+	To    State
+	Time  time.Time
+	Cause error
+}
+
+func (e StateEvent) String() string {
+	if e.Cause != nil {
+		return fmt.Sprintf("%s -> %s: %s", e.From, e.To, e.Cause)
+	}
+	return fmt.Sprintf("%s -> %s", e.From, e.To)
+}
+
+//StateNotifier is implemented by connections which track their state.
+//Every call of StateChanges returns a new channel which receives the events
+//after the call and is closed when the connection is closed. If the receiver
+//falls behind, the oldest events are dropped.
+type StateNotifier interface {
+	State() State
+	StateChanges() <-chan StateEvent
+}
+
+//stateBuffer is the number of events buffered for every StateChanges channel.
+const stateBuffer = 16
+
+//stateMachine holds the state of a connection and sends its changes to the
+//channels returned by changes.
+type stateMachine struct {
+	mu        sync.Mutex
+	state     State
+	clock     clock
+	listeners []chan StateEvent
+}
+
+//newStateMachine returns a state machine in state StateConnecting.
+func newStateMachine() *stateMachine {
+	return &stateMachine{state: StateConnecting, clock: realClock{}}
+}
+
+//current returns the state.
+func (m *stateMachine) current() State {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state
+}
+
+//changes returns a new channel for the events. It is closed right away if
+//the state machine is closed.
+func (m *stateMachine) changes() <-chan StateEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := make(chan StateEvent, stateBuffer)
+	if m.state == StateClosed {
+		close(c)
+		return c
+	}
+	m.listeners = append(m.listeners, c)
+	return c
+}
+
+//set changes the state to to because of cause and reports if it changed.
+//A closed state machine does not change anymore.
+func (m *stateMachine) set(to State, cause error) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.change(to, cause)
+}
+
+//setFrom changes the state to to only if it is from.
+func (m *stateMachine) setFrom(from, to State, cause error) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.state != from {
+		return false
+	}
+	return m.change(to, cause)
+}
+
+//change changes the state and emits the event. It must be called with the lock held.
+func (m *stateMachine) change(to State, cause error) bool {
+	if m.state == to || m.state == StateClosed {
+		return false
+	}
+	m.emit(StateEvent{From: m.state, To: to, Time: m.clock.Now(), Cause: cause})
+	m.state = to
+	if to == StateClosed {
+		for _, c := range m.listeners {
+			close(c)
+		}
+		m.listeners = nil
+	}
+	return true
+}
+
+//emit sends e to all listeners, dropping their oldest event if they are full.
+//It must be called with the lock held.
+func (m *stateMachine) emit(e StateEvent) {
+	for _, c := range m.listeners {
+		select {
+		case c <- e:
+			continue
+		default:
+		}
+		select {
+		case <-c:
+		default:
+		}
+		c <- e
+	}
+}
+
+//gaveUp reports that reconnecting has given up with err.
+func (m *stateMachine) gaveUp(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.state == StateReconnecting {
+		m.emit(StateEvent{From: m.state, To: m.state, Time: m.clock.Now(), Cause: err})
+	}
+}
+
+//observe returns p with hooks which also change the state before calling
+//the hooks of p.
+func (m *stateMachine) observe(p ReconnectPolicy) ReconnectPolicy {
+	onDisconnect, onReconnect, onFailed := p.OnDisconnect, p.OnReconnect, p.OnReconnectFailed
+	p.OnDisconnect = func(err error) {
+		m.set(StateReconnecting, err)
+		if onDisconnect != nil {
+			onDisconnect(err)
+		}
+	}
+	p.OnReconnect = func(attempts int, downtime time.Duration) {
+		m.set(StateConnected, nil)
+		if onReconnect != nil {
+			onReconnect(attempts, downtime)
+		}
+	}
+	p.OnReconnectFailed = func(err error) {
+		m.gaveUp(err)
+		if onFailed != nil {
+			onFailed(err)
+		}
+	}
+	return p
+}
@@ -0,0 +1,139 @@
+package opcda
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func receive(t *testing.T, c <-chan StateEvent) StateEvent {
+	t.Helper()
+	select {
+	case e := <-c:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("no state event")
+		return StateEvent{}
+	}
+}
+
+func TestStateMachine(t *testing.T) {
+	m := newStateMachine()
+	clock := newFakeClock()
+	m.clock = clock
+	if m.current() != StateConnecting {
+		t.Fatalf("expected Connecting, got %s", m.current())
+	}
+	changes := m.changes()
+
+	m.set(StateConnected, nil)
+	if m.set(StateConnected, nil) {
+		t.Error("setting the same state is no change")
+	}
+	e := receive(t, changes)
+	if e.From != StateConnecting || e.To != StateConnected || !e.Time.Equal(clock.Now()) || e.Cause != nil {
+		t.Errorf("unexpected event %v", e)
+	}
+
+	cause := errors.New("cannot read all tags")
+	clock.Advance(time.Second)
+	if m.setFrom(StateDegraded, StateConnected, nil) {
+		t.Error("only a degraded connection recovers")
+	}
+	m.setFrom(StateConnected, StateDegraded, cause)
+	e = receive(t, changes)
+	if e.To != StateDegraded || e.Cause != cause || !e.Time.Equal(clock.Now()) {
+		t.Errorf("unexpected event %v", e)
+	}
+	if e.String() != "Connected -> Degraded: cannot read all tags" {
+		t.Errorf("unexpected text %q", e.String())
+	}
+
+	m.set(StateClosed, nil)
+	if e := receive(t, changes); e.To != StateClosed {
+		t.Errorf("unexpected event %v", e)
+	}
+	if _, ok := <-changes; ok {
+		t.Error("the channel should be closed with the connection")
+	}
+	if m.set(StateConnected, nil) || m.current() != StateClosed {
+		t.Error("a closed connection should stay closed")
+	}
+	if _, ok := <-m.changes(); ok {
+		t.Error("the channel of a closed connection should be closed")
+	}
+}
+
+func TestStateMachineSlowListener(t *testing.T) {
+	m := newStateMachine()
+	changes := m.changes()
+	states := []State{StateConnected, StateDegraded}
+	for i := 0; i < stateBuffer+3; i++ {
+		m.set(states[i%2], nil)
+	}
+	var last StateEvent
+	for i := 0; i < stateBuffer; i++ {
+		last = receive(t, changes)
+	}
+	if last.To != m.current() {
+		t.Errorf("the latest event should be kept, got %v", last)
+	}
+	select {
+	case e := <-changes:
+		t.Errorf("the oldest events should be dropped, got %v", e)
+	default:
+	}
+}
+
+func TestStateMachineObserve(t *testing.T) {
+	m := newStateMachine()
+	m.set(StateConnected, nil)
+	changes := m.changes()
+
+	var hooks []string
+	policy := m.observe(ReconnectPolicy{
+		Interval:          time.Second,
+		MaxAttempts:       2,
+		OnDisconnect:      func(error) { hooks = append(hooks, "disconnect") },
+		OnReconnect:       func(int, time.Duration) { hooks = append(hooks, "reconnect") },
+		OnReconnectFailed: func(error) { hooks = append(hooks, "failed") },
+	})
+	r, clock, attempts := newTestReconnector(policy, 2)
+
+	lost := errors.New("server down")
+	done := r.start(lost)
+	clock.Advance(clock.wait(t))
+	<-done
+	if e := receive(t, changes); e.To != StateReconnecting || e.Cause != lost {
+		t.Errorf("unexpected event %v", e)
+	}
+	e := receive(t, changes)
+	var rerr *ReconnectError
+	if e.From != StateReconnecting || e.To != StateReconnecting || !errors.As(e.Cause, &rerr) {
+		t.Errorf("giving up should be reported, got %v", e)
+	}
+
+	done = r.start(lost)
+	<-done
+	if *attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", *attempts)
+	}
+	if e := receive(t, changes); e.From != StateReconnecting || e.To != StateConnected {
+		t.Errorf("unexpected event %v", e)
+	}
+	expected := []string{"disconnect", "failed", "disconnect", "reconnect"}
+	if len(hooks) != len(expected) {
+		t.Fatalf("expected hooks %v, got %v", expected, hooks)
+	}
+	for i := range expected {
+		if hooks[i] != expected[i] {
+			t.Errorf("expected hooks %v, got %v", expected, hooks)
+		}
+	}
+}
+
+func TestStateString(t *testing.T) {
+	if StateReconnecting.String() != "Reconnecting" || State(42).String() != "State(42)" {
+		t.Error("unexpected state names")
+	}
+}
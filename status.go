@@ -0,0 +1,141 @@
+package opcda
+
+import (
+	"fmt"
+	"time"
+)
+
+//ServerState is the state of the OPC server.
+type ServerState int32
+
+const (
+	//OPCServerState defines the state of the server:
+	//Disconnected
+	OPCDisconnected ServerState = 6
+	//Failed
+	OPCFailed ServerState = 2
+	//Noconfig
+	OPCNoconfig ServerState = 3
+	//Running
+	OPCRunning ServerState = 1
+	//Suspended
+	OPCSuspended ServerState = 4
+	//Test
+	OPCTest ServerState = 5
+)
+
+//String returns the name of the state.
+func (s ServerState) String() string {
+	switch s {
+	case OPCDisconnected:
+		return "Disconnected"
+	case OPCFailed:
+		return "Failed"
+	case OPCNoconfig:
+		return "No Configuration"
+	case OPCRunning:
+		return "Running"
+	case OPCSuspended:
+		return "Suspended"
+	case OPCTest:
+		return "Test"
+	}
+	return fmt.Sprintf("ServerState(%d)", int32(s))
+}
+
+//ServerStatus holds the state and the vendor information of the OPC server.
+//The times are given by the clock of the server.
+type ServerStatus struct {
+	State          ServerState `json:"state"`
+	ServerName     string      `json:"serverName"`
+	ServerNode     string      `json:"serverNode"`
+	VendorInfo     string      `json:"vendorInfo"`
+	MajorVersion   int16       `json:"majorVersion"`
+	MinorVersion   int16       `json:"minorVersion"`
+	BuildNumber    int16       `json:"buildNumber"`
+	StartTime      time.Time   `json:"startTime"`
+	CurrentTime    time.Time   `json:"currentTime"`
+	LastUpdateTime time.Time   `json:"lastUpdateTime"`
+	//Bandwidth is the server specific bandwidth, usually in percent.
+	Bandwidth int32  `json:"bandwidth"`
+	LocaleID  uint32 `json:"localeId"`
+}
+
+//StatusReader is implemented by connections which read the status of the server.
+type StatusReader interface {
+	ServerStatus() (ServerStatus, error)
+}
+
+//Version returns the version of the server as major.minor.build.
+func (s *ServerStatus) Version() string {
+	return fmt.Sprintf("%d.%d.%d", s.MajorVersion, s.MinorVersion, s.BuildNumber)
+}
+
+//ClockOffset returns how far the clock of the server is behind now. A server
+//with a frozen clock falls further behind with every call.
+func (s *ServerStatus) ClockOffset(now time.Time) time.Duration {
+	return now.Sub(s.CurrentTime)
+}
+
+//UpdateAge returns how long ago the server last sent data to a client,
+//measured with the clock of the server.
+func (s *ServerStatus) UpdateAge() time.Duration {
+	if s.LastUpdateTime.IsZero() {
+		return 0
+	}
+	return s.CurrentTime.Sub(s.LastUpdateTime)
+}
+
+//Uptime returns how long the server has been running.
+func (s *ServerStatus) Uptime() time.Duration {
+	return s.CurrentTime.Sub(s.StartTime)
+}
+
+//ServerStatus reads the state and the vendor information of the server.
+func (ao *AutomationObject) ServerStatus() (ServerStatus, error) {
+	var status ServerStatus
+	if ao.opc == nil {
+		return status, fmt.Errorf("cannot read server status: %w", ErrNotConnected)
+	}
+	var err error
+	property := func(name string) interface{} {
+		if err != nil {
+			return nil
+		}
+		v, perr := ao.opc.get(name)
+		if perr != nil {
+			err = fmt.Errorf("cannot get %s property: %w", name, refineOleError(perr))
+			return nil
+		}
+		return v
+	}
+	integer := func(name string) int64 {
+		v, _ := toInt64(property(name))
+		return v
+	}
+	date := func(name string) time.Time {
+		v, _ := property(name).(time.Time)
+		return v
+	}
+	text := func(name string) string {
+		v, _ := property(name).(string)
+		return v
+	}
+
+	status.State = ServerState(integer("ServerState"))
+	status.ServerName = text("ServerName")
+	status.ServerNode = text("ServerNode")
+	status.VendorInfo = text("VendorInfo")
+	status.MajorVersion = int16(integer("MajorVersion"))
+	status.MinorVersion = int16(integer("MinorVersion"))
+	status.BuildNumber = int16(integer("BuildNumber"))
+	status.StartTime = date("StartTime")
+	status.CurrentTime = date("CurrentTime")
+	status.LastUpdateTime = date("LastUpdateTime")
+	status.Bandwidth = int32(integer("Bandwidth"))
+	status.LocaleID = uint32(integer("LocaleID"))
+	if err != nil {
+		return ServerStatus{}, err
+	}
+	return status, nil
+}
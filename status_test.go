@@ -0,0 +1,53 @@
+package opcda
+
+import (
+	"testing"
+	"time"
+)
+
+func TestServerState(t *testing.T) {
+	states := map[ServerState]string{
+		OPCRunning:      "Running",
+		OPCFailed:       "Failed",
+		OPCNoconfig:     "No Configuration",
+		OPCSuspended:    "Suspended",
+		OPCTest:         "Test",
+		OPCDisconnected: "Disconnected",
+		7:               "ServerState(7)",
+	}
+	for state, name := range states {
+		if state.String() != name {
+			t.Errorf("%d: got %q, want %q", int32(state), state.String(), name)
+		}
+	}
+}
+
+func TestServerStatus(t *testing.T) {
+	now := time.Now()
+	status := ServerStatus{
+		State:          OPCRunning,
+		MajorVersion:   1,
+		MinorVersion:   4,
+		BuildNumber:    277,
+		StartTime:      now.Add(-2 * time.Hour),
+		CurrentTime:    now.Add(-time.Minute),
+		LastUpdateTime: now.Add(-3 * time.Minute),
+	}
+	if status.Version() != "1.4.277" {
+		t.Errorf("unexpected version %s", status.Version())
+	}
+	if status.ClockOffset(now) != time.Minute {
+		t.Errorf("unexpected clock offset %s", status.ClockOffset(now))
+	}
+	if status.UpdateAge() != 2*time.Minute {
+		t.Errorf("unexpected update age %s", status.UpdateAge())
+	}
+	if status.Uptime() != 119*time.Minute {
+		t.Errorf("unexpected uptime %s", status.Uptime())
+	}
+
+	status.LastUpdateTime = time.Time{}
+	if status.UpdateAge() != 0 {
+		t.Error("server without updates should have no update age")
+	}
+}
@@ -0,0 +1,26 @@
+//go:build windows
+// +build windows
+
+package opcda
+
+import (
+	"context"
+	"fmt"
+)
+
+// ServerStatus reads the state and the vendor information of the server.
+func (conn *opcConnectionImpl) ServerStatus() (ServerStatus, error) {
+	var status ServerStatus
+	var err error
+	cerr := conn.mu.do(context.Background(), func() {
+		if conn.AutomationObject == nil {
+			err = fmt.Errorf("cannot read server status: %w", ErrNotConnected)
+			return
+		}
+		status, err = conn.AutomationObject.ServerStatus()
+	})
+	if cerr != nil {
+		return ServerStatus{}, cerr
+	}
+	return status, err
+}
@@ -0,0 +1,196 @@
+package opcda
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"time"
+)
+
+//SubscriptionOptions configures a subscription created with Subscribe.
+type SubscriptionOptions struct {
+	//UpdateRate is the rate at which the tags are checked for changes.
+	//The default is one second.
+	UpdateRate time.Duration
+	//Buffer is the capacity of the channel with the changes.
+	//The default is 100.
+	//Changes pushed by the server are delivered from its event thread, which
+	//must never wait for a slow consumer: if the channel is full, the oldest
+	//change is dropped to make room for the new one. Polled subscriptions
+	//wait for the consumer instead.
+	Buffer int
+}
+
+//withDefaults returns the options with the zero values replaced by the defaults.
+func (opts SubscriptionOptions) withDefaults() SubscriptionOptions {
+	if opts.UpdateRate <= 0 {
+		opts.UpdateRate = time.Second
+	}
+	if opts.Buffer <= 0 {
+		opts.Buffer = 100
+	}
+	return opts
+}
+
+//ItemChange is sent on a subscription when the value or the quality of a tag changes.
+type ItemChange struct {
+	Tag string
+	Item
+}
+
+//Subscriber is implemented by connections that get the data changes pushed
+//by the OPC server.
+type Subscriber interface {
+	Subscribe(tags []string, opts SubscriptionOptions) (<-chan ItemChange, io.Closer)
+}
+
+//Subscribe returns a channel which receives the current value of the tags and
+//then every change of their value or quality. The tags are added to the
+//connection if necessary. If conn implements Subscriber the server pushes the
+//changes, otherwise the tags are polled at the update rate and compared to the
+//previous values. Closing the returned io.Closer ends the subscription and
+//closes the channel. The changes pushed by the server of a Connection end
+//when the connection is lost, and the channel is closed then too; polled
+//subscriptions go on after a reconnect.
+func Subscribe(conn Connection, tags []string, opts SubscriptionOptions) (<-chan ItemChange, io.Closer) {
+	if s, ok := conn.(Subscriber); ok {
+		return s.Subscribe(tags, opts)
+	}
+	return pollChanges(conn, tags, opts)
+}
+
+//subscription stops the goroutine feeding a subscription channel.
+type subscription struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+//Close stops the subscription and waits until its channel is closed.
+func (s *subscription) Close() error {
+	s.cancel()
+	<-s.done
+	return nil
+}
+
+//pollChanges implements Subscribe for any Connection by polling it.
+func pollChanges(conn Connection, tags []string, opts SubscriptionOptions) (<-chan ItemChange, io.Closer) {
+	opts = opts.withDefaults()
+	changes := make(chan ItemChange, opts.Buffer)
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &subscription{cancel: cancel, done: make(chan struct{})}
+
+	go func() {
+		defer close(s.done)
+		defer close(changes)
+
+		if err := addMissing(ctx, conn, tags); err != nil {
+			logger.Println("Cannot add all tags of subscription:", err)
+		}
+
+		ticker := time.NewTicker(opts.UpdateRate)
+		defer ticker.Stop()
+
+		last := make(map[string]Item)
+		for {
+			results, err := conn.ReadContext(ctx)
+			if err != nil && ctx.Err() == nil {
+				logger.Println("Cannot read subscription:", err)
+			}
+			for _, tag := range tags {
+				result, ok := results[tag]
+				if !ok || result.Err != nil {
+					continue
+				}
+				prev, seen := last[tag]
+				if seen && !changed(prev, result.Item) {
+					continue
+				}
+				last[tag] = result.Item
+				select {
+				case changes <- ItemChange{Tag: tag, Item: result.Item}:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return changes, s
+}
+
+//sendDropOldest sends change on c without blocking. If c is full, the oldest
+//change is dropped to make room; the number of dropped changes is returned.
+func sendDropOldest(c chan ItemChange, change ItemChange) int {
+	dropped := 0
+	for {
+		select {
+		case c <- change:
+			return dropped
+		default:
+		}
+		select {
+		case <-c:
+			dropped++
+		default:
+		}
+	}
+}
+
+//watchLost calls lost once the connection is lost, which is when its state
+//changes to reconnecting or closed, or when the server is found disconnected
+//by a check every interval; the check starts reconnecting then. It returns
+//when stop is closed.
+func (conn *opcConnectionImpl) watchLost(interval time.Duration, stop <-chan struct{}, lost func()) {
+	changes := conn.state.changes()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case e, ok := <-changes:
+			if !ok || e.To == StateReconnecting || e.To == StateClosed {
+				lost()
+				return
+			}
+		case <-ticker.C:
+			var down bool
+			conn.mu.do(context.Background(), func() {
+				down = conn.lost(errors.New("subscription found the connection lost"))
+			})
+			if down {
+				lost()
+				return
+			}
+		}
+	}
+}
+
+//addMissing adds the tags which are not yet added to the connection.
+func addMissing(ctx context.Context, conn Connection, tags []string) error {
+	added := make(map[string]bool)
+	for _, tag := range conn.Tags() {
+		added[tag] = true
+	}
+	var missing []string
+	for _, tag := range tags {
+		if !added[tag] {
+			missing = append(missing, tag)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return conn.AddContext(ctx, missing...)
+}
+
+//changed reports if the value or the quality differs between two items.
+func changed(prev, next Item) bool {
+	return prev.Quality != next.Quality || !reflect.DeepEqual(prev.Value, next.Value)
+}
@@ -0,0 +1,151 @@
+package opcda
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSubscribePollingInitialValues(t *testing.T) {
+	conn := &OpcMockServerStatic{TagList: []string{"tag1", "tag2", "tag3"}}
+	changes, sub := Subscribe(conn, []string{"tag1", "tag3"}, SubscriptionOptions{UpdateRate: 20 * time.Millisecond})
+	defer sub.Close()
+
+	got := make(map[string]float64)
+	timeout := time.After(time.Second)
+	for len(got) < 2 {
+		select {
+		case change := <-changes:
+			got[change.Tag] = change.Value.(float64)
+		case <-timeout:
+			t.Fatal("time out while waiting for initial values")
+		}
+	}
+	if got["tag1"] != 1.0 || got["tag3"] != 3.0 {
+		t.Fatalf("unexpected initial values %v", got)
+	}
+
+	// static values do not change
+	select {
+	case change := <-changes:
+		t.Fatalf("unexpected change %v", change)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestSubscribePollingChanges(t *testing.T) {
+	conn := &OpcMockServerRandom{TagList: []string{"tag1"}}
+	changes, sub := Subscribe(conn, []string{"tag1"}, SubscriptionOptions{UpdateRate: 10 * time.Millisecond})
+	defer sub.Close()
+
+	var last interface{}
+	for i := 0; i < 3; i++ {
+		select {
+		case change := <-changes:
+			if change.Tag != "tag1" {
+				t.Fatalf("unexpected tag %s", change.Tag)
+			}
+			if change.Value == last {
+				t.Fatal("subscription should only send changed values")
+			}
+			last = change.Value
+		case <-time.After(time.Second):
+			t.Fatal("time out while waiting for changes")
+		}
+	}
+}
+
+func TestSubscribeClose(t *testing.T) {
+	conn := &OpcMockServerRandom{TagList: []string{"tag1"}}
+	changes, sub := Subscribe(conn, []string{"tag1"}, SubscriptionOptions{UpdateRate: 10 * time.Millisecond, Buffer: 1})
+
+	time.Sleep(50 * time.Millisecond)
+	if err := sub.Close(); err != nil {
+		t.Fatal(err)
+	}
+	sub.Close()
+
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-changes:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("channel should be closed after Close")
+		}
+	}
+}
+
+func TestSubscribeIgnoresReadErrors(t *testing.T) {
+	conn := &OpcMockServerBroken{TagList: []string{"tag1", "tag2"}}
+	conn.Break("tag1")
+	changes, sub := Subscribe(conn, []string{"tag1", "tag2"}, SubscriptionOptions{UpdateRate: 10 * time.Millisecond})
+	defer sub.Close()
+
+	select {
+	case change := <-changes:
+		if change.Tag != "tag2" {
+			t.Fatalf("unexpected change of %s", change.Tag)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("time out while waiting for tag2")
+	}
+	select {
+	case change := <-changes:
+		t.Fatalf("unexpected change %v", change)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSendDropOldest(t *testing.T) {
+	c := make(chan ItemChange, 2)
+	for i, tag := range []string{"tag1", "tag2", "tag3", "tag4"} {
+		dropped := sendDropOldest(c, ItemChange{Tag: tag})
+		if want := i - 1; want > 0 && dropped != 1 || want <= 0 && dropped != 0 {
+			t.Fatalf("unexpected number of dropped changes %d for %s", dropped, tag)
+		}
+	}
+	if first, second := <-c, <-c; first.Tag != "tag3" || second.Tag != "tag4" {
+		t.Fatalf("the newest changes should be kept, got %s and %s", first.Tag, second.Tag)
+	}
+}
+
+func TestSubscriptionWatchLost(t *testing.T) {
+	//the server is found lost by the check at the interval
+	server := newFakeServer(fakeNamespace())
+	conn := newFakeConnection(t, server, "numeric.sin.float")
+	defer conn.Close()
+	lost := make(chan struct{})
+	go conn.watchLost(10*time.Millisecond, make(chan struct{}), func() { close(lost) })
+	changes := conn.StateChanges()
+	server.crash()
+	server.fail("Connect", errors.New("RPC server unavailable"))
+	select {
+	case <-lost:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the lost connection should end the subscription")
+	}
+	if e := receive(t, changes); e.To != StateReconnecting {
+		t.Errorf("the check should start reconnecting, got %s", e)
+	}
+
+	//closing the connection ends the subscription, stopping it does not
+	conn = newFakeConnection(t, newFakeServer(fakeNamespace()))
+	closed := make(chan struct{})
+	go conn.watchLost(time.Hour, make(chan struct{}), func() { close(closed) })
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		conn.watchLost(time.Hour, stop, func() { t.Error("a stopped subscription should not end") })
+		close(stopped)
+	}()
+	close(stop)
+	<-stopped
+	conn.Close()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("closing the connection should end the subscription")
+	}
+}
@@ -0,0 +1,229 @@
+//go:build windows
+// +build windows
+
+package opcda
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	ole "github.com/go-ole/go-ole"
+	"github.com/go-ole/go-ole/oleutil"
+)
+
+// Subscribe creates a dedicated OPC group for the tags and forwards its
+// DataChange events. If the group cannot be set up or a tag cannot be added
+// to it, it falls back to polling all tags.
+// The group does not survive a lost connection: its channel is closed when
+// the connection is lost, which is checked at the update rate, or closed.
+// Subscribe again after the reconnect.
+func (conn *opcConnectionImpl) Subscribe(tags []string, opts SubscriptionOptions) (<-chan ItemChange, io.Closer) {
+	opts = opts.withDefaults()
+	var sub *groupSubscription
+	var err error
+	conn.mu.do(context.Background(), func() {
+		sub, err = newGroupSubscription(conn.AutomationObject, tags, opts)
+	})
+	if err != nil {
+		conn.log().Println("Cannot subscribe to data changes, polling instead:", err)
+		return pollChanges(conn, tags, opts)
+	}
+	sub.lock = func(f func()) { conn.mu.do(context.Background(), f) }
+	go conn.watchLost(opts.UpdateRate, sub.stop, func() {
+		conn.log().Println("Subscription ended by the lost connection")
+		sub.Close()
+	})
+	return sub.changes, sub
+}
+
+// groupSubscription receives the DataChange events of an OPC group.
+type groupSubscription struct {
+	groups  *ole.IDispatch
+	group   *ole.IDispatch
+	sink    *eventSink
+	point   *ole.IConnectionPoint
+	cookie  uint32
+	handles map[int32]string // client handle to tag
+	lock    func(func())
+	stop    chan struct{} // closed by Close to stop watching the connection
+
+	changes   chan ItemChange
+	mu        sync.RWMutex
+	closed    bool
+	closeOnce sync.Once
+}
+
+// newGroupSubscription adds an active and subscribed OPC group with the tags.
+func newGroupSubscription(ao *AutomationObject, tags []string, opts SubscriptionOptions) (*groupSubscription, error) {
+	if ao == nil || !ao.IsConnected() {
+		return nil, ErrNotConnected
+	}
+	groups, err := oleutil.GetProperty(oleDispatch(ao.opc), "OPCGroups")
+	if err != nil {
+		return nil, fmt.Errorf("cannot get OPCGroups property: %w", refineOleError(err))
+	}
+	group, err := oleutil.CallMethod(groups.ToIDispatch(), "Add")
+	if err != nil {
+		groups.ToIDispatch().Release()
+		return nil, fmt.Errorf("cannot add new OPC Group: %w", refineOleError(err))
+	}
+	sub := &groupSubscription{
+		groups:  groups.ToIDispatch(),
+		group:   group.ToIDispatch(),
+		handles: make(map[int32]string),
+		stop:    make(chan struct{}),
+		changes: make(chan ItemChange, opts.Buffer),
+	}
+
+	if err := sub.setup(tags, opts); err != nil {
+		sub.release()
+		return nil, err
+	}
+	return sub, nil
+}
+
+// setup adds the items, connects the event sink and activates the group.
+// It fails with a MultiError of *AddItemError if a tag cannot be added.
+func (sub *groupSubscription) setup(tags []string, opts SubscriptionOptions) error {
+	items, err := oleutil.GetProperty(sub.group, "OPCItems")
+	if err != nil {
+		return fmt.Errorf("cannot get OPC Items: %w", refineOleError(err))
+	}
+	defer items.ToIDispatch().Release()
+	var errs []error
+	for i, tag := range tags {
+		handle := int32(i + 1)
+		item, err := oleutil.CallMethod(items.ToIDispatch(), "AddItem", tag, handle)
+		if err != nil {
+			err = refineOleError(err)
+			errs = append(errs, &AddItemError{Tag: tag, HRESULT: hresultOf(err), Err: err})
+			continue
+		}
+		if item.Val == 0 {
+			errs = append(errs, &AddItemError{Tag: tag, Err: errors.New("could not get IDispatch")})
+			continue
+		}
+		item.ToIDispatch().Release()
+		sub.handles[handle] = tag
+	}
+	if err := multiError(errs); err != nil {
+		return err
+	}
+
+	sub.sink = newEventSink(iidOPCGroupEvent, sub.handle)
+	sub.point, sub.cookie, err = advise(sub.group, iidOPCGroupEvent, sub.sink)
+	if err != nil {
+		return fmt.Errorf("cannot connect to DataChange event: %w", refineOleError(err))
+	}
+
+	rate := int32(opts.UpdateRate / time.Millisecond)
+	if _, err := oleutil.PutProperty(sub.group, "UpdateRate", rate); err != nil {
+		return fmt.Errorf("cannot set UpdateRate: %w", refineOleError(err))
+	}
+	if _, err := oleutil.PutProperty(sub.group, "IsActive", true); err != nil {
+		return fmt.Errorf("cannot activate group: %w", refineOleError(err))
+	}
+	if _, err := oleutil.PutProperty(sub.group, "IsSubscribed", true); err != nil {
+		return fmt.Errorf("cannot subscribe to group: %w", refineOleError(err))
+	}
+	return nil
+}
+
+// handle is called by COM for every event of the group.
+// DataChange(TransactionID, NumItems, ClientHandles, ItemValues, Qualities, TimeStamps)
+func (sub *groupSubscription) handle(dispid int32, args []ole.VARIANT) {
+	if dispid != dispidDataChange || len(args) != 6 {
+		return
+	}
+	// arguments are passed in reverse order
+	handles, err := int32sFromVariant(&args[3])
+	if err != nil {
+		logger.Println("Cannot decode DataChange client handles:", err)
+		return
+	}
+	values, err := valuesFromVariant(&args[2])
+	if err != nil {
+		logger.Println("Cannot decode DataChange values:", err)
+		return
+	}
+	qualities, err := int32sFromVariant(&args[1])
+	if err != nil {
+		logger.Println("Cannot decode DataChange qualities:", err)
+		return
+	}
+	timestamps, err := timesFromVariant(&args[0])
+	if err != nil {
+		logger.Println("Cannot decode DataChange timestamps:", err)
+		return
+	}
+	if len(values) != len(handles) || len(qualities) != len(handles) || len(timestamps) != len(handles) {
+		logger.Println("DataChange arrays differ in length")
+		return
+	}
+
+	sub.mu.RLock()
+	defer sub.mu.RUnlock()
+	if sub.closed {
+		return
+	}
+	dropped := 0
+	for i, handle := range handles {
+		tag, ok := sub.handles[handle]
+		if !ok {
+			continue
+		}
+		change := ItemChange{
+			Tag: tag,
+			Item: Item{
+				Value:     values[i],
+				Quality:   Quality(ensureInt16(qualities[i])),
+				Timestamp: timestamps[i],
+			},
+		}
+		dropped += sendDropOldest(sub.changes, change)
+	}
+	if dropped > 0 {
+		logger.Printf("Subscription buffer full, dropped %d old changes", dropped)
+	}
+}
+
+// Close removes the OPC group and closes the channel.
+func (sub *groupSubscription) Close() error {
+	sub.closeOnce.Do(func() {
+		close(sub.stop)
+		sub.lock(sub.release)
+		sub.mu.Lock()
+		sub.closed = true
+		close(sub.changes)
+		sub.mu.Unlock()
+	})
+	return nil
+}
+
+// release disconnects the event sink and removes the group from the server.
+func (sub *groupSubscription) release() {
+	unadvise(sub.point, sub.cookie)
+	sub.point = nil
+	if sub.sink != nil {
+		sub.sink.release()
+		sub.sink = nil
+	}
+	if sub.group != nil {
+		handle, err := oleutil.GetProperty(sub.group, "ServerHandle")
+		if err == nil {
+			if _, err := oleutil.CallMethod(sub.groups, "Remove", handle.Value()); err != nil {
+				logger.Println("Cannot remove OPC group:", err)
+			}
+		}
+		sub.group.Release()
+		sub.group = nil
+	}
+	if sub.groups != nil {
+		sub.groups.Release()
+		sub.groups = nil
+	}
+}
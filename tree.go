@@ -1,19 +1,58 @@
 package opcda
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
 
 //Tree creates an OPC browser representation
+//In JSON the parents are left out, see ReadTree.
 type Tree struct {
-	Name     string
-	Parent   *Tree
-	Branches []*Tree
-	Leaves   []Leaf
+	Name     string  `json:"name"`
+	Parent   *Tree   `json:"-"`
+	Branches []*Tree `json:"branches,omitempty"`
+	Leaves   []Leaf  `json:"leaves,omitempty"`
+}
+
+//ReadTree decodes a namespace in JSON, e.g. one written with json.Marshal of
+//a Tree, and sets the parents of its branches:
+//
+//	{"name": "root", "branches": [
+//		{"name": "numeric", "leaves": [{"name": "float", "itemId": "numeric.sin.float"}]}
+//	]}
+func ReadTree(r io.Reader) (*Tree, error) {
+	var tree Tree
+	if err := json.NewDecoder(r).Decode(&tree); err != nil {
+		return nil, fmt.Errorf("cannot decode tree: %w", err)
+	}
+	if err := setParents(&tree); err != nil {
+		return nil, err
+	}
+	return &tree, nil
+}
+
+//setParents sets the parent of the branches of tree and its subtrees. It
+//fails on a null branch.
+func setParents(tree *Tree) error {
+	for i, b := range tree.Branches {
+		if b == nil {
+			return fmt.Errorf("cannot decode tree: branch %d of %q is null", i, tree.Name)
+		}
+		b.Parent = tree
+		if err := setParents(b); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 //Leaf contains the OPC tag and forms part of the Tree struct for the  OPC browser
+//Properties is only set if the browser was created with the item properties.
 type Leaf struct {
-	Name   string `json:"name"`
-	ItemId string `json:"itemId"`
+	Name       string          `json:"name"`
+	ItemId     string          `json:"itemId"`
+	Properties *ItemProperties `json:"properties,omitempty"`
 }
 
 //ExtractBranchByName return substree with name
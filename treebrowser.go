@@ -0,0 +1,99 @@
+package opcda
+
+import (
+	"strings"
+	"sync"
+)
+
+//treeBrowser implements Browser with a Tree in memory.
+type treeBrowser struct {
+	mu       sync.Mutex
+	root     *Tree
+	position *Tree
+}
+
+//NewTreeBrowser returns a Browser of tree, e.g. from CreateBrowser or
+//ReadTree, which needs no OPC server. Like the browser of the OPC Automation
+//interface, moves to branches which do not exist leave the position
+//unchanged, and the position is the path of the current branch joined by
+//dots, or an empty string at the root.
+func NewTreeBrowser(tree *Tree) Browser {
+	return &treeBrowser{root: tree, position: tree}
+}
+
+//child returns the branch of tree with name, or nil.
+func child(tree *Tree, name string) *Tree {
+	for _, b := range tree.Branches {
+		if b.Name == name {
+			return b
+		}
+	}
+	return nil
+}
+
+func (b *treeBrowser) Close() {}
+
+//MoveTo moves to the absolute path of branches.
+func (b *treeBrowser) MoveTo(branches ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := b.root
+	for _, name := range branches {
+		if t = child(t, name); t == nil {
+			logger.Printf("Cannot MoveTo: no branch %s", strings.Join(branches, "."))
+			return
+		}
+	}
+	b.position = t
+}
+
+func (b *treeBrowser) MoveToRoot() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.position = b.root
+}
+
+func (b *treeBrowser) MoveUp() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.position != b.root && b.position.Parent != nil {
+		b.position = b.position.Parent
+	}
+}
+
+func (b *treeBrowser) MoveDown(branch string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := child(b.position, branch)
+	if t == nil {
+		logger.Printf("Cannot MoveDown: no branch %s", branch)
+		return
+	}
+	b.position = t
+}
+
+func (b *treeBrowser) Position() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var names []string
+	for t := b.position; t != b.root && t != nil; t = t.Parent {
+		names = append([]string{t.Name}, names...)
+	}
+	return strings.Join(names, ".")
+}
+
+func (b *treeBrowser) ShowBranches() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	names := make([]string, 0, len(b.position.Branches))
+	for _, t := range b.position.Branches {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+func (b *treeBrowser) ShowLeafs() []Leaf {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Leaf{}, b.position.Leaves...)
+}
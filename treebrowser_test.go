@@ -0,0 +1,114 @@
+package opcda
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testNamespace = `{"name": "root", "leaves": [{"name": "bandwidth", "itemId": "bandwidth"}], "branches": [
+	{"name": "numeric", "branches": [
+		{"name": "sin", "leaves": [{"name": "float", "itemId": "numeric.sin.float"}, {"name": "int64", "itemId": "numeric.sin.int64"}]}
+	]},
+	{"name": "textual", "leaves": [{"name": "string", "itemId": "textual.string"}]}
+]}`
+
+func TestReadTree(t *testing.T) {
+	tree, err := ReadTree(strings.NewReader(testNamespace))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sin := ExtractBranchByNames(tree, "numeric", "sin")
+	if sin == nil || sin.Parent.Name != "numeric" || sin.Parent.Parent != tree {
+		t.Fatalf("parents should be set: %+v", sin)
+	}
+	if tags := CollectTags(tree); len(tags) != 4 {
+		t.Errorf("unexpected tags %v", tags)
+	}
+
+	b, err := json.Marshal(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := ReadTree(bytes.NewReader(b))
+	if err != nil || !reflect.DeepEqual(CollectTags(again), CollectTags(tree)) {
+		t.Errorf("tree should survive JSON: %s", b)
+	}
+
+	if _, err := ReadTree(strings.NewReader(`{"name": `)); err == nil {
+		t.Error("invalid JSON should fail")
+	}
+	for _, namespace := range []string{`{"branches": [null]}`, `{"branches": [{"name": "numeric", "branches": [null]}]}`} {
+		if _, err := ReadTree(strings.NewReader(namespace)); err == nil {
+			t.Errorf("null branch in %s should fail", namespace)
+		}
+	}
+}
+
+func TestTreeBrowser(t *testing.T) {
+	tree, _ := ReadTree(strings.NewReader(testNamespace))
+	b := NewTreeBrowser(tree)
+	defer b.Close()
+
+	if pos := b.Position(); pos != "" {
+		t.Errorf("root position should be empty, got %q", pos)
+	}
+	if branches := b.ShowBranches(); !reflect.DeepEqual(branches, []string{"numeric", "textual"}) {
+		t.Errorf("unexpected branches %v", branches)
+	}
+	if leafs := b.ShowLeafs(); len(leafs) != 1 || leafs[0].ItemId != "bandwidth" {
+		t.Errorf("unexpected leafs %v", leafs)
+	}
+
+	b.MoveDown("numeric")
+	b.MoveDown("sin")
+	if pos := b.Position(); pos != "numeric.sin" {
+		t.Errorf("expected numeric.sin, got %q", pos)
+	}
+	if leafs := b.ShowLeafs(); len(leafs) != 2 || leafs[1].Name != "int64" || leafs[1].ItemId != "numeric.sin.int64" {
+		t.Errorf("unexpected leafs %v", leafs)
+	}
+	if branches := b.ShowBranches(); len(branches) != 0 {
+		t.Errorf("sin has no branches, got %v", branches)
+	}
+
+	b.MoveDown("missing")
+	b.MoveTo("numeric", "missing")
+	if pos := b.Position(); pos != "numeric.sin" {
+		t.Errorf("unknown branches should not move, got %q", pos)
+	}
+
+	b.MoveUp()
+	if pos := b.Position(); pos != "numeric" {
+		t.Errorf("expected numeric, got %q", pos)
+	}
+	b.MoveTo("textual")
+	if pos := b.Position(); pos != "textual" {
+		t.Errorf("expected textual, got %q", pos)
+	}
+	b.MoveUp()
+	b.MoveUp()
+	if pos := b.Position(); pos != "" {
+		t.Errorf("MoveUp should stop at the root, got %q", pos)
+	}
+	b.MoveTo("numeric", "sin")
+	b.MoveToRoot()
+	if pos := b.Position(); pos != "" {
+		t.Errorf("expected root, got %q", pos)
+	}
+	b.MoveTo("numeric")
+	b.MoveTo()
+	if pos := b.Position(); pos != "" {
+		t.Errorf("MoveTo without branches should move to the root, got %q", pos)
+	}
+
+	//a subtree is browsed from its own root
+	sub := NewTreeBrowser(ExtractBranchByName(tree, "numeric"))
+	sub.MoveUp()
+	sub.MoveDown("sin")
+	if pos := sub.Position(); pos != "sin" {
+		t.Errorf("expected sin in subtree, got %q", pos)
+	}
+}
@@ -32,6 +32,8 @@ func newLogger(w io.Writer) *log.Logger {
 	return log.New(w, "OPC ", log.LstdFlags)
 }
 
+// refineOleError turns an *ole.OleError into a readable error which unwraps
+// to its HRESULT.
 func refineOleError(err error) error {
 	if err == nil {
 		return nil
@@ -40,5 +42,13 @@ func refineOleError(err error) error {
 	if !ok {
 		return err
 	}
-	return fmt.Errorf("code=%v, desc=%q, sub=[%s]", oleError.Code(), strings.TrimRight(oleError.Description(), "\r\n"), refineOleError(oleError.SubError()))
+	code := HRESULT(oleError.Code())
+	// the code of the server is in the EXCEPINFO of DISP_E_EXCEPTION
+	if info, ok := oleError.SubError().(ole.EXCEPINFO); ok && info.SCODE() != 0 {
+		code = HRESULT(info.SCODE())
+	}
+	return &oleCallError{
+		text: fmt.Sprintf("code=%v, desc=%q, sub=[%s]", oleError.Code(), strings.TrimRight(oleError.Description(), "\r\n"), refineOleError(oleError.SubError())),
+		code: code,
+	}
 }
@@ -0,0 +1,128 @@
+package opcda
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+//WriteOptions configures WriteMany.
+type WriteOptions struct {
+	//AllOrNothing reads the previous values of the tags from the device before
+	//writing. If any tag cannot be read, nothing is written; if any tag cannot
+	//be written, the tags already written are restored to their previous values.
+	AllOrNothing bool
+}
+
+//ErrWriteAborted is reported for the tags of an all-or-nothing write which were
+//not written or have been restored because another tag failed.
+var ErrWriteAborted = errors.New("write aborted")
+
+//BatchWriter is implemented by connections which write several tags in one
+//operation.
+type BatchWriter interface {
+	WriteMany(ctx context.Context, values map[string]interface{}, opts WriteOptions) (map[string]error, error)
+}
+
+//WriteMany writes the values to their tags and returns the errors of the tags
+//which could not be written; the map is empty if all tags were written. The
+//error is only set if the operation as a whole failed, e.g. because ctx is done.
+//If conn implements BatchWriter, it writes all tags in one operation, otherwise
+//every tag is written with WriteContext. Tags which are not added yet are
+//added to the connection.
+func WriteMany(ctx context.Context, conn Connection, values map[string]interface{}, opts WriteOptions) (map[string]error, error) {
+	if w, ok := conn.(BatchWriter); ok {
+		return w.WriteMany(ctx, values, opts)
+	}
+	read := func(tags []string) map[string]ReadResult {
+		results := make(map[string]ReadResult)
+		if err := addMissing(ctx, conn, tags); err != nil {
+			logger.Println("Cannot add all tags to write:", err)
+		}
+		deviceCtx := WithReadSource(ctx, SourceDevice)
+		for _, tag := range tags {
+			item, err := conn.ReadItemContext(deviceCtx, tag)
+			results[tag] = ReadResult{Item: item, Err: err}
+		}
+		return results
+	}
+	write := func(values map[string]interface{}) map[string]error {
+		failures := make(map[string]error)
+		for _, tag := range sortedKeys(values) {
+			if err := conn.WriteContext(ctx, tag, values[tag]); err != nil {
+				failures[tag] = err
+			}
+		}
+		return failures
+	}
+	failures := writeMany(values, opts, read, write)
+	if err := ctx.Err(); err != nil {
+		return failures, err
+	}
+	return failures, nil
+}
+
+//sortedKeys returns the tags of values in alphabetical order.
+func sortedKeys(values map[string]interface{}) []string {
+	tags := make([]string, 0, len(values))
+	for tag := range values {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+//writeMany writes values with write. For an all-or-nothing write, the previous
+//values are read with read first and restored with write on a partial failure.
+func writeMany(values map[string]interface{}, opts WriteOptions,
+	read func(tags []string) map[string]ReadResult,
+	write func(values map[string]interface{}) map[string]error) map[string]error {
+
+	if len(values) == 0 {
+		return map[string]error{}
+	}
+	if !opts.AllOrNothing {
+		return write(values)
+	}
+
+	tags := sortedKeys(values)
+	previous := read(tags)
+	failures := make(map[string]error)
+	for _, tag := range tags {
+		result, ok := previous[tag]
+		if !ok {
+			failures[tag] = fmt.Errorf("cannot read previous value of %s: %w", tag, ErrTagNotFound)
+		} else if result.Err != nil {
+			failures[tag] = fmt.Errorf("cannot read previous value of %s: %w", tag, result.Err)
+		}
+	}
+	if len(failures) > 0 {
+		for _, tag := range tags {
+			if _, failed := failures[tag]; !failed {
+				failures[tag] = fmt.Errorf("%s not written: %w", tag, ErrWriteAborted)
+			}
+		}
+		return failures
+	}
+
+	failures = write(values)
+	if len(failures) == 0 {
+		return failures
+	}
+	restore := make(map[string]interface{})
+	for _, tag := range tags {
+		if _, failed := failures[tag]; !failed {
+			restore[tag] = previous[tag].Value
+		}
+	}
+	restoreFailures := write(restore)
+	for tag := range restore {
+		if err, ok := restoreFailures[tag]; ok {
+			failures[tag] = fmt.Errorf("cannot restore previous value of %s: %w", tag, err)
+			continue
+		}
+		failures[tag] = fmt.Errorf("%s restored to previous value: %w", tag, ErrWriteAborted)
+	}
+	return failures
+}
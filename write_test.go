@@ -0,0 +1,110 @@
+package opcda
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func newWritableServer() *OpcMockServerWritable {
+	return &OpcMockServerWritable{
+		Values:    map[string]interface{}{"temp": 20.0, "speed": 100, "mode": "auto", "valve": true},
+		FailWrite: map[string]bool{},
+		FailRead:  map[string]bool{},
+	}
+}
+
+var recipe = map[string]interface{}{"temp": 80.0, "speed": 250, "mode": "manual"}
+
+func TestWriteMany(t *testing.T) {
+	server := newWritableServer()
+	server.FailWrite["speed"] = true
+
+	failures, err := WriteMany(context.Background(), server, recipe, WriteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 1 || failures["speed"] == nil {
+		t.Fatalf("only speed should fail, got %v", failures)
+	}
+	if server.Value("temp") != 80.0 || server.Value("mode") != "manual" {
+		t.Fatal("the other tags should be written")
+	}
+}
+
+func TestWriteManyAllOrNothing(t *testing.T) {
+	server := newWritableServer()
+	failures, err := WriteMany(context.Background(), server, recipe, WriteOptions{AllOrNothing: true})
+	if err != nil || len(failures) != 0 {
+		t.Fatalf("all tags should be written, got %v %v", failures, err)
+	}
+	if server.Value("speed") != 250 {
+		t.Fatal("speed should be written")
+	}
+
+	server = newWritableServer()
+	server.FailWrite["speed"] = true
+	failures, err = WriteMany(context.Background(), server, recipe, WriteOptions{AllOrNothing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 3 {
+		t.Fatalf("all tags should be reported, got %v", failures)
+	}
+	if errors.Is(failures["speed"], ErrWriteAborted) {
+		t.Error("speed should report why it failed")
+	}
+	for _, tag := range []string{"temp", "mode"} {
+		if !errors.Is(failures[tag], ErrWriteAborted) {
+			t.Errorf("%s should be rolled back, got %v", tag, failures[tag])
+		}
+	}
+	if server.Value("temp") != 20.0 || server.Value("speed") != 100 || server.Value("mode") != "auto" {
+		t.Fatal("previous values should be restored")
+	}
+}
+
+func TestWriteManyAllOrNothingUnreadable(t *testing.T) {
+	server := newWritableServer()
+	server.FailRead["mode"] = true
+
+	failures, err := WriteMany(context.Background(), server, recipe, WriteOptions{AllOrNothing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 3 || errors.Is(failures["mode"], ErrWriteAborted) || !errors.Is(failures["temp"], ErrWriteAborted) {
+		t.Fatalf("unexpected failures %v", failures)
+	}
+	if server.writes != 0 {
+		t.Fatal("nothing should be written if a previous value cannot be read")
+	}
+}
+
+func TestWriteManyCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	failures, err := WriteMany(ctx, newWritableServer(), recipe, WriteOptions{})
+	if !errors.Is(err, context.Canceled) || len(failures) != 3 {
+		t.Fatalf("canceled write should fail, got %v %v", failures, err)
+	}
+}
+
+type batchWriterMock struct {
+	*OpcMockServerWritable
+	calls int
+}
+
+func (m *batchWriterMock) WriteMany(ctx context.Context, values map[string]interface{}, opts WriteOptions) (map[string]error, error) {
+	m.calls++
+	return map[string]error{}, nil
+}
+
+func TestWriteManyBatchWriter(t *testing.T) {
+	mock := &batchWriterMock{OpcMockServerWritable: newWritableServer()}
+	if _, err := WriteMany(context.Background(), mock, recipe, WriteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if mock.calls != 1 || mock.writes != 0 {
+		t.Fatal("WriteMany of the connection should be used")
+	}
+}